@@ -1,45 +1,316 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
-
-	"github.com/rs/zerolog/log"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-var EnvConfig *envConfig
-
-// Struct with environment variables used in app
-type envConfig struct {
-	ServerPort        string
-	RabbitUrl         string
-	DbHost            string
-	DbPort            string
-	DbUser            string
-	DbPass            string
-	DbName            string
-	SslMode           string
-	NotificationQueue string
-}
-
-// Load the env variables from .env file. Defined variables
-// in docker-compose file will override those from .env file
-func Load() {
-	err := godotenv.Load()
+// Typed app configuration. Every field is loaded, in order of
+// precedence from lowest to highest, from the default tag, the config
+// file, env variable named by the env tag and the command line flag
+// (env name in lower case with dashes, like --db-host). Secret fields
+// can be read from the file named by <ENV>_FILE and are masked in Print.
+type Config struct {
+	ServerPort          string            `env:"SERVER_PORT" default:"9000" usage:"grpc server port"`
+	RabbitUrl           string            `env:"RABBIT_URL" secret:"true" usage:"rabbit connection url"`
+	DbDriver            string            `env:"DB_DRIVER" default:"postgres" usage:"database driver: postgres or sqlite"`
+	DbPath              string            `env:"DB_PATH" default:"usermanager.db" usage:"sqlite database file"`
+	DbHost              string            `env:"DB_HOST" usage:"postgres host"`
+	DbPort              string            `env:"DB_PORT" default:"5432" usage:"postgres port"`
+	DbUser              string            `env:"DB_USER" usage:"postgres user"`
+	DbPass              string            `env:"DB_PASS" secret:"true" usage:"postgres password"`
+	DbName              string            `env:"DB_NAME" usage:"postgres database"`
+	SslMode             string            `env:"SSL_MODE" default:"require" usage:"postgres ssl mode"`
+	DbMaxOpenConns      int               `env:"DB_MAX_OPEN_CONNS" default:"20" usage:"max open connections per database, 0 is unlimited"`
+	DbMaxIdleConns      int               `env:"DB_MAX_IDLE_CONNS" default:"10" usage:"max idle connections per database"`
+	DbConnMaxLifetime   time.Duration     `env:"DB_CONN_MAX_LIFETIME" default:"30m" usage:"max time a connection is reused"`
+	DbConnMaxIdleTime   time.Duration     `env:"DB_CONN_MAX_IDLE_TIME" default:"5m" usage:"max time a connection stays idle"`
+	DbStatementTimeout  time.Duration     `env:"DB_STATEMENT_TIMEOUT" default:"30s" usage:"postgres statement_timeout, 0 is none"`
+	DbReplicaHosts      []string          `env:"DB_REPLICA_HOSTS" usage:"read replicas as host:port,..."`
+	NotificationQueue   string            `env:"NOTIFICATION_QUEUE" default:"notification_queue" usage:"rabbit exchange for user change notifications"`
+	RabbitReconnect     time.Duration     `env:"RABBIT_RECONNECT_DELAY" default:"5s" usage:"delay between rabbit connection attempts"`
+	RabbitConfirm       time.Duration     `env:"RABBIT_CONFIRM_TIMEOUT" default:"5s" usage:"how long a publish waits for rabbit to confirm the message"`
+	RabbitCloudEvents   string            `env:"RABBIT_CLOUDEVENTS" default:"none" usage:"none, structured or binary CloudEvents message format"`
+	CloudEventsSource   string            `env:"CLOUDEVENTS_SOURCE" default:"/usermanager" usage:"source attribute of the cloud events"`
+	SpoolPath           string            `env:"NOTIFICATION_SPOOL_PATH" usage:"file that buffers notifications while rabbit is unreachable, disabled if empty"`
+	SpoolMaxBytes       int               `env:"NOTIFICATION_SPOOL_MAX_BYTES" default:"67108864" usage:"max size of the spool file, 0 is unlimited"`
+	SpoolFsync          string            `env:"NOTIFICATION_SPOOL_FSYNC" default:"interval" usage:"always, interval or never"`
+	SpoolFsyncInterval  time.Duration     `env:"NOTIFICATION_SPOOL_FSYNC_INTERVAL" default:"1s" usage:"how often the spool file is synced with interval fsync"`
+	NotifTimeout        time.Duration     `env:"NOTIFICATION_PUBLISH_TIMEOUT" default:"30s" usage:"deadline of publishing one notification to all transports, 0 is none"`
+	NotifTransports     []string          `env:"NOTIFICATION_TRANSPORTS" default:"rabbitmq" usage:"rabbitmq, nats, kafka, webhook or memory, several are published to at once"`
+	NatsUrl             string            `env:"NATS_URL" secret:"true" usage:"nats server url"`
+	NatsSubject         string            `env:"NATS_SUBJECT" default:"users.changed" usage:"nats subject for user change notifications"`
+	KafkaBrokers        []string          `env:"KAFKA_BROKERS" usage:"kafka brokers as host:port,..."`
+	KafkaTopic          string            `env:"KAFKA_TOPIC" default:"user_changes" usage:"kafka topic for user change notifications"`
+	NotifWebhookUrl     string            `env:"NOTIFICATION_WEBHOOK_URL" usage:"url user change notifications are posted to"`
+	NotifWebhookTimeout time.Duration     `env:"NOTIFICATION_WEBHOOK_TIMEOUT" default:"5s" usage:"deadline of one webhook request"`
+	WebhookWorkers      int               `env:"WEBHOOK_WORKERS" default:"4" usage:"webhook deliveries running at once"`
+	WebhookTimeout      time.Duration     `env:"WEBHOOK_TIMEOUT" default:"10s" usage:"deadline of one webhook delivery request"`
+	WebhookMaxAttempts  int               `env:"WEBHOOK_MAX_ATTEMPTS" default:"5" usage:"attempts of one webhook delivery"`
+	WebhookBackoff      time.Duration     `env:"WEBHOOK_RETRY_BACKOFF" default:"1s" usage:"delay before the first delivery retry, doubled for every next one"`
+	WebhookMaxBackoff   time.Duration     `env:"WEBHOOK_MAX_BACKOFF" default:"1m" usage:"max delay between delivery retries"`
+	WebhookDisableAfter int               `env:"WEBHOOK_DISABLE_AFTER" default:"10" usage:"failed deliveries in a row that disable a webhook, 0 never disables it"`
+	WebhookInsecure     bool              `env:"WEBHOOK_ALLOW_INSECURE" usage:"development only, allow http webhook urls and loopback, private or link-local addresses"`
+	UserCache           string            `env:"USER_CACHE" default:"none" usage:"none, memory or redis cache of user lookups"`
+	UserCacheSize       int               `env:"USER_CACHE_SIZE" default:"10000" usage:"users kept in the memory cache"`
+	UserCacheTTL        time.Duration     `env:"USER_CACHE_TTL" default:"1m" usage:"how long a user stays cached"`
+	UserCacheMissingTTL time.Duration     `env:"USER_CACHE_NOT_FOUND_TTL" default:"10s" usage:"how long a missing user stays cached, 0 doesn't cache it"`
+	UserCacheRedelete   time.Duration     `env:"USER_CACHE_INVALIDATE_DELAY" default:"1s" usage:"second removal of a written user from the cache, drops users cached by concurrent reads, 0 turns it off"`
+	RedisUrl            string            `env:"REDIS_URL" secret:"true" usage:"redis url of the user cache"`
+	RedisPrefix         string            `env:"USER_CACHE_REDIS_PREFIX" default:"usermanager:" usage:"prefix of the user cache keys in redis"`
+	WatchHistorySize    int               `env:"WATCH_HISTORY_SIZE" default:"1000" usage:"user events kept for resuming watches"`
+	WatchBufferSize     int               `env:"WATCH_BUFFER_SIZE" default:"100" usage:"events buffered per watcher"`
+	IdempotencyTTL      time.Duration     `env:"IDEMPOTENCY_TTL" default:"24h" usage:"how long idempotent responses are kept"`
+	AuthEnabled         bool              `env:"AUTH_ENABLED" usage:"authenticate callers"`
+	JwtAlgorithm        string            `env:"JWT_ALGORITHM" default:"HS256" usage:"HS256 or RS256"`
+	JwtKeyFile          string            `env:"JWT_KEY_FILE" usage:"file with jwt secret or public key"`
+	JwksFile            string            `env:"JWKS_FILE" usage:"file with json web key set"`
+	JwtIssuer           string            `env:"JWT_ISSUER" usage:"expected jwt issuer"`
+	JwtAudience         string            `env:"JWT_AUDIENCE" usage:"expected jwt audience"`
+	ApiKeys             []ApiKey          `env:"API_KEYS" secret:"true" usage:"api keys as name:key:role,..."`
+	AuthzEnabled        bool              `env:"AUTHZ_ENABLED" usage:"check caller's role per rpc"`
+	AuthzMetadataRoles  bool              `env:"AUTHZ_METADATA_ROLES" usage:"development only, read role and user id of anonymous callers from x-role and x-user-id metadata"`
+	TlsCertFile         string            `env:"TLS_CERT_FILE" usage:"server certificate file"`
+	TlsKeyFile          string            `env:"TLS_KEY_FILE" usage:"server key file"`
+	TlsClientCAFile     string            `env:"TLS_CLIENT_CA_FILE" usage:"CA for client certificates"`
+	TlsClientAuth       string            `env:"TLS_CLIENT_AUTH" default:"require" usage:"require or optional"`
+	MetricsPort         string            `env:"METRICS_PORT" usage:"prometheus metrics port"`
+	MetricsInterval     time.Duration     `env:"METRICS_REFRESH_INTERVAL" default:"1m" usage:"business metrics refresh interval"`
+	TraceExporter       string            `env:"TRACE_EXPORTER" default:"none" usage:"none, stdout or otlp"`
+	TraceOtlpEndpoint   string            `env:"TRACE_OTLP_ENDPOINT" usage:"otlp collector host:port"`
+	TraceOtlpInsecure   bool              `env:"TRACE_OTLP_INSECURE" usage:"send spans without TLS"`
+	TraceSampleRatio    float64           `env:"TRACE_SAMPLE_RATIO" default:"1" usage:"fraction of sampled traces"`
+	LogLevel            string            `env:"LOG_LEVEL" default:"info" usage:"minimal log level"`
+	LogFormat           string            `env:"LOG_FORMAT" default:"json" usage:"json or console"`
+	GatewayPort         string            `env:"GATEWAY_PORT" usage:"http/json gateway port"`
+	GatewayGrpcCAFile   string            `env:"GATEWAY_GRPC_CA_FILE" usage:"CA the gateway trusts"`
+	GatewayCertFile     string            `env:"GATEWAY_CLIENT_CERT_FILE" usage:"client certificate the gateway presents to the grpc server"`
+	GatewayKeyFile      string            `env:"GATEWAY_CLIENT_KEY_FILE" usage:"key of the gateway client certificate"`
+	TrustedProxies      []string          `env:"TRUSTED_PROXIES" default:"127.0.0.1/32,::1/128" usage:"networks of proxies whose x-forwarded-for is trusted, like the gateway on loopback"`
+	GrpcReflection      bool              `env:"GRPC_REFLECTION" usage:"register grpc reflection service"`
+	WebPort             string            `env:"WEB_PORT" usage:"grpc-web and connect port"`
+	WebAllowedOrigins   []string          `env:"WEB_ALLOWED_ORIGINS" usage:"browser origins allowed to call the server"`
+	RateLimitRps        float64           `env:"RATE_LIMIT_RPS" usage:"default requests per second per client"`
+	RateLimitBurst      int               `env:"RATE_LIMIT_BURST" usage:"default burst per client"`
+	RateLimitMethods    []MethodRateLimit `env:"RATE_LIMIT_METHODS" usage:"per method limits as method:rps:burst,..."`
+	HashConcurrency     int               `env:"PASSWORD_HASH_CONCURRENCY" usage:"concurrent password hashes, number of cpus if 0"`
+}
+
+type ApiKey struct {
+	Name string
+	Key  string
+	Role string
+}
+
+type MethodRateLimit struct {
+	Method string
+	Rps    float64
+	Burst  int
+}
+
+// All problems found while loading the config.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+// Options from the command line that are not part of the config.
+type Options struct {
+	// yaml or toml config file, also read from CONFIG_FILE
+	File string
+	// print effective config and exit
+	Print bool
+}
+
+// source of raw values by env variable name
+type source func(key string) (string, bool)
+
+// Load config from defaults, config file, .env file and env variables,
+// and command line args. Returns Errors with every problem found.
+func Load(args []string) (*Config, Options, error) {
+	// .env is optional and applied before the environment, so variables
+	// set in the environment win, also a <ENV>_FILE over <ENV> in .env
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, Options{}, Errors{fmt.Sprintf(".env: %v", err)}
+	}
+	return load(args, mapSource(dotenv), os.LookupEnv)
+}
+
+func load(args []string, dotenv source, env source) (*Config, Options, error) {
+	c := &Config{}
+	var opts Options
+	var problems Errors
+
+	// usage is already printed by the flag set, including for --help
+	flags, err := parseFlags(args, &opts)
 	if err != nil {
-		log.Fatal().Msg("Error loading .env file")
+		return nil, opts, err
+	}
+	if opts.File == "" {
+		opts.File, _ = env("CONFIG_FILE")
+	}
+
+	c.apply(defaultSource, "default", &problems)
+	if opts.File != "" {
+		file, fileProblems := fileSource(opts.File)
+		problems = append(problems, fileProblems...)
+		if file != nil {
+			c.apply(file, opts.File, &problems)
+		}
+	}
+	if dotenv != nil {
+		c.apply(dotenv, ".env", &problems)
+	}
+	c.apply(env, "env", &problems)
+	c.apply(flags, "flag", &problems)
+
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return nil, opts, problems
+	}
+	return c, opts, nil
+}
+
+// Set fields found in the source. Secret fields can point to the
+// file with the value with <ENV>_FILE.
+func (c *Config) apply(src source, layer string, problems *Errors) {
+	forEachField(c, func(f reflect.StructField, v reflect.Value) {
+		key := f.Tag.Get("env")
+		raw, ok := src(key)
+
+		if f.Tag.Get("secret") == "true" {
+			if path, fileOk := src(key + "_FILE"); fileOk && path != "" {
+				if ok {
+					*problems = append(*problems,
+						fmt.Sprintf("%v: both %v and %v_FILE are set (%v)", key, key, key, layer))
+					return
+				}
+				secret, err := os.ReadFile(path)
+				if err != nil {
+					*problems = append(*problems, fmt.Sprintf("%v_FILE: %v", key, err))
+					return
+				}
+				raw, ok = strings.TrimRight(string(secret), "\r\n"), true
+			}
+		}
+
+		if !ok {
+			return
+		}
+		if err := setField(v, raw); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%v: %v (%v)", key, err, layer))
+		}
+	})
+}
+
+// Print effective config as KEY=value lines with secrets masked.
+func (c *Config) Print(w io.Writer) {
+	forEachField(c, func(f reflect.StructField, v reflect.Value) {
+		value := formatField(v)
+		if f.Tag.Get("secret") == "true" && value != "" {
+			value = "******"
+		}
+		fmt.Fprintf(w, "%v=%v\n", f.Tag.Get("env"), value)
+	})
+}
+
+func forEachField(c *Config, fn func(reflect.StructField, reflect.Value)) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fn(v.Type().Field(i), v.Field(i))
+	}
+}
+
+// Values from the default tags.
+func mapSource(values map[string]string) source {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func defaultSource(key string) (string, bool) {
+	f, ok := fieldByEnv(key)
+	if !ok {
+		return "", false
+	}
+	return f.Tag.Lookup("default")
+}
+
+func fieldByEnv(key string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("env") == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Flag name for env variable, DB_HOST is --db-host.
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// Parse command line args. Returns source with flags that were set.
+func parseFlags(args []string, opts *Options) (source, error) {
+	fs := flag.NewFlagSet("usermanager", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "config", "", "yaml or toml config file")
+	fs.BoolVar(&opts.Print, "print-config", false, "print effective config and exit")
+
+	values := map[string]*flagValue{}
+	forEachField(&Config{}, func(f reflect.StructField, v reflect.Value) {
+		key := f.Tag.Get("env")
+		values[key] = &flagValue{isBool: v.Kind() == reflect.Bool}
+		fs.Var(values[key], flagName(key), f.Tag.Get("usage"))
+		if f.Tag.Get("secret") == "true" {
+			values[key+"_FILE"] = &flagValue{}
+			fs.Var(values[key+"_FILE"], flagName(key+"_FILE"), "file with "+f.Tag.Get("usage"))
+		}
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
 
-	EnvConfig = &envConfig{
-		ServerPort:        os.Getenv("SERVER_PORT"),
-		RabbitUrl:         os.Getenv("RABBIT_URL"),
-		DbHost:            os.Getenv("DB_HOST"),
-		DbPort:            os.Getenv("DB_PORT"),
-		DbUser:            os.Getenv("DB_USER"),
-		DbPass:            os.Getenv("DB_PASS"),
-		DbName:            os.Getenv("DB_NAME"),
-		SslMode:           os.Getenv("SSL_MODE"),
-		NotificationQueue: os.Getenv("NOTIFICATION_QUEUE"),
+	return func(key string) (string, bool) {
+		if value, ok := values[key]; ok && value.set {
+			return value.value, true
+		}
+		return "", false
+	}, nil
+}
+
+// Flag that remembers whether it was set, so only flags given
+// on the command line override other layers.
+type flagValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
 	}
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value, f.set = value, true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
 }
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Set field from the raw string value. Empty value resets
+// the field to zero value.
+func setField(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch p := v.Addr().Interface().(type) {
+	case *string:
+		*p = raw
+	case *int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("should be a number")
+		}
+		*p = i
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("should be a number")
+		}
+		*p = f
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("should be true or false")
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("should be a duration like 30s or 24h")
+		}
+		*p = d
+	case *[]string:
+		*p = parseList(raw)
+	case *[]ApiKey:
+		keys, err := parseApiKeys(raw)
+		if err != nil {
+			return err
+		}
+		*p = keys
+	case *[]MethodRateLimit:
+		limits, err := parseMethodRateLimits(raw)
+		if err != nil {
+			return err
+		}
+		*p = limits
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// Format field the same way it is parsed.
+func formatField(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case []string:
+		return strings.Join(value, ",")
+	case []ApiKey:
+		items := make([]string, len(value))
+		for i, k := range value {
+			items[i] = strings.TrimSuffix(k.Name+":"+k.Key+":"+k.Role, ":")
+		}
+		return strings.Join(items, ",")
+	case []MethodRateLimit:
+		items := make([]string, len(value))
+		for i, l := range value {
+			items[i] = fmt.Sprintf("%v:%v:%v", l.Method, l.Rps, l.Burst)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Returns values of comma separated list
+func parseList(raw string) []string {
+	values := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// Returns api keys in format 'name1:key1:role1,name2:key2:role2',
+// role is optional
+func parseApiKeys(raw string) ([]ApiKey, error) {
+	apiKeys := []ApiKey{}
+	for _, item := range parseList(raw) {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("should be in format 'name:key:role,...'")
+		}
+
+		apiKey := ApiKey{Name: parts[0], Key: parts[1]}
+		if len(parts) == 3 {
+			apiKey.Role = parts[2]
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, nil
+}
+
+// Returns per method rate limits in format
+// 'method1:rps1:burst1,method2:rps2:burst2'
+func parseMethodRateLimits(raw string) ([]MethodRateLimit, error) {
+	limits := []MethodRateLimit{}
+	for _, item := range parseList(raw) {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("should be in format 'method:rps:burst,...'")
+		}
+
+		rps, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("rate of %v should be a number", parts[0])
+		}
+		burst, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("burst of %v should be a number", parts[0])
+		}
+		limits = append(limits, MethodRateLimit{Method: parts[0], Rps: rps, Burst: burst})
+	}
+	return limits, nil
+}
+
+// Read yaml or toml config file. Keys are env variable names in any
+// case, like db_host or DB_HOST, lists can be written as arrays.
+// Unknown keys are reported, so typos don't go unnoticed.
+func fileSource(path string) (source, Errors) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Errors{fmt.Sprintf("config file: %v", err)}
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, Errors{fmt.Sprintf("config file: unsupported format %v, use yaml or toml", path)}
+	}
+	if err != nil {
+		return nil, Errors{fmt.Sprintf("config file: %v", err)}
+	}
+
+	var problems Errors
+	raw := map[string]string{}
+	for key, value := range values {
+		envKey := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if !knownKey(envKey) {
+			problems = append(problems, fmt.Sprintf("config file: unknown key %v", key))
+			continue
+		}
+		raw[envKey] = fileValue(value)
+	}
+	sort.Strings(problems)
+
+	return func(key string) (string, bool) {
+		value, ok := raw[key]
+		return value, ok
+	}, problems
+}
+
+// Field env name, or <ENV>_FILE of a secret field.
+func knownKey(key string) bool {
+	if _, ok := fieldByEnv(key); ok {
+		return true
+	}
+	f, ok := fieldByEnv(strings.TrimSuffix(key, "_FILE"))
+	return ok && f.Tag.Get("secret") == "true"
+}
+
+// Raw string value from the decoded file value.
+func fileValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fileValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
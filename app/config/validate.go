@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Check that required values are present and all values are in range.
+// Returns every problem found.
+func (c *Config) validate() Errors {
+	var problems Errors
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	required := map[string]string{
+		"SERVER_PORT": c.ServerPort,
+	}
+	if len(c.NotifTransports) == 0 {
+		problem("NOTIFICATION_TRANSPORTS: is required")
+	}
+	for _, transport := range c.NotifTransports {
+		switch transport {
+		case "rabbitmq":
+			required["RABBIT_URL"] = c.RabbitUrl
+			required["NOTIFICATION_QUEUE"] = c.NotificationQueue
+		case "nats":
+			required["NATS_URL"] = c.NatsUrl
+			required["NATS_SUBJECT"] = c.NatsSubject
+		case "kafka":
+			required["KAFKA_BROKERS"] = strings.Join(c.KafkaBrokers, ",")
+			required["KAFKA_TOPIC"] = c.KafkaTopic
+		case "webhook":
+			required["NOTIFICATION_WEBHOOK_URL"] = c.NotifWebhookUrl
+		case "memory":
+		default:
+			problem("NOTIFICATION_TRANSPORTS: %q should be one of [rabbitmq nats kafka webhook memory]", transport)
+		}
+	}
+	if c.UserCache == "redis" {
+		required["REDIS_URL"] = c.RedisUrl
+	}
+	if c.DbDriver == "sqlite" {
+		required["DB_PATH"] = c.DbPath
+	} else {
+		required["DB_HOST"] = c.DbHost
+		required["DB_PORT"] = c.DbPort
+		required["DB_USER"] = c.DbUser
+		required["DB_NAME"] = c.DbName
+	}
+	forEachKey(required, func(key, value string) {
+		if value == "" {
+			problem("%v: is required", key)
+		}
+	})
+
+	ports := map[string]string{
+		"SERVER_PORT":  c.ServerPort,
+		"DB_PORT":      c.DbPort,
+		"METRICS_PORT": c.MetricsPort,
+		"GATEWAY_PORT": c.GatewayPort,
+		"WEB_PORT":     c.WebPort,
+	}
+	forEachKey(ports, func(key, value string) {
+		if port, err := strconv.Atoi(value); value != "" && (err != nil || port < 1 || port > 65535) {
+			problem("%v: %q is not a valid port", key, value)
+		}
+	})
+
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		problem("%v: %q should be one of %v", key, value, allowed)
+	}
+	oneOf("DB_DRIVER", c.DbDriver, "postgres", "sqlite")
+	oneOf("SSL_MODE", c.SslMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	oneOf("JWT_ALGORITHM", c.JwtAlgorithm, "HS256", "RS256")
+	oneOf("TLS_CLIENT_AUTH", c.TlsClientAuth, "require", "optional")
+	oneOf("TRACE_EXPORTER", c.TraceExporter, "none", "stdout", "otlp")
+	oneOf("LOG_LEVEL", c.LogLevel, "trace", "debug", "info", "warn", "error")
+	oneOf("LOG_FORMAT", c.LogFormat, "json", "console")
+	oneOf("NOTIFICATION_SPOOL_FSYNC", c.SpoolFsync, "always", "interval", "never")
+	oneOf("RABBIT_CLOUDEVENTS", c.RabbitCloudEvents, "none", "structured", "binary")
+	oneOf("USER_CACHE", c.UserCache, "none", "memory", "redis")
+
+	if (c.TlsCertFile == "") != (c.TlsKeyFile == "") {
+		problem("TLS_CERT_FILE, TLS_KEY_FILE: both should be set to enable TLS")
+	}
+	if c.TlsClientCAFile != "" && c.TlsCertFile == "" {
+		problem("TLS_CLIENT_CA_FILE: requires TLS_CERT_FILE")
+	}
+	if (c.GatewayCertFile == "") != (c.GatewayKeyFile == "") {
+		problem("GATEWAY_CLIENT_CERT_FILE, GATEWAY_CLIENT_KEY_FILE: both should be set to present a client certificate")
+	}
+	if c.GatewayPort != "" && c.TlsClientCAFile != "" && c.TlsClientAuth == "require" && c.GatewayCertFile == "" {
+		problem("GATEWAY_CLIENT_CERT_FILE: is required when TLS_CLIENT_AUTH is require")
+	}
+	for _, network := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			problem("TRUSTED_PROXIES: %v is not a network like 127.0.0.1/32", network)
+		}
+	}
+	if c.AuthEnabled && c.JwtKeyFile == "" && c.JwksFile == "" && len(c.ApiKeys) == 0 {
+		problem("AUTH_ENABLED: requires JWT_KEY_FILE, JWKS_FILE or API_KEYS")
+	}
+	if c.AuthzMetadataRoles && (c.AuthEnabled || c.TlsClientCAFile != "") {
+		problem("AUTHZ_METADATA_ROLES: is for development only and cannot be combined with AUTH_ENABLED or TLS_CLIENT_CA_FILE")
+	}
+	if c.TraceExporter == "otlp" && c.TraceOtlpEndpoint == "" {
+		problem("TRACE_OTLP_ENDPOINT: is required for otlp exporter")
+	}
+	if c.DbDriver == "sqlite" && len(c.DbReplicaHosts) > 0 {
+		problem("DB_REPLICA_HOSTS: read replicas are not supported with sqlite")
+	}
+	if c.DbMaxOpenConns > 0 && c.DbMaxIdleConns > c.DbMaxOpenConns {
+		problem("DB_MAX_IDLE_CONNS: should not be greater than DB_MAX_OPEN_CONNS")
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		problem("TRACE_SAMPLE_RATIO: should be between 0 and 1")
+	}
+
+	if c.WebhookWorkers < 1 {
+		problem("WEBHOOK_WORKERS: should be at least 1")
+	}
+	if c.WebhookMaxAttempts < 1 {
+		problem("WEBHOOK_MAX_ATTEMPTS: should be at least 1")
+	}
+	if c.UserCache == "memory" && c.UserCacheSize < 1 {
+		problem("USER_CACHE_SIZE: should be at least 1")
+	}
+
+	nonNegative := map[string]float64{
+		"WATCH_HISTORY_SIZE":                float64(c.WatchHistorySize),
+		"WATCH_BUFFER_SIZE":                 float64(c.WatchBufferSize),
+		"IDEMPOTENCY_TTL":                   float64(c.IdempotencyTTL),
+		"METRICS_REFRESH_INTERVAL":          float64(c.MetricsInterval),
+		"RATE_LIMIT_RPS":                    c.RateLimitRps,
+		"RATE_LIMIT_BURST":                  float64(c.RateLimitBurst),
+		"PASSWORD_HASH_CONCURRENCY":         float64(c.HashConcurrency),
+		"DB_MAX_OPEN_CONNS":                 float64(c.DbMaxOpenConns),
+		"DB_MAX_IDLE_CONNS":                 float64(c.DbMaxIdleConns),
+		"DB_CONN_MAX_LIFETIME":              float64(c.DbConnMaxLifetime),
+		"DB_CONN_MAX_IDLE_TIME":             float64(c.DbConnMaxIdleTime),
+		"DB_STATEMENT_TIMEOUT":              float64(c.DbStatementTimeout),
+		"NOTIFICATION_PUBLISH_TIMEOUT":      float64(c.NotifTimeout),
+		"NOTIFICATION_WEBHOOK_TIMEOUT":      float64(c.NotifWebhookTimeout),
+		"WEBHOOK_TIMEOUT":                   float64(c.WebhookTimeout),
+		"RABBIT_RECONNECT_DELAY":            float64(c.RabbitReconnect),
+		"RABBIT_CONFIRM_TIMEOUT":            float64(c.RabbitConfirm),
+		"NOTIFICATION_SPOOL_MAX_BYTES":      float64(c.SpoolMaxBytes),
+		"NOTIFICATION_SPOOL_FSYNC_INTERVAL": float64(c.SpoolFsyncInterval),
+		"WEBHOOK_RETRY_BACKOFF":             float64(c.WebhookBackoff),
+		"WEBHOOK_MAX_BACKOFF":               float64(c.WebhookMaxBackoff),
+		"WEBHOOK_DISABLE_AFTER":             float64(c.WebhookDisableAfter),
+		"USER_CACHE_TTL":                    float64(c.UserCacheTTL),
+		"USER_CACHE_NOT_FOUND_TTL":          float64(c.UserCacheMissingTTL),
+		"USER_CACHE_INVALIDATE_DELAY":       float64(c.UserCacheRedelete),
+	}
+	for _, l := range c.RateLimitMethods {
+		if l.Rps < 0 || l.Burst < 0 {
+			problem("RATE_LIMIT_METHODS: limit of %v should not be negative", l.Method)
+		}
+	}
+	forEachKey(nonNegative, func(key string, value float64) {
+		if value < 0 {
+			problem("%v: should not be negative", key)
+		}
+	})
+
+	return problems
+}
+
+// Call fn for every key in sorted order, so problems
+// are always reported in the same order.
+func forEachKey[T any](values map[string]T, fn func(string, T)) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(key, values[key])
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User change notification that could not be published to a
+// transport. It is kept until it is replayed successfully.
+type DeadLetter struct {
+	Id        uuid.UUID `gorm:"column:id;primaryKey"`
+	Transport string    `gorm:"column:transport;index;not null"`
+	UserId    uuid.UUID `gorm:"column:user_id;index;not null"`
+	// W3C traceparent of the request that changed the user
+	TraceParent string `gorm:"column:trace_parent"`
+	// id and time of the original message, replays keep them so
+	// consumers see the same event. Not set for dead letters stored
+	// before they were kept.
+	EventId   uuid.UUID  `gorm:"column:event_id"`
+	EventTime *time.Time `gorm:"column:event_time"`
+	// error of the last attempt
+	Error     string    `gorm:"column:error;not null"`
+	Attempts  int       `gorm:"column:attempts;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	// time of the last attempt
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
@@ -11,6 +11,14 @@ const (
 	UniqueConstraintEmail    = "users_email_key"
 )
 
+// ErrorInfo reasons of the unique constraint errors, clients match on
+// them instead of the message.
+const (
+	ErrorDomain              = "usermanager"
+	ErrorReasonNicknameTaken = "NICKNAME_TAKEN"
+	ErrorReasonEmailTaken    = "EMAIL_TAKEN"
+)
+
 type User struct {
 	Id        uuid.UUID `gorm:"column:id;primaryKey"`
 	Firstname string    `gorm:"column:first_name;not null"`
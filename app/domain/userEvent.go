@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserEventType int
+
+const (
+	UserCreated UserEventType = iota + 1
+	UserUpdated
+	UserDeleted
+)
+
+// Lower case name of the event type, like "created".
+func (t UserEventType) String() string {
+	switch t {
+	case UserCreated:
+		return "created"
+	case UserUpdated:
+		return "updated"
+	case UserDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Change that happened to a user. Sequence is assigned by the event
+// hub and is strictly increasing within the epoch of the hub, so
+// subscribers can resume from it. User is nil for deleted users.
+type UserEvent struct {
+	Epoch    string
+	Sequence uint64
+	Type     UserEventType
+	UserId   uuid.UUID
+	User     *User
+	Time     time.Time
+}
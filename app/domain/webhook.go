@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription to user events delivered over HTTP. Empty event
+// types means all of them.
+type Webhook struct {
+	Id                  uuid.UUID       `gorm:"column:id;primaryKey"`
+	Url                 string          `gorm:"column:url;not null"`
+	EventTypes          []UserEventType `gorm:"column:event_types;serializer:json"`
+	Secret              string          `gorm:"column:secret;not null"`
+	Enabled             bool            `gorm:"column:enabled;not null"`
+	ConsecutiveFailures int             `gorm:"column:consecutive_failures;not null"`
+	CreatedAt           time.Time       `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt           time.Time       `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// Whether the webhook is subscribed to the event type.
+func (w Webhook) Accepts(t UserEventType) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, eventType := range w.EventTypes {
+		if eventType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether webhooks may be delivered to the address. Loopback, private,
+// link-local and unspecified addresses are internal to the deployment
+// (like the cloud metadata service on 169.254.169.254) and are rejected.
+func WebhookAddressAllowed(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified())
+}
+
+// Single attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	Id            uuid.UUID     `gorm:"column:id;primaryKey"`
+	WebhookId     uuid.UUID     `gorm:"column:webhook_id;index;not null"`
+	EventSequence uint64        `gorm:"column:event_sequence;not null"`
+	EventType     UserEventType `gorm:"column:event_type;not null"`
+	UserId        uuid.UUID     `gorm:"column:user_id;not null"`
+	Attempt       int           `gorm:"column:attempt;not null"`
+	// zero if there was no response
+	StatusCode int           `gorm:"column:status_code;not null"`
+	Error      string        `gorm:"column:error"`
+	Success    bool          `gorm:"column:success;not null"`
+	Duration   time.Duration `gorm:"column:duration;not null"`
+	CreatedAt  time.Time     `gorm:"column:created_at;autoCreateTime"`
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backends of the user cache.
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Key is not cached or it expired.
+var ErrMiss = errors.New("cache miss")
+
+// Key value store where every value expires after its ttl.
+type Cache interface {
+	// Value of the key, ErrMiss if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
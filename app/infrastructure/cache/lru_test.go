@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_Full_ShouldEvictLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	ctx := context.Background()
+
+	// arrange
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+	_, _ = c.Get(ctx, "a")
+
+	// act
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	// assert
+	_, err := c.Get(ctx, "b")
+	assert.Equal(t, ErrMiss, err)
+	value, err := c.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expired_ShouldReturnMiss(t *testing.T) {
+	c := NewLRU(2)
+	ctx := context.Background()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// arrange
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	// act
+	now = now.Add(time.Minute)
+	_, err := c.Get(ctx, "a")
+
+	// assert
+	assert.Equal(t, ErrMiss, err)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRU_Delete_ShouldRemoveKey(t *testing.T) {
+	c := NewLRU(2)
+	ctx := context.Background()
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	_ = c.Delete(ctx, "a")
+
+	_, err := c.Get(ctx, "a")
+	assert.Equal(t, ErrMiss, err)
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func createRedis(t *testing.T) (*redisCache, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	c, err := NewRedis(RedisConfig{Url: "redis://" + server.Addr(), Prefix: "usermanager:"})
+	assert.Nil(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func TestRedis_Set_ShouldStoreWithPrefixAndTTL(t *testing.T) {
+	c, server := createRedis(t)
+	ctx := context.Background()
+
+	// act
+	err := c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	// assert
+	assert.Nil(t, err)
+	value, err := server.Get("usermanager:a")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", value)
+	assert.Equal(t, time.Minute, server.TTL("usermanager:a"))
+}
+
+func TestRedis_Expired_ShouldReturnMiss(t *testing.T) {
+	c, server := createRedis(t)
+	ctx := context.Background()
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	// act
+	server.FastForward(time.Minute)
+	_, err := c.Get(ctx, "a")
+
+	// assert
+	assert.Equal(t, ErrMiss, err)
+}
+
+func TestRedis_Unreachable_ShouldReturnErr(t *testing.T) {
+	c, server := createRedis(t)
+	server.Close()
+
+	_, err := c.Get(context.Background(), "a")
+
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrMiss, err)
+}
+
+func TestNewRedis_InvalidUrl_ShouldReturnErr(t *testing.T) {
+	_, err := NewRedis(RedisConfig{Url: "localhost:6379"})
+
+	assert.NotNil(t, err)
+}
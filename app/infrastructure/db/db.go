@@ -2,17 +2,135 @@ package db
 
 import (
 	"fmt"
+	"time"
+	"usermanager/app/infrastructure/tracing"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+// Supported database drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+)
+
+// Database connection settings
+type Config struct {
+	// postgres (default) or sqlite
+	Driver string
+	// sqlite database file, ":memory:" keeps the database in memory
+	Path string
+	// postgres connection
+	Host    string
+	Port    string
+	User    string
+	Pass    string
+	Name    string
+	SslMode string
+	// pool limits, applied to the primary and to every replica,
+	// zero keeps the database/sql default
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// statement_timeout of every connection, zero means no timeout
+	StatementTimeout time.Duration
+	// read replicas as host or host:port, with the same credentials
+	// and database name as the primary
+	ReplicaHosts []string
+}
+
 // open database method, returns gorm db wrapper or error if ocurred
-func OpenDb() (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dbUrl()), &gorm.Config{})
+func OpenDb(c Config) (*gorm.DB, error) {
+	if c.Driver == DriverSqlite {
+		return openSqlite(c)
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(c.ReplicaHosts))
+	for _, host := range c.ReplicaHosts {
+		replicas = append(replicas, postgres.Open(dbUrl(replicaConfig(c, host))))
+	}
+	return openDb(postgres.Open(dbUrl(c)), replicas, c)
+}
+
+// Open primary, set pool limits and route reads to the replicas if
+// there are any. Writes, transactions and queries with
+// dbresolver.Write clause stay on the primary.
+func openDb(primary gorm.Dialector, replicas []gorm.Dialector, c Config) (*gorm.DB, error) {
+	db, err := gorm.Open(primary, &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect database: %v", err)
 	}
 
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database pool: %v", err)
+	}
+	setPoolLimits(sqlDb, c)
+
+	if len(replicas) > 0 {
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		})
+		setPoolLimits(resolverPool{resolver}, c)
+		if err := db.Use(resolver); err != nil {
+			return nil, fmt.Errorf("failed to connect read replicas: %v", err)
+		}
+	}
+
+	// span for every query
+	if err := db.Use(tracing.NewGormPlugin()); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %v", err)
+	}
+
 	return db, nil
 }
+
+// Open sqlite database. There are no replicas and the statement
+// timeout is not supported, concurrent writers wait for the lock.
+func openSqlite(c Config) (*gorm.DB, error) {
+	// every connection to an in memory database gets a new empty one
+	if c.Path == ":memory:" {
+		c.MaxOpenConns = 1
+	}
+	c.ReplicaHosts = nil
+	return openDb(sqlite.Open(sqliteDsn(c)), nil, c)
+}
+
+// Implemented by sql.DB and by the dbresolver for replica pools.
+type pool interface {
+	SetMaxOpenConns(int)
+	SetMaxIdleConns(int)
+	SetConnMaxLifetime(time.Duration)
+	SetConnMaxIdleTime(time.Duration)
+}
+
+// Set the limits that are configured, others keep the defaults.
+func setPoolLimits(p pool, c Config) {
+	if c.MaxOpenConns > 0 {
+		p.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		p.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		p.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		p.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
+
+// Replica pools of the resolver, its setters return the resolver.
+type resolverPool struct {
+	r *dbresolver.DBResolver
+}
+
+func (p resolverPool) SetMaxOpenConns(n int)              { p.r.SetMaxOpenConns(n) }
+func (p resolverPool) SetMaxIdleConns(n int)              { p.r.SetMaxIdleConns(n) }
+func (p resolverPool) SetConnMaxLifetime(d time.Duration) { p.r.SetConnMaxLifetime(d) }
+func (p resolverPool) SetConnMaxIdleTime(d time.Duration) { p.r.SetConnMaxIdleTime(d) }
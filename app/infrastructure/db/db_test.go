@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func mockDialector(t *testing.T) (gorm.Dialector, sqlmock.Sqlmock) {
+	mockDb, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	return postgres.New(postgres.Config{
+		DriverName: "postgres",
+		Conn:       mockDb,
+	}), mock
+}
+
+func TestOpenDb_WithReplica_ShouldRouteReadsToReplica(t *testing.T) {
+	primary, primaryMock := mockDialector(t)
+	replica, replicaMock := mockDialector(t)
+
+	// arrange
+	db, err := openDb(primary, []gorm.Dialector{replica}, Config{})
+	assert.Nil(t, err)
+
+	replicaMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(regexp.QuoteMeta(`UPDATE "users"`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectCommit()
+
+	// act
+	var users []domain.User
+	readErr := db.WithContext(context.Background()).Find(&users).Error
+	writeErr := db.Model(&domain.User{}).Where("id = ?", "id").Update("country", "RS").Error
+
+	// assert
+	assert.Nil(t, readErr)
+	assert.Nil(t, writeErr)
+	assert.Nil(t, replicaMock.ExpectationsWereMet())
+	assert.Nil(t, primaryMock.ExpectationsWereMet())
+}
+
+func TestOpenDb_ShouldSetPoolLimits(t *testing.T) {
+	primary, _ := mockDialector(t)
+
+	db, err := openDb(primary, nil, Config{MaxOpenConns: 7})
+	assert.Nil(t, err)
+
+	sqlDb, _ := db.DB()
+	assert.Equal(t, 7, sqlDb.Stats().MaxOpenConnections)
+}
+
+func TestDbUrl_StatementTimeout_ShouldBeRuntimeParam(t *testing.T) {
+	c := Config{User: "u", Pass: "p", Host: "db", Port: "5432", Name: "users",
+		SslMode: "disable", StatementTimeout: 5 * time.Second}
+
+	url := dbUrl(c)
+
+	assert.Equal(t, "postgres://u:p@db:5432/users?sslmode=disable&statement_timeout=5000", url)
+}
+
+func TestReplicaConfig_ShouldUseReplicaHostAndPort(t *testing.T) {
+	c := Config{Host: "primary", Port: "5432", ReplicaHosts: []string{"replica"}}
+
+	withPort := replicaConfig(c, "replica-1:6432")
+	withoutPort := replicaConfig(c, "replica-2")
+
+	assert.Equal(t, "replica-1", withPort.Host)
+	assert.Equal(t, "6432", withPort.Port)
+	assert.Equal(t, "replica-2", withoutPort.Host)
+	assert.Equal(t, "5432", withoutPort.Port)
+	assert.Empty(t, withPort.ReplicaHosts)
+}
+
+func TestMigrateDb_Sqlite_ShouldCreateFileAndBeRepeatable(t *testing.T) {
+	c := Config{Driver: DriverSqlite, Path: filepath.Join(t.TempDir(), "users.db")}
+
+	// act
+	firstErr := MigrateDb(c)
+	secondErr := MigrateDb(c)
+
+	// assert
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	db, err := OpenDb(c)
+	assert.Nil(t, err)
+	assert.True(t, db.Migrator().HasTable(&domain.User{}))
+}
+
+func TestOpenDb_SqliteInMemory_ShouldUseSingleConnection(t *testing.T) {
+	db, err := OpenDb(Config{Driver: DriverSqlite, Path: ":memory:", MaxOpenConns: 10})
+	assert.Nil(t, err)
+
+	sqlDb, err := db.DB()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sqlDb.Stats().MaxOpenConnections)
+}
@@ -5,37 +5,43 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	c "usermanager/app/config"
+	"net"
 	"usermanager/app/domain"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 )
 
 // migrate database method, returns error if ocurred.
-func MigrateDb() error {
+func MigrateDb(c Config) error {
 	// first we need to validate db, check if exist
-	// on server, if not we need to create new one
-	if err := validateDb(); err != nil {
-		return err
+	// on server, if not we need to create new one,
+	// sqlite creates the file when it is opened
+	if c.Driver != DriverSqlite {
+		if err := validateDb(c); err != nil {
+			return err
+		}
 	}
 
-	db, err := OpenDb()
+	// schema changes go to the primary only
+	c.ReplicaHosts = nil
+	db, err := OpenDb(c)
 	if err != nil {
 		return err
 	}
 
 	// simple db migrations from domain models
-	return db.AutoMigrate(&domain.User{})
+	return db.AutoMigrate(&domain.User{}, &domain.Webhook{}, &domain.WebhookDelivery{},
+		&domain.DeadLetter{})
 }
 
 // validate does database exist method, returns error if ocurred
-func validateDb() error {
-	dbExist, err := dbExist()
+func validateDb(c Config) error {
+	dbExist, err := dbExist(c)
 	if err != nil {
 		return err
 	}
 	if !dbExist {
-		if err = createDb(); err != nil {
+		if err = createDb(c); err != nil {
 			return fmt.Errorf("create database failed: %v", err)
 		}
 	}
@@ -44,14 +50,14 @@ func validateDb() error {
 }
 
 // create new database method, returns error if ocurred.
-func createDb() error {
-	db, err := sql.Open("postgres", server())
+func createDb(c Config) error {
+	db, err := sql.Open("postgres", server(c))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", c.EnvConfig.DbName))
+	_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", c.Name))
 	if err != nil {
 		return err
 	}
@@ -60,8 +66,8 @@ func createDb() error {
 }
 
 // Checks if the database exists method.
-func dbExist() (bool, error) {
-	db, err := sql.Open("postgres", server())
+func dbExist(c Config) (bool, error) {
+	db, err := sql.Open("postgres", server(c))
 	if err != nil {
 		return false, err
 	}
@@ -71,7 +77,7 @@ func dbExist() (bool, error) {
 		WHERE lower(datname) = lower($1));`
 
 	ctx := context.Background()
-	row := db.QueryRowContext(ctx, sqlQuery, c.EnvConfig.DbName)
+	row := db.QueryRowContext(ctx, sqlQuery, c.Name)
 
 	var dbExists bool
 	err = row.Scan(&dbExists)
@@ -79,24 +85,46 @@ func dbExist() (bool, error) {
 	return dbExists, err
 }
 
-// DB connection string from env variables
-func dbUrl() string {
-	return fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v",
-		c.EnvConfig.DbUser,
-		c.EnvConfig.DbPass,
-		c.EnvConfig.DbHost,
-		c.EnvConfig.DbPort,
-		c.EnvConfig.DbName,
-		c.EnvConfig.SslMode)
+// DB connection string from config. Statement timeout is sent
+// to postgres as run-time parameter in milliseconds.
+func dbUrl(c Config) string {
+	url := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v",
+		c.User,
+		c.Pass,
+		c.Host,
+		c.Port,
+		c.Name,
+		c.SslMode)
+	if c.StatementTimeout > 0 {
+		url += fmt.Sprintf("&statement_timeout=%d", c.StatementTimeout.Milliseconds())
+	}
+	return url
+}
+
+// Sqlite DSN from config. Busy timeout makes concurrent writers
+// wait for the lock instead of failing with "database is locked".
+func sqliteDsn(c Config) string {
+	return fmt.Sprintf("%v?_busy_timeout=5000&_foreign_keys=on", c.Path)
+}
+
+// Config of the replica, port of the primary is used
+// if replica host has no port.
+func replicaConfig(c Config, replica string) Config {
+	c.Host = replica
+	if host, port, err := net.SplitHostPort(replica); err == nil {
+		c.Host, c.Port = host, port
+	}
+	c.ReplicaHosts = nil
+	return c
 }
 
 // Connection string without db name. We need this one so
 // we can check first does database exist on the server.
-func server() string {
+func server(c Config) string {
 	return fmt.Sprintf("postgres://%v:%v@%v:%v/?sslmode=%v",
-		c.EnvConfig.DbUser,
-		c.EnvConfig.DbPass,
-		c.EnvConfig.DbHost,
-		c.EnvConfig.DbPort,
-		c.EnvConfig.SslMode)
+		c.User,
+		c.Pass,
+		c.Host,
+		c.Port,
+		c.SslMode)
 }
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type UserEventHubMock struct {
+	mock.Mock
+}
+
+// this mock will only record that the method was called
+func (h *UserEventHubMock) Publish(eventType domain.UserEventType, userId uuid.UUID, user *domain.User) {
+	_ = h.Called(eventType, userId, user)
+}
+
+func (h *UserEventHubMock) Subscribe(from events.ResumePoint, filter events.EventFilter) (events.Subscription, error) {
+	args := h.Called(from, filter)
+
+	var r0 events.Subscription
+	if rf, ok := args.Get(0).(func(events.ResumePoint, events.EventFilter) events.Subscription); ok {
+		r0 = rf(from, filter)
+	} else if args.Get(0) != nil {
+		r0 = args.Get(0).(events.Subscription)
+	}
+
+	var r1 error
+	if rf, ok := args.Get(1).(func(events.ResumePoint, events.EventFilter) error); ok {
+		r1 = rf(from, filter)
+	} else {
+		r1 = args.Error(1)
+	}
+
+	return r0, r1
+}
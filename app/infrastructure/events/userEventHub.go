@@ -0,0 +1,237 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"usermanager/app/domain"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultHistorySize = 1000
+	DefaultBufferSize  = 100
+)
+
+// Decides whether the event should be delivered to a subscriber.
+type EventFilter func(e domain.UserEvent) bool
+
+// Point in the event history from which subscriber wants to receive
+// events. Zero value means only new events. Epoch is the epoch of the
+// event with AfterSequence, empty epoch is not checked.
+type ResumePoint struct {
+	Epoch         string
+	AfterSequence uint64
+	Since         time.Time
+}
+
+type UserEventHub interface {
+	Publish(eventType domain.UserEventType, userId uuid.UUID, user *domain.User)
+	Subscribe(from ResumePoint, filter EventFilter) (Subscription, error)
+}
+
+type Subscription interface {
+	// Channel with events. It is closed when the subscription is
+	// closed or when the subscriber can't keep up with publishing.
+	Events() <-chan domain.UserEvent
+	// Reason why events channel was closed by the hub, nil otherwise.
+	Err() error
+	Close()
+}
+
+type userEventHub struct {
+	mu          sync.Mutex
+	epoch       string
+	sequence    uint64
+	history     []domain.UserEvent
+	historySize int
+	bufferSize  int
+	subs        map[*subscription]struct{}
+}
+
+type subscription struct {
+	hub     *userEventHub
+	events  chan domain.UserEvent
+	filter  EventFilter
+	lastSeq uint64
+	err     error
+	closed  bool
+}
+
+// Create in memory event hub. The hub keeps last historySize events so
+// subscribers can resume, and every subscriber gets a buffer of
+// bufferSize events. Sequences start at 1 in a new random epoch, so a
+// subscriber can't resume from a sequence of another hub. Subscriber with a full buffer is disconnected
+// instead of blocking the publisher.
+func NewUserEventHub(historySize int, bufferSize int) *userEventHub {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &userEventHub{
+		epoch:       uuid.NewString(),
+		history:     make([]domain.UserEvent, 0, historySize),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subs:        make(map[*subscription]struct{}),
+	}
+}
+
+// Publish user change to all subscribers. Never blocks.
+func (h *userEventHub) Publish(eventType domain.UserEventType, userId uuid.UUID, user *domain.User) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	event := domain.UserEvent{
+		Epoch:    h.epoch,
+		Sequence: h.sequence,
+		Type:     eventType,
+		UserId:   userId,
+		User:     user,
+		Time:     time.Now().UTC(),
+	}
+
+	// keep only last historySize events
+	if len(h.history) == h.historySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:len(h.history)-1]
+	}
+	h.history = append(h.history, event)
+
+	for s := range h.subs {
+		s.send(event)
+	}
+}
+
+// Subscribe to user events. Events from the history after the resume
+// point are delivered first. Returns OutOfRange error if the requested
+// events are no longer in the history.
+func (h *userEventHub) Subscribe(from ResumePoint, filter EventFilter) (Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	replay, err := h.replay(from)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &subscription{
+		hub:     h,
+		events:  make(chan domain.UserEvent, h.bufferSize+len(replay)),
+		filter:  filter,
+		lastSeq: h.sequence,
+	}
+	for _, e := range replay {
+		if s.matches(e) {
+			s.events <- e
+		}
+	}
+	h.subs[s] = struct{}{}
+
+	return s, nil
+}
+
+// Events from the history after the resume point.
+func (h *userEventHub) replay(from ResumePoint) ([]domain.UserEvent, error) {
+	if from.AfterSequence == 0 && from.Since.IsZero() {
+		return nil, nil
+	}
+
+	if from.AfterSequence > 0 && from.Epoch != "" && from.Epoch != h.epoch {
+		return nil, status.Errorf(codes.OutOfRange,
+			"sequence %v is from epoch %v, events are now in epoch %v", from.AfterSequence, from.Epoch, h.epoch)
+	}
+
+	if from.AfterSequence > h.sequence {
+		return nil, status.Errorf(codes.OutOfRange,
+			"sequence %v is ahead of the last event %v", from.AfterSequence, h.sequence)
+	}
+
+	// sequence of the oldest event we still have
+	oldest := h.sequence + 1
+	if len(h.history) > 0 {
+		oldest = h.history[0].Sequence
+	}
+	trimmed := oldest > 1
+
+	if from.AfterSequence > 0 {
+		if from.AfterSequence+1 < oldest {
+			return nil, status.Errorf(codes.OutOfRange,
+				"events after sequence %v are no longer available", from.AfterSequence)
+		}
+		return h.historyFrom(func(e domain.UserEvent) bool {
+			return e.Sequence > from.AfterSequence
+		}), nil
+	}
+
+	if trimmed && (len(h.history) == 0 || from.Since.Before(h.history[0].Time)) {
+		return nil, status.Errorf(codes.OutOfRange,
+			"events since %v are no longer available", from.Since)
+	}
+	return h.historyFrom(func(e domain.UserEvent) bool {
+		return !e.Time.Before(from.Since)
+	}), nil
+}
+
+// Returns history starting with the first event that satisfies start.
+func (h *userEventHub) historyFrom(start func(e domain.UserEvent) bool) []domain.UserEvent {
+	for i, e := range h.history {
+		if start(e) {
+			return append([]domain.UserEvent(nil), h.history[i:]...)
+		}
+	}
+	return nil
+}
+
+func (h *userEventHub) unsubscribe(s *subscription, err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.events)
+	delete(h.subs, s)
+}
+
+// Non blocking send of the event. Must be called with hub lock held.
+func (s *subscription) send(e domain.UserEvent) {
+	if !s.matches(e) {
+		s.lastSeq = e.Sequence
+		return
+	}
+
+	select {
+	case s.events <- e:
+		s.lastSeq = e.Sequence
+	default:
+		// subscriber is too slow, drop it so it can resume later
+		s.hub.unsubscribe(s, status.Error(codes.ResourceExhausted,
+			fmt.Sprintf("subscriber is too slow, resume after sequence %v", s.lastSeq)))
+	}
+}
+
+func (s *subscription) matches(e domain.UserEvent) bool {
+	return s.filter == nil || s.filter(e)
+}
+
+func (s *subscription) Events() <-chan domain.UserEvent {
+	return s.events
+}
+
+func (s *subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (s *subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.unsubscribe(s, nil)
+}
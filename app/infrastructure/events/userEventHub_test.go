@@ -0,0 +1,174 @@
+package events
+
+import (
+	"testing"
+	"time"
+	"usermanager/app/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSubscribe_NewEvent_ShouldBeDelivered(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	sub, err := hub.Subscribe(ResumePoint{}, nil)
+	assert.Nil(t, err)
+	id := uuid.New()
+
+	// act
+	hub.Publish(domain.UserCreated, id, &domain.User{Id: id})
+
+	// assert
+	event := <-sub.Events()
+	assert.Equal(t, uint64(1), event.Sequence)
+	assert.Equal(t, domain.UserCreated, event.Type)
+	assert.Equal(t, id, event.UserId)
+}
+
+func TestSubscribe_WithFilter_ShouldDeliverOnlyMatchedEvents(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	sub, _ := hub.Subscribe(ResumePoint{}, func(e domain.UserEvent) bool {
+		return e.Type == domain.UserDeleted
+	})
+
+	// act
+	hub.Publish(domain.UserCreated, uuid.New(), &domain.User{})
+	hub.Publish(domain.UserDeleted, uuid.New(), nil)
+
+	// assert
+	event := <-sub.Events()
+	assert.Equal(t, domain.UserDeleted, event.Type)
+	assert.Equal(t, uint64(2), event.Sequence)
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestSubscribe_AfterSequence_ShouldReplayHistory(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	for i := 0; i < 5; i++ {
+		hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	}
+
+	// act
+	sub, err := hub.Subscribe(ResumePoint{AfterSequence: 3}, nil)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), (<-sub.Events()).Sequence)
+	assert.Equal(t, uint64(5), (<-sub.Events()).Sequence)
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestSubscribe_AfterSequenceOfSameEpoch_ShouldReplayHistory(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	sub, _ := hub.Subscribe(ResumePoint{}, nil)
+	hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	first := <-sub.Events()
+	sub.Close()
+
+	// act
+	resumed, err := hub.Subscribe(ResumePoint{Epoch: first.Epoch, AfterSequence: first.Sequence}, nil)
+
+	// assert
+	assert.Nil(t, err)
+	assert.NotEmpty(t, first.Epoch)
+	event := <-resumed.Events()
+	assert.Equal(t, uint64(2), event.Sequence)
+	assert.Equal(t, first.Epoch, event.Epoch)
+}
+
+func TestSubscribe_AfterSequenceOfOtherEpoch_ShouldReturnErr(t *testing.T) {
+	restarted := NewUserEventHub(10, 10)
+
+	// arrange
+	for i := 0; i < 5; i++ {
+		restarted.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	}
+
+	// act
+	sub, err := restarted.Subscribe(ResumePoint{Epoch: NewUserEventHub(10, 10).epoch, AfterSequence: 3}, nil)
+
+	// assert
+	assert.Nil(t, sub)
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func TestSubscribe_Since_ShouldReplayHistory(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	time.Sleep(time.Millisecond * 10)
+	since := time.Now()
+	hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+
+	// act
+	sub, err := hub.Subscribe(ResumePoint{Since: since}, nil)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), (<-sub.Events()).Sequence)
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestSubscribe_SequenceNoLongerInHistory_ShouldReturnErr(t *testing.T) {
+	hub := NewUserEventHub(2, 10)
+
+	// arrange
+	for i := 0; i < 5; i++ {
+		hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	}
+
+	// act
+	sub, err := hub.Subscribe(ResumePoint{AfterSequence: 1}, nil)
+
+	// assert
+	assert.Nil(t, sub)
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func TestPublish_SlowSubscriber_ShouldBeDisconnected(t *testing.T) {
+	hub := NewUserEventHub(10, 2)
+
+	// arrange
+	sub, _ := hub.Subscribe(ResumePoint{}, nil)
+
+	// act, publisher must not block on the third event
+	for i := 0; i < 3; i++ {
+		hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+	}
+
+	// assert, buffered events are still delivered before channel is closed
+	assert.Equal(t, uint64(1), (<-sub.Events()).Sequence)
+	assert.Equal(t, uint64(2), (<-sub.Events()).Sequence)
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sub.Err()))
+	assert.Contains(t, sub.Err().Error(), "resume after sequence 2")
+}
+
+func TestClose_ShouldStopDelivery(t *testing.T) {
+	hub := NewUserEventHub(10, 10)
+
+	// arrange
+	sub, _ := hub.Subscribe(ResumePoint{}, nil)
+
+	// act
+	sub.Close()
+	hub.Publish(domain.UserUpdated, uuid.New(), &domain.User{})
+
+	// assert
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.Nil(t, sub.Err())
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const DefaultTTL = 24 * time.Hour
+
+// How often expired records are removed from the store.
+const sweepInterval = time.Minute
+
+// Stored result of the request made with an idempotency key.
+// Response is nil while the first request is still in progress.
+type Record struct {
+	RequestHash [32]byte
+	Response    proto.Message
+	ExpiresAt   time.Time
+}
+
+type IdempotencyStore interface {
+	// Reserve key for the request with provided hash. If the key is
+	// already known, existing record is returned with true.
+	Reserve(key string, requestHash [32]byte) (Record, bool)
+	// Save response of the request for the reserved key.
+	Save(key string, response proto.Message)
+	// Release reserved key so the request can be retried.
+	Release(key string)
+}
+
+type idempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	records   map[string]Record
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// Create in memory idempotency store. Records are kept for ttl. The
+// store is not shared between instances and is lost on restart, so a
+// retry routed to another instance runs the request again.
+func NewIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &idempotencyStore{
+		ttl:       ttl,
+		records:   make(map[string]Record),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *idempotencyStore) Reserve(key string, requestHash [32]byte) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if r, ok := s.records[key]; ok && now.Before(r.ExpiresAt) {
+		return r, true
+	}
+
+	r := Record{
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	s.records[key] = r
+	return r, false
+}
+
+func (s *idempotencyStore) Save(key string, response proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	if !ok {
+		return
+	}
+	r.Response = proto.Clone(response)
+	r.ExpiresAt = s.now().Add(s.ttl)
+	s.records[key] = r
+}
+
+func (s *idempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
+
+// Remove expired records. Must be called with lock held.
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for key, r := range s.records {
+		if !now.Before(r.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var requestHash = sha256.Sum256([]byte("request"))
+
+func TestReserve_NewKey_ShouldReserve(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	record, exists := store.Reserve("key", requestHash)
+
+	assert.False(t, exists)
+	assert.Equal(t, requestHash, record.RequestHash)
+	assert.Nil(t, record.Response)
+}
+
+func TestReserve_SavedKey_ShouldReturnResponse(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	// arrange
+	store.Reserve("key", requestHash)
+	store.Save("key", &proto.CreateUserResponse{Id: "test"})
+
+	// act
+	record, exists := store.Reserve("key", requestHash)
+
+	// assert
+	assert.True(t, exists)
+	assert.Equal(t, "test", record.Response.(*proto.CreateUserResponse).Id)
+}
+
+func TestReserve_ReleasedKey_ShouldReserveAgain(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	// arrange
+	store.Reserve("key", requestHash)
+	store.Release("key")
+
+	// act
+	_, exists := store.Reserve("key", requestHash)
+
+	// assert
+	assert.False(t, exists)
+}
+
+func TestReserve_ExpiredKey_ShouldReserveAgain(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// arrange
+	store.Reserve("key", requestHash)
+	store.Save("key", &proto.CreateUserResponse{Id: "test"})
+	store.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	// act
+	record, exists := store.Reserve("key", requestHash)
+
+	// assert
+	assert.False(t, exists)
+	assert.Nil(t, record.Response)
+	assert.Len(t, store.records, 1)
+}
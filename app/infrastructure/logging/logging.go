@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FormatJson    = "json"
+	FormatConsole = "console"
+)
+
+type Config struct {
+	// trace, debug, info (default), warn or error
+	Level string
+	// json (default) or console
+	Format string
+}
+
+// Configure global logger. Emails and passwords are redacted from
+// every log line. Logger is also used as default for contexts without
+// request scoped logger.
+func Setup(c Config) error {
+	logger, err := New(c, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	log.Logger = logger
+	zerolog.DefaultContextLogger = &log.Logger
+	return nil
+}
+
+// Create logger that writes redacted lines to out.
+func New(c Config, out io.Writer) (zerolog.Logger, error) {
+	level := zerolog.InfoLevel
+	if c.Level != "" {
+		l, err := zerolog.ParseLevel(c.Level)
+		if err != nil {
+			return zerolog.Logger{}, fmt.Errorf("unsupported log level %v", c.Level)
+		}
+		level = l
+	}
+
+	var w io.Writer
+	switch c.Format {
+	case "", FormatJson:
+		w = NewRedactingWriter(out)
+	case FormatConsole:
+		// redact before the line is formatted for console
+		w = NewRedactingWriter(zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339})
+	default:
+		return zerolog.Logger{}, fmt.Errorf("unsupported log format %v", c.Format)
+	}
+
+	return zerolog.New(w).Level(level).With().Timestamp().Logger(), nil
+}
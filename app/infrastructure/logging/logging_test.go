@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_JsonFormat_ShouldRedactEmails(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Format: FormatJson}, &buf)
+	assert.Nil(t, err)
+
+	logger.Info().Str("email", "john@example.com").Msg("user created")
+
+	assert.Contains(t, buf.String(), `"email":"j***@example.com"`)
+	assert.NotContains(t, buf.String(), "john@example.com")
+}
+
+func TestNew_ConsoleFormat_ShouldRedactPasswords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Format: FormatConsole}, &buf)
+	assert.Nil(t, err)
+
+	logger.Info().Str("password", "secret").Msg("user created")
+
+	assert.Contains(t, buf.String(), "[REDACTED]")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestNew_Level_ShouldSkipLowerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "warn"}, &buf)
+	assert.Nil(t, err)
+
+	logger.Info().Msg("skipped")
+	logger.Warn().Msg("logged")
+
+	assert.NotContains(t, buf.String(), "skipped")
+	assert.Contains(t, buf.String(), "logged")
+}
+
+func TestNew_UnsupportedLevel_ShouldReturnErr(t *testing.T) {
+	_, err := New(Config{Level: "loud"}, &bytes.Buffer{})
+
+	assert.NotNil(t, err)
+}
+
+func TestNew_UnsupportedFormat_ShouldReturnErr(t *testing.T) {
+	_, err := New(Config{Format: "xml"}, &bytes.Buffer{})
+
+	assert.NotNil(t, err)
+}
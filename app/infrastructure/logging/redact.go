@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"io"
+	"regexp"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// json field whose name contains password, with string value
+	passwordPattern = regexp.MustCompile(`("[^"]*(?i:password)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// Writer that masks emails and password fields in every log line
+// before it is written to the underlying writer.
+type redactingWriter struct {
+	out io.Writer
+}
+
+func NewRedactingWriter(out io.Writer) io.Writer {
+	return &redactingWriter{out: out}
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(Redact(p)); err != nil {
+		return 0, err
+	}
+	// report the original length, zerolog treats shorter writes as errors
+	return len(p), nil
+}
+
+// Mask emails, keeping first letter and domain, and replace
+// values of password fields.
+func Redact(p []byte) []byte {
+	p = passwordPattern.ReplaceAll(p, []byte(`$1"[REDACTED]"`))
+	return emailPattern.ReplaceAll(p, []byte(`$1***@$2`))
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact_Email_ShouldBeMasked(t *testing.T) {
+	line := []byte(`{"level":"error","error":"Key (email)=(john.doe@example.com) already exists"}`)
+
+	res := Redact(line)
+
+	assert.Equal(t, `{"level":"error","error":"Key (email)=(j***@example.com) already exists"}`, string(res))
+}
+
+func TestRedact_PasswordField_ShouldBeReplaced(t *testing.T) {
+	line := []byte(`{"level":"info","password":"se\"cret","new_password": "x","nickname":"john"}`)
+
+	res := Redact(line)
+
+	assert.Equal(t, `{"level":"info","password":"[REDACTED]","new_password": "[REDACTED]","nickname":"john"}`, string(res))
+}
+
+func TestRedact_NoPII_ShouldNotChange(t *testing.T) {
+	line := []byte(`{"level":"info","message":"user with id 1b4e28ba successfully updated"}`)
+
+	assert.Equal(t, string(line), string(Redact(line)))
+}
+
+func TestRedactingWriter_ShouldReportOriginalLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRedactingWriter(&buf)
+	line := []byte(`{"email":"john.doe@example.com"}`)
+
+	n, err := w.Write(line)
+
+	assert.Nil(t, err)
+	assert.Equal(t, len(line), n)
+	assert.Equal(t, `{"email":"j***@example.com"}`, buf.String())
+}
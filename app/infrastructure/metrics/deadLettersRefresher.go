@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Source of the dead letter counts by transport.
+type DeadLetterCounter interface {
+	CountByTransport(ctx context.Context) (map[string]int64, error)
+}
+
+// Refresh dead letters gauge every interval until the context is
+// done. Counts are loaded immediately on start.
+func RefreshDeadLetters(ctx context.Context, counter DeadLetterCounter, interval time.Duration) {
+	refreshEvery(ctx, interval, func(ctx context.Context) {
+		refreshDeadLetters(ctx, counter)
+	})
+}
+
+func refreshDeadLetters(ctx context.Context, counter DeadLetterCounter) {
+	counts, err := counter.CountByTransport(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot refresh dead letters metric")
+		return
+	}
+
+	// reset so the replayed transports disappear
+	DeadLetters.Reset()
+	for transport, count := range counts {
+		DeadLetters.WithLabelValues(transport).Set(float64(count))
+	}
+}
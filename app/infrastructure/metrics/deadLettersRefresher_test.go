@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDeadLetterCounter struct {
+	counts map[string]int64
+}
+
+func (c *fakeDeadLetterCounter) CountByTransport(ctx context.Context) (map[string]int64, error) {
+	return c.counts, nil
+}
+
+func TestRefreshDeadLetters_TransportReplayed_ShouldBeRemoved(t *testing.T) {
+	refreshDeadLetters(context.Background(),
+		&fakeDeadLetterCounter{counts: map[string]int64{"rabbitmq": 3, "kafka": 1}})
+
+	refreshDeadLetters(context.Background(),
+		&fakeDeadLetterCounter{counts: map[string]int64{"rabbitmq": 2}})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(DeadLetters))
+	assert.Equal(t, float64(2), testutil.ToFloat64(DeadLetters.WithLabelValues("rabbitmq")))
+}
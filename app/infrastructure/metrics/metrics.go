@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "usermanager"
+
+// Registry with all service metrics, exposed on /metrics endpoint.
+var Registry = prometheus.NewRegistry()
+
+var (
+	RpcHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_server_handled_total",
+		Help:      "Number of RPCs completed on the server, by method and status code.",
+	}, []string{"method", "code"})
+
+	RpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_server_handling_seconds",
+		Help:      "Latency of RPCs handled by the server, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	NotificationsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_published_total",
+		Help:      "Number of user change notifications published, by transport.",
+	}, []string{"transport"})
+
+	NotificationsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_failed_total",
+		Help:      "Number of user change notifications that failed to publish, by transport.",
+	}, []string{"transport"})
+
+	NotificationsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "notifications_queued",
+		Help:      "Number of user change notifications waiting for the publishers.",
+	})
+
+	NotificationsSpooled = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "notifications_spooled",
+		Help:      "Number of user change notifications in the spool file, waiting for rabbit.",
+	})
+
+	DeadLetters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "notification_dead_letters",
+		Help:      "Number of user change notifications waiting for replay in the dead letter table, by transport.",
+	}, []string{"transport"})
+
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts, by result (success or failure).",
+	}, []string{"result"})
+
+	UsersByCountry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "users",
+		Help:      "Total number of users, by country.",
+	}, []string{"country"})
+
+	UserCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_cache_requests_total",
+		Help:      "Number of user cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RpcHandled,
+		RpcDuration,
+		NotificationsPublished,
+		NotificationsFailed,
+		NotificationsQueued,
+		NotificationsSpooled,
+		DeadLetters,
+		WebhookDeliveries,
+		UsersByCountry,
+		UserCacheRequests,
+	)
+}
+
+// Register connection pool stats of the database.
+func RegisterDB(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Handler that serves metrics in prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
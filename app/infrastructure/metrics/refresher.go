@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultRefreshInterval = time.Minute
+
+// Call refresh every interval until the context is done, the first
+// time immediately on start. Every call gets a context that times out
+// after the interval, so a slow refresh never overlaps the next one.
+func refreshEvery(ctx context.Context, interval time.Duration, refresh func(ctx context.Context)) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, interval)
+		refresh(refreshCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
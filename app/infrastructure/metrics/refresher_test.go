@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshEvery_ShouldRefreshOnStartWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan bool, 1)
+
+	// act
+	go refreshEvery(ctx, time.Hour, func(ctx context.Context) {
+		_, ok := ctx.Deadline()
+		refreshed <- ok
+	})
+
+	// assert
+	select {
+	case hasDeadline := <-refreshed:
+		assert.True(t, hasDeadline)
+	case <-time.After(time.Second):
+		t.Fatal("refresh wasn't called on start")
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Source of the user counts by country.
+type UserCounter interface {
+	CountByCountry(ctx context.Context) (map[string]int64, error)
+}
+
+// Refresh users by country gauge every interval until the context is
+// done. Counts are loaded immediately on start.
+func RefreshUsersByCountry(ctx context.Context, counter UserCounter, interval time.Duration) {
+	refreshEvery(ctx, interval, func(ctx context.Context) {
+		refreshUsersByCountry(ctx, counter)
+	})
+}
+
+func refreshUsersByCountry(ctx context.Context, counter UserCounter) {
+	counts, err := counter.CountByCountry(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot refresh users by country metric")
+		return
+	}
+
+	// reset so the countries without users disappear
+	UsersByCountry.Reset()
+	for country, count := range counts {
+		UsersByCountry.WithLabelValues(country).Set(float64(count))
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCounter struct {
+	counts map[string]int64
+	err    error
+}
+
+func (c *fakeCounter) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	return c.counts, c.err
+}
+
+func TestRefreshUsersByCountry_ShouldSetGauges(t *testing.T) {
+	counter := &fakeCounter{counts: map[string]int64{"RS": 3, "DE": 1}}
+
+	refreshUsersByCountry(context.Background(), counter)
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(UsersByCountry.WithLabelValues("RS")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(UsersByCountry.WithLabelValues("DE")))
+}
+
+func TestRefreshUsersByCountry_CountryWithoutUsers_ShouldBeRemoved(t *testing.T) {
+	refreshUsersByCountry(context.Background(), &fakeCounter{counts: map[string]int64{"RS": 3, "DE": 1}})
+
+	refreshUsersByCountry(context.Background(), &fakeCounter{counts: map[string]int64{"RS": 2}})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(UsersByCountry))
+	assert.Equal(t, float64(2), testutil.ToFloat64(UsersByCountry.WithLabelValues("RS")))
+}
+
+func TestRefreshUsersByCountry_CounterErr_ShouldKeepPreviousValues(t *testing.T) {
+	refreshUsersByCountry(context.Background(), &fakeCounter{counts: map[string]int64{"RS": 3}})
+
+	refreshUsersByCountry(context.Background(), &fakeCounter{err: errors.New("db error")})
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(UsersByCountry.WithLabelValues("RS")))
+}
+
+func TestRefreshUsersByCountryLoop_ContextDone_ShouldReturn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		RefreshUsersByCountry(ctx, &fakeCounter{counts: map[string]int64{}}, time.Millisecond)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresher didn't stop")
+	}
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
@@ -10,6 +12,6 @@ type NotificationServiceMock struct {
 }
 
 // this mock will only record that the method was called
-func (n *NotificationServiceMock) NotifyAboutUserChange(userId uuid.UUID) {
-	_ = n.Called(userId)
+func (n *NotificationServiceMock) NotifyAboutUserChange(ctx context.Context, userId uuid.UUID) {
+	_ = n.Called(ctx, userId)
 }
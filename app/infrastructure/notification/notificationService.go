@@ -1,29 +1,58 @@
 package notif
 
 import (
-	"usermanager/app/infrastructure/rabbit"
+	"context"
+	"time"
+	"usermanager/app/infrastructure/metrics"
+	"usermanager/app/infrastructure/publisher"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type NotificationService interface {
-	NotifyAboutUserChange(userId uuid.UUID)
+	NotifyAboutUserChange(ctx context.Context, userId uuid.UUID)
 }
 
 type notificationService struct {
-	rmq *rabbit.RMQ
+	publisher publisher.Publisher
+	// deadline of one publish, zero means no deadline
+	timeout time.Duration
 }
 
-func NewNotificationService(rmqPublisher *rabbit.RMQ) *notificationService {
+func NewNotificationService(p publisher.Publisher, timeout time.Duration) *notificationService {
 	return &notificationService{
-		rmq: rmqPublisher,
+		publisher: p,
+		timeout:   timeout,
 	}
 }
 
-// This function will push user id to channel where on other side will
-// be a running goroutine that publish messages to a rabbit queue.
-// On the other side of the queue are subscribed listeners (services)
-// that are interested about updated users.
-func (n *notificationService) NotifyAboutUserChange(userId uuid.UUID) {
-	n.rmq.PublishChannel <- userId
+// This function will publish user id to the configured transports
+// (rabbit, nats, kafka, webhook...). On the other side are subscribed
+// listeners (services) that are interested about updated users. Only
+// the span context is taken from ctx, so publishing is not canceled
+// with the request, it is bounded by the publish timeout instead.
+func (n *notificationService) NotifyAboutUserChange(ctx context.Context, userId uuid.UUID) {
+	// queued until every publisher is done with the message
+	metrics.NotificationsQueued.Inc()
+	defer metrics.NotificationsQueued.Dec()
+
+	msg := publisher.Message{
+		Id:          uuid.New(),
+		Time:        time.Now(),
+		UserId:      userId,
+		SpanContext: trace.SpanContextFromContext(ctx),
+	}
+
+	publishCtx := context.Background()
+	if n.timeout > 0 {
+		var cancel context.CancelFunc
+		publishCtx, cancel = context.WithTimeout(publishCtx, n.timeout)
+		defer cancel()
+	}
+
+	if err := n.publisher.Publish(publishCtx, msg); err != nil {
+		log.Error().Err(err).Msgf("cannot publish notification for user %v", userId)
+	}
 }
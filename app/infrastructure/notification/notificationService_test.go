@@ -0,0 +1,58 @@
+package notif
+
+import (
+	"context"
+	"testing"
+	"time"
+	"usermanager/app/infrastructure/publisher"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNotifyAboutUserChange_ShouldPublishWithSpanContext(t *testing.T) {
+	bus := publisher.NewMemoryBus()
+	messages, unsubscribe := bus.Subscribe(1)
+	defer unsubscribe()
+
+	// arrange
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx, cancel := context.WithCancel(trace.ContextWithSpanContext(context.Background(), spanContext))
+	cancel()
+	userId := uuid.New()
+
+	// act
+	NewNotificationService(bus, time.Minute).NotifyAboutUserChange(ctx, userId)
+
+	// assert
+	msg := <-messages
+	assert.Equal(t, userId, msg.UserId)
+	assert.Equal(t, spanContext, msg.SpanContext)
+}
+
+// publisher that records the deadline of the publish ctx
+type deadlinePublisher struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (p *deadlinePublisher) Publish(ctx context.Context, msg publisher.Message) error {
+	p.deadline, p.ok = ctx.Deadline()
+	return nil
+}
+
+func TestNotifyAboutUserChange_ShouldPublishWithTimeout(t *testing.T) {
+	p := &deadlinePublisher{}
+	before := time.Now()
+
+	// act
+	NewNotificationService(p, time.Minute).NotifyAboutUserChange(context.Background(), uuid.New())
+
+	// assert
+	assert.True(t, p.ok)
+	assert.WithinDuration(t, before.Add(time.Minute), p.deadline, time.Second)
+}
@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"context"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/metrics"
+	repo "usermanager/app/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dead letter is stored even when the publish ctx is done
+const deadLetterTimeout = 5 * time.Second
+
+type deadLetterPublisher struct {
+	transport string
+	publisher Publisher
+	repo      repo.DeadLetterRepo
+}
+
+// Create publisher that stores messages the transport publisher
+// failed to publish as dead letters, so they can be replayed later.
+func NewDeadLetterPublisher(transport string, p Publisher, r repo.DeadLetterRepo) *deadLetterPublisher {
+	return &deadLetterPublisher{
+		transport: transport,
+		publisher: p,
+		repo:      r,
+	}
+}
+
+// Publish the message. Returns the publish error, also when the
+// message was stored as dead letter.
+func (d *deadLetterPublisher) Publish(ctx context.Context, msg Message) error {
+	err := d.publisher.Publish(ctx, msg)
+	if err == nil {
+		return nil
+	}
+
+	letter := domain.DeadLetter{
+		Id:          uuid.New(),
+		Transport:   d.transport,
+		UserId:      msg.UserId,
+		TraceParent: TraceParent(msg.SpanContext),
+		EventId:     msg.Id,
+		Error:       err.Error(),
+		Attempts:    1,
+	}
+	if !msg.Time.IsZero() {
+		letter.EventTime = &msg.Time
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+	if storeErr := d.repo.Add(storeCtx, letter); storeErr != nil {
+		log.Error().Err(storeErr).Msgf("cannot store dead letter for user %v, notification is lost", msg.UserId)
+		return err
+	}
+
+	metrics.DeadLetters.WithLabelValues(d.transport).Inc()
+	log.Warn().Err(err).Msgf("notification for user %v stored as dead letter %v", msg.UserId, letter.Id)
+	return err
+}
+
+// W3C traceparent of the span context, empty if it is not valid.
+func TraceParent(sc trace.SpanContext) string {
+	if !sc.IsValid() {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	return carrier.Get("traceparent")
+}
+
+// Span context from W3C traceparent, invalid if it can't be parsed.
+func SpanContextFromTraceParent(traceParent string) trace.SpanContext {
+	carrier := propagation.MapCarrier{"traceparent": traceParent}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
@@ -0,0 +1,57 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	repoMock "usermanager/app/infrastructure/repositories/mocks"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDeadLetterPublisher_PublishFailed_ShouldStoreDeadLetter(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	errUnavailable := errors.New("broker unavailable")
+	p := NewDeadLetterPublisher(TransportKafka, &fakePublisher{err: errUnavailable}, mockedRepo)
+
+	// arrange
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	msg := Message{Id: uuid.New(), Time: time.Now(), UserId: uuid.New(), SpanContext: spanContext}
+	var stored domain.DeadLetter
+	mockedRepo.
+		On("Add", mock.Anything, mock.AnythingOfType("domain.DeadLetter")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(domain.DeadLetter) }).
+		Return(nil)
+
+	// act
+	err := p.Publish(context.Background(), msg)
+
+	// assert
+	assert.Equal(t, errUnavailable, err)
+	assert.Equal(t, TransportKafka, stored.Transport)
+	assert.Equal(t, msg.UserId, stored.UserId)
+	assert.Equal(t, msg.Id, stored.EventId)
+	assert.Equal(t, msg.Time, *stored.EventTime)
+	assert.Equal(t, "broker unavailable", stored.Error)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, spanContext.TraceID(), SpanContextFromTraceParent(stored.TraceParent).TraceID())
+}
+
+func TestDeadLetterPublisher_Published_ShouldNotStoreDeadLetter(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	p := NewDeadLetterPublisher(TransportNats, &fakePublisher{messages: make(chan Message, 1)}, mockedRepo)
+
+	err := p.Publish(context.Background(), Message{UserId: uuid.New()})
+
+	assert.Nil(t, err)
+	mockedRepo.AssertNotCalled(t, "Add", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+type fanout struct {
+	publishers []Publisher
+}
+
+// Create publisher that publishes every message to all publishers
+// at once, so a slow transport doesn't delay the others.
+func NewFanout(publishers ...Publisher) *fanout {
+	return &fanout{publishers: publishers}
+}
+
+// Publish to all publishers. Returns error with every
+// failure if any of them failed.
+func (f *fanout) Publish(ctx context.Context, msg Message) error {
+	errs := make([]error, len(f.publishers))
+
+	var wg sync.WaitGroup
+	for i, p := range f.publishers {
+		wg.Add(1)
+		go func(i int, p Publisher) {
+			defer wg.Done()
+			errs[i] = p.Publish(ctx, msg)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var failed FanoutError
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// Errors of the publishers that failed.
+type FanoutError []error
+
+func (e FanoutError) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Lets errors.Is check every failure.
+func (e FanoutError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lets errors.As check every failure, the first match is used.
+func (e FanoutError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// publisher that records messages or fails
+type fakePublisher struct {
+	messages chan Message
+	err      error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages <- msg
+	return nil
+}
+
+func TestFanout_ShouldPublishToAll(t *testing.T) {
+	first := &fakePublisher{messages: make(chan Message, 1)}
+	second := &fakePublisher{messages: make(chan Message, 1)}
+	msg := Message{UserId: uuid.New()}
+
+	// act
+	err := NewFanout(first, second).Publish(context.Background(), msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, msg, <-first.messages)
+	assert.Equal(t, msg, <-second.messages)
+}
+
+func TestFanout_SomeFailed_ShouldPublishToOthersAndReturnErr(t *testing.T) {
+	errUnavailable := errors.New("broker unavailable")
+	failed := &fakePublisher{err: errUnavailable}
+	ok := &fakePublisher{messages: make(chan Message, 1)}
+
+	// act
+	err := NewFanout(failed, ok).Publish(context.Background(), Message{UserId: uuid.New()})
+
+	// assert
+	assert.Len(t, ok.messages, 1)
+	assert.True(t, errors.Is(err, errUnavailable))
+	assert.Equal(t, "broker unavailable", err.Error())
+}
+
+// error type of a failed transport
+type transportError struct {
+	transport string
+}
+
+func (e *transportError) Error() string {
+	return e.transport + " failed"
+}
+
+func TestFanout_SomeFailed_ShouldUnwrapErrType(t *testing.T) {
+	failed := &fakePublisher{err: &transportError{transport: "nats"}}
+	ok := &fakePublisher{messages: make(chan Message, 1)}
+
+	// act
+	err := NewFanout(ok, failed).Publish(context.Background(), Message{UserId: uuid.New()})
+
+	// assert
+	var target *transportError
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, "nats", target.transport)
+	assert.False(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,88 @@
+package publisher
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// Kafka publisher settings
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
+
+// Implemented by kafka.Writer.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type kafkaPublisher struct {
+	writer kafkaWriter
+	topic  string
+}
+
+// Create publisher to the kafka topic. Connections to the brokers
+// are opened on the first write.
+func NewKafkaPublisher(c KafkaConfig) *kafkaPublisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(c.Brokers...),
+			Topic:        c.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// messages are written one by one from separate goroutines
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		topic: c.Topic,
+	}
+}
+
+// Publish user id keyed by the user id, so changes of the same user
+// stay in order on one partition. Trace context goes to the headers.
+func (p *kafkaPublisher) Publish(ctx context.Context, msg Message) error {
+	ctx, span := startSpan(ctx, msg, TransportKafka, p.topic)
+	defer span.End()
+
+	var headers kafkaHeadersCarrier
+	otel.GetTextMapPropagator().Inject(ctx, &headers)
+
+	err := p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(msg.UserId.String()),
+		Value:   []byte(msg.UserId.String()),
+		Headers: headers,
+	})
+	return record(TransportKafka, span, err)
+}
+
+// Adapts kafka message headers to otel TextMapCarrier.
+type kafkaHeadersCarrier []kafka.Header
+
+func (c *kafkaHeadersCarrier) Get(key string) string {
+	for _, h := range *c {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *kafkaHeadersCarrier) Set(key string, value string) {
+	for i, h := range *c {
+		if h.Key == key {
+			(*c)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c = append(*c, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c *kafkaHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c))
+	for _, h := range *c {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
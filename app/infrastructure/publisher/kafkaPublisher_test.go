@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeKafkaWriter struct {
+	messages []kafka.Message
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func TestKafkaPublisher_ShouldWriteKeyedMessageWithTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	writer := &fakeKafkaWriter{}
+	p := &kafkaPublisher{writer: writer, topic: "user_changes"}
+	msg := Message{
+		UserId: uuid.New(),
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1, 2, 3},
+			SpanID:     trace.SpanID{4, 5, 6},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+
+	// act
+	err := p.Publish(context.Background(), msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, writer.messages, 1)
+	assert.Equal(t, msg.UserId.String(), string(writer.messages[0].Key))
+	assert.Equal(t, msg.UserId.String(), string(writer.messages[0].Value))
+	headers := kafkaHeadersCarrier(writer.messages[0].Headers)
+	assert.Contains(t, headers.Get("traceparent"), msg.SpanContext.TraceID().String())
+}
@@ -0,0 +1,56 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Message]struct{}
+}
+
+// Create in-process message bus for tests and local development.
+// Messages are delivered only to the current subscribers.
+func NewMemoryBus() *memoryBus {
+	return &memoryBus{subscribers: make(map[chan Message]struct{})}
+}
+
+// Deliver message to every subscriber. Waits for subscribers with
+// full buffer until ctx is done.
+func (b *memoryBus) Publish(ctx context.Context, msg Message) error {
+	ctx, span := startSpan(ctx, msg, TransportMemory, "memory")
+	defer span.End()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			return record(TransportMemory, span, ctx.Err())
+		}
+	}
+	return record(TransportMemory, span, nil)
+}
+
+// Subscribe to messages published from now on. Returned function
+// unsubscribes and closes the channel.
+func (b *memoryBus) Subscribe(buffer int) (<-chan Message, func()) {
+	ch := make(chan Message, buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
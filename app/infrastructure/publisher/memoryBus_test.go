@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBus_ShouldDeliverToSubscribers(t *testing.T) {
+	bus := NewMemoryBus()
+	first, unsubscribeFirst := bus.Subscribe(1)
+	second, unsubscribeSecond := bus.Subscribe(1)
+	defer unsubscribeSecond()
+	msg := Message{UserId: uuid.New()}
+
+	// act
+	unsubscribeFirst()
+	err := bus.Publish(context.Background(), msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, msg, <-second)
+	_, open := <-first
+	assert.False(t, open)
+}
+
+func TestMemoryBus_FullSubscriber_ShouldWaitUntilCtxDone(t *testing.T) {
+	bus := NewMemoryBus()
+	_, unsubscribe := bus.Subscribe(0)
+	defer unsubscribe()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := bus.Publish(ctx, Message{UserId: uuid.New()})
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
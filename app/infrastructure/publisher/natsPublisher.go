@@ -0,0 +1,53 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// NATS publisher settings
+type NatsConfig struct {
+	Url     string
+	Subject string
+}
+
+// Implemented by nats.Conn.
+type natsConn interface {
+	PublishMsg(msg *nats.Msg) error
+}
+
+type natsPublisher struct {
+	conn    natsConn
+	subject string
+}
+
+// Connect to NATS server and create publisher to the subject. If the
+// server is not available, connecting is retried in the background
+// and messages are buffered by the client until it connects.
+func NewNatsPublisher(c NatsConfig) (*natsPublisher, error) {
+	conn, err := nats.Connect(c.Url,
+		nats.Name("usermanager"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nats: %v", err)
+	}
+	return &natsPublisher{conn: conn, subject: c.Subject}, nil
+}
+
+// Publish user id with the trace context in the headers.
+func (p *natsPublisher) Publish(ctx context.Context, msg Message) error {
+	ctx, span := startSpan(ctx, msg, TransportNats, p.subject)
+	defer span.End()
+
+	natsMsg := nats.NewMsg(p.subject)
+	natsMsg.Data = []byte(msg.UserId.String())
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(natsMsg.Header)))
+
+	return record(TransportNats, span, p.conn.PublishMsg(natsMsg))
+}
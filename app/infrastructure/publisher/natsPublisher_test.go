@@ -0,0 +1,41 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNatsConn struct {
+	messages []*nats.Msg
+	err      error
+}
+
+func (c *fakeNatsConn) PublishMsg(msg *nats.Msg) error {
+	c.messages = append(c.messages, msg)
+	return c.err
+}
+
+func TestNatsPublisher_ShouldPublishUserIdToSubject(t *testing.T) {
+	conn := &fakeNatsConn{}
+	p := &natsPublisher{conn: conn, subject: "users.changed"}
+	msg := Message{UserId: uuid.New()}
+
+	err := p.Publish(context.Background(), msg)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "users.changed", conn.messages[0].Subject)
+	assert.Equal(t, msg.UserId.String(), string(conn.messages[0].Data))
+}
+
+func TestNatsPublisher_ConnectionClosed_ShouldReturnErr(t *testing.T) {
+	p := &natsPublisher{conn: &fakeNatsConn{err: nats.ErrConnectionClosed}, subject: "users.changed"}
+
+	err := p.Publish(context.Background(), Message{UserId: uuid.New()})
+
+	assert.True(t, errors.Is(err, nats.ErrConnectionClosed))
+}
@@ -0,0 +1,62 @@
+package publisher
+
+import (
+	"context"
+	"time"
+	"usermanager/app/infrastructure/metrics"
+	"usermanager/app/infrastructure/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported notification transports.
+const (
+	TransportRabbit  = "rabbitmq"
+	TransportNats    = "nats"
+	TransportKafka   = "kafka"
+	TransportWebhook = "webhook"
+	TransportMemory  = "memory"
+)
+
+// User change notification. Span context of the request that caused
+// the change is kept so the publish span joins the same trace.
+type Message struct {
+	// id of the notification and time of the change, used by the
+	// transports that send event metadata
+	Id          uuid.UUID
+	Time        time.Time
+	UserId      uuid.UUID
+	SpanContext trace.SpanContext
+}
+
+// Publishes user change notifications to a transport.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Start producer span of the message, child of the request span.
+func startSpan(ctx context.Context, msg Message, system string, destination string) (context.Context, trace.Span) {
+	ctx = trace.ContextWithSpanContext(ctx, msg.SpanContext)
+	return otel.Tracer("usermanager/publisher").Start(ctx,
+		destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem(system),
+			semconv.MessagingDestinationName(destination),
+		))
+}
+
+// Count published or failed notification of the transport and
+// record the error on the span. Returns the same error.
+func record(transport string, span trace.Span, err error) error {
+	if err != nil {
+		tracing.RecordError(span, err)
+		metrics.NotificationsFailed.WithLabelValues(transport).Inc()
+		return err
+	}
+	metrics.NotificationsPublished.WithLabelValues(transport).Inc()
+	return nil
+}
@@ -0,0 +1,74 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// HTTP webhook publisher settings
+type WebhookConfig struct {
+	Url string
+	// deadline of one request
+	Timeout time.Duration
+}
+
+// JSON body of the webhook request.
+type webhookBody struct {
+	UserId string `json:"user_id"`
+}
+
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// Create publisher that posts every notification to the url.
+func NewWebhookPublisher(c WebhookConfig) *webhookPublisher {
+	return &webhookPublisher{
+		url:    c.Url,
+		client: &http.Client{Timeout: c.Timeout},
+	}
+}
+
+// Post user id as JSON with the trace context in the headers.
+// Any status other than 2xx is an error.
+func (p *webhookPublisher) Publish(ctx context.Context, msg Message) error {
+	ctx, span := startSpan(ctx, msg, TransportWebhook, p.url)
+	defer span.End()
+
+	return record(TransportWebhook, span, p.post(ctx, msg))
+}
+
+func (p *webhookPublisher) post(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(webhookBody{UserId: msg.UserId.String()})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %v", resp.StatusCode)
+	}
+	return nil
+}
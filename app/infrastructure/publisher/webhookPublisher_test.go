@@ -0,0 +1,59 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWebhookPublisher_ShouldPostUserIdWithTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var body webhookBody
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	// arrange
+	msg := Message{
+		UserId: uuid.New(),
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1, 2, 3},
+			SpanID:     trace.SpanID{4, 5, 6},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+
+	// act
+	err := NewWebhookPublisher(WebhookConfig{Url: server.URL, Timeout: time.Second}).
+		Publish(context.Background(), msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, msg.UserId.String(), body.UserId)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Contains(t, header.Get("traceparent"), msg.SpanContext.TraceID().String())
+}
+
+func TestWebhookPublisher_ErrorStatus_ShouldReturnErr(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewWebhookPublisher(WebhookConfig{Url: server.URL, Timeout: time.Second}).
+		Publish(context.Background(), Message{UserId: uuid.New()})
+
+	assert.Equal(t, "webhook responded with status 503", err.Error())
+}
@@ -0,0 +1,121 @@
+package rabbit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// Message formats, see the CloudEvents AMQP protocol binding.
+const (
+	// user id as text/plain body
+	CloudEventsNone = "none"
+	// the whole event as JSON body
+	CloudEventsStructured = "structured"
+	// event data as body, attributes in "cloudEvents:" headers
+	CloudEventsBinary = "binary"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	// type of the user change notification
+	UserUpdatedEventType = "com.usermanager.user.updated"
+
+	cloudEventsContentType   = "application/cloudevents+json"
+	cloudEventsHeaderPrefix  = "cloudEvents:"
+	defaultCloudEventsSource = "/usermanager"
+)
+
+// Structured mode body.
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	Id              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            eventData `json:"data"`
+}
+
+// Event data, the same in both modes.
+type eventData struct {
+	UserId string `json:"user_id"`
+}
+
+// Encodes messages in the configured format.
+type encoding struct {
+	mode   string
+	source string
+}
+
+// Message as amqp publishing. Id and time are set if the message
+// doesn't have them.
+func (e encoding) encode(msg Message) (amqp.Publishing, error) {
+	headers := amqp.Table{}
+	if e.mode != CloudEventsStructured && e.mode != CloudEventsBinary {
+		return amqp.Publishing{
+			Headers:     headers,
+			ContentType: "text/plain",
+			Body:        []byte(msg.UserId.String()),
+		}, nil
+	}
+
+	event := e.event(msg)
+	if e.mode == CloudEventsStructured {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return amqp.Publishing{}, fmt.Errorf("cannot encode cloud event: %w", err)
+		}
+		return amqp.Publishing{
+			Headers:     headers,
+			ContentType: cloudEventsContentType,
+			MessageId:   event.Id,
+			Timestamp:   event.Time,
+			Body:        body,
+		}, nil
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("cannot encode cloud event data: %w", err)
+	}
+	headers[cloudEventsHeaderPrefix+"specversion"] = event.SpecVersion
+	headers[cloudEventsHeaderPrefix+"id"] = event.Id
+	headers[cloudEventsHeaderPrefix+"source"] = event.Source
+	headers[cloudEventsHeaderPrefix+"type"] = event.Type
+	headers[cloudEventsHeaderPrefix+"subject"] = event.Subject
+	headers[cloudEventsHeaderPrefix+"time"] = event.Time.Format(time.RFC3339Nano)
+	return amqp.Publishing{
+		Headers: headers,
+		// datacontenttype maps to the content type property
+		ContentType: event.DataContentType,
+		MessageId:   event.Id,
+		Timestamp:   event.Time,
+		Body:        data,
+	}, nil
+}
+
+func (e encoding) event(msg Message) cloudEvent {
+	id := msg.Id
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	t := msg.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return cloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Id:              id.String(),
+		Source:          e.source,
+		Type:            UserUpdatedEventType,
+		Subject:         msg.UserId.String(),
+		Time:            t.UTC(),
+		DataContentType: "application/json",
+		Data:            eventData{UserId: msg.UserId.String()},
+	}
+}
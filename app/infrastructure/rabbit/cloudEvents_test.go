@@ -0,0 +1,80 @@
+package rabbit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMessage() Message {
+	return Message{
+		Id:     uuid.New(),
+		Time:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		UserId: uuid.New(),
+	}
+}
+
+func TestEncode_None_ShouldPublishUserId(t *testing.T) {
+	msg := testMessage()
+
+	publishing, err := encoding{mode: CloudEventsNone}.encode(msg)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "text/plain", publishing.ContentType)
+	assert.Equal(t, msg.UserId.String(), string(publishing.Body))
+}
+
+func TestEncode_Structured_ShouldPublishWholeEvent(t *testing.T) {
+	msg := testMessage()
+
+	// act
+	publishing, err := encoding{mode: CloudEventsStructured, source: "/usermanager"}.encode(msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "application/cloudevents+json", publishing.ContentType)
+
+	var event map[string]interface{}
+	assert.Nil(t, json.Unmarshal(publishing.Body, &event))
+	assert.Equal(t, "1.0", event["specversion"])
+	assert.Equal(t, msg.Id.String(), event["id"])
+	assert.Equal(t, "/usermanager", event["source"])
+	assert.Equal(t, "com.usermanager.user.updated", event["type"])
+	assert.Equal(t, msg.UserId.String(), event["subject"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", event["time"])
+	assert.Equal(t, "application/json", event["datacontenttype"])
+	assert.Equal(t, map[string]interface{}{"user_id": msg.UserId.String()}, event["data"])
+}
+
+func TestEncode_Binary_ShouldPublishAttributesInHeaders(t *testing.T) {
+	msg := testMessage()
+
+	// act
+	publishing, err := encoding{mode: CloudEventsBinary, source: "/usermanager"}.encode(msg)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", publishing.ContentType)
+	assert.JSONEq(t, `{"user_id": "`+msg.UserId.String()+`"}`, string(publishing.Body))
+	assert.Equal(t, "1.0", publishing.Headers["cloudEvents:specversion"])
+	assert.Equal(t, msg.Id.String(), publishing.Headers["cloudEvents:id"])
+	assert.Equal(t, "/usermanager", publishing.Headers["cloudEvents:source"])
+	assert.Equal(t, "com.usermanager.user.updated", publishing.Headers["cloudEvents:type"])
+	assert.Equal(t, msg.UserId.String(), publishing.Headers["cloudEvents:subject"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", publishing.Headers["cloudEvents:time"])
+	assert.NotContains(t, publishing.Headers, "cloudEvents:datacontenttype")
+}
+
+func TestEncode_MessageWithoutId_ShouldGenerateIdAndTime(t *testing.T) {
+	msg := Message{UserId: uuid.New()}
+
+	publishing, err := encoding{mode: CloudEventsBinary}.encode(msg)
+
+	assert.Nil(t, err)
+	_, err = uuid.Parse(publishing.Headers["cloudEvents:id"].(string))
+	assert.Nil(t, err)
+	assert.False(t, publishing.Timestamp.IsZero())
+}
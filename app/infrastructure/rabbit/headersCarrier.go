@@ -0,0 +1,28 @@
+package rabbit
+
+import (
+	"github.com/streadway/amqp"
+)
+
+// Adapts amqp message headers to otel TextMapCarrier, so the
+// trace context can be injected into the published message.
+type headersCarrier amqp.Table
+
+func (c headersCarrier) Get(key string) string {
+	if v, ok := c[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (c headersCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c headersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
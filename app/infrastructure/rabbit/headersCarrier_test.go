@@ -0,0 +1,38 @@
+package rabbit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeadersCarrier_ShouldPropagateTraceContext(t *testing.T) {
+	propagator := propagation.TraceContext{}
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	// act
+	headers := amqp.Table{}
+	propagator.Inject(trace.ContextWithSpanContext(context.Background(), spanContext), headersCarrier(headers))
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), headersCarrier(headers)))
+
+	// assert
+	assert.Contains(t, headers, "traceparent")
+	assert.Equal(t, spanContext.TraceID(), extracted.TraceID())
+	assert.Equal(t, spanContext.SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsSampled())
+}
+
+func TestHeadersCarrier_NonStringHeader_ShouldBeIgnored(t *testing.T) {
+	carrier := headersCarrier(amqp.Table{"traceparent": 42})
+
+	assert.Equal(t, "", carrier.Get("traceparent"))
+	assert.Equal(t, []string{"traceparent"}, carrier.Keys())
+}
@@ -0,0 +1,256 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"usermanager/app/infrastructure/metrics"
+	"usermanager/app/infrastructure/publisher"
+	"usermanager/app/infrastructure/spool"
+	"usermanager/app/infrastructure/tracing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// always ready, selected while there are spooled messages to drain
+var drainReady = func() chan time.Time {
+	c := make(chan time.Time)
+	close(c)
+	return c
+}()
+
+// Message that cannot be encoded, publishing it again won't help.
+type encodeError struct {
+	err error
+}
+
+func (e encodeError) Error() string { return e.err.Error() }
+
+func (e encodeError) Unwrap() error { return e.err }
+
+// Spooled message, the span context is kept as traceparent.
+type spooledMessage struct {
+	Id          uuid.UUID `json:"id"`
+	Time        time.Time `json:"time"`
+	UserId      uuid.UUID `json:"user_id"`
+	TraceParent string    `json:"traceparent,omitempty"`
+}
+
+// Publishes messages on a single goroutine, which owns the connection.
+// While rabbit is unreachable, or while older messages wait in the
+// spool, messages are appended to the spool, so they are published in
+// order once the connection is restored.
+type producer struct {
+	exchange       string
+	encoding       encoding
+	reconnectDelay time.Duration
+	spool          spool.Spool
+	dial           func() (amqpChannel, error)
+	// nil while disconnected
+	ch     amqpChannel
+	closed chan *amqp.Error
+	// fires the next connection attempt while disconnected
+	retry <-chan time.Time
+}
+
+func newProducer(c Config, dial func() (amqpChannel, error)) *producer {
+	p := &producer{
+		exchange:       c.Exchange,
+		encoding:       encoding{mode: c.CloudEvents, source: c.CloudEventsSource},
+		reconnectDelay: c.ReconnectDelay,
+		spool:          c.Spool,
+		dial:           dial,
+	}
+	p.reportDepth()
+	return p
+}
+
+// Listens on the channel for messages to be sent to the queue,
+// reconnects and drains the spool in between.
+func (p *producer) run(publishChannel <-chan Message) {
+	for {
+		select {
+		case msg := <-publishChannel:
+			err := p.handle(msg)
+			if msg.Result != nil {
+				msg.Result <- err
+			}
+		case err := <-p.closed:
+			log.Error().Err(err).Msg("rabbit connection closed")
+			p.disconnect()
+		case <-p.retry:
+			p.connect()
+		case <-p.drainable():
+			p.drainOne()
+		}
+	}
+}
+
+func (p *producer) connect() {
+	ch, err := p.dial()
+	if err != nil {
+		p.retry = time.After(p.reconnectDelay)
+		return
+	}
+
+	p.ch = ch
+	p.closed = ch.NotifyClose(make(chan *amqp.Error, 1))
+	p.retry = nil
+	log.Info().Msg("rmq ready to send messages")
+}
+
+func (p *producer) disconnect() {
+	if p.ch != nil {
+		p.ch.Close()
+	}
+	p.ch = nil
+	p.closed = nil
+	p.retry = time.After(p.reconnectDelay)
+}
+
+// Publish the message, or spool it if rabbit is unreachable or older
+// messages are still spooled.
+func (p *producer) handle(msg Message) error {
+	if p.spool != nil && (p.ch == nil || p.spool.Depth() > 0) {
+		return p.spoolMessage(msg)
+	}
+	if p.ch == nil {
+		metrics.NotificationsFailed.WithLabelValues(publisher.TransportRabbit).Inc()
+		return errNotConnected
+	}
+
+	if err := p.publish(msg); err != nil {
+		if errors.As(err, &encodeError{}) {
+			return err
+		}
+		p.disconnect()
+		if p.spool != nil {
+			return p.spoolMessage(msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *producer) spoolMessage(msg Message) error {
+	record, err := json.Marshal(spooledMessage{
+		Id:          msg.Id,
+		Time:        msg.Time,
+		UserId:      msg.UserId,
+		TraceParent: publisher.TraceParent(msg.SpanContext),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.spool.Append(record); err != nil {
+		metrics.NotificationsFailed.WithLabelValues(publisher.TransportRabbit).Inc()
+		return fmt.Errorf("cannot spool notification: %w", err)
+	}
+	p.reportDepth()
+
+	log.Warn().Msgf("notification for user %v spooled until rabbit is reachable", msg.UserId)
+	return nil
+}
+
+// Channel that is ready while connected with spooled messages left.
+func (p *producer) drainable() <-chan time.Time {
+	if p.ch == nil || p.spool == nil || p.spool.Depth() == 0 {
+		return nil
+	}
+	return drainReady
+}
+
+// Publish the oldest spooled message. It stays in the spool if
+// publishing fails, unless it cannot be encoded.
+func (p *producer) drainOne() {
+	record, err := p.spool.Peek()
+	if err != nil {
+		log.Error().Err(err).Msg("cannot read spooled notification")
+		return
+	}
+
+	var spooled spooledMessage
+	if err := json.Unmarshal(record, &spooled); err != nil {
+		log.Error().Err(err).Msg("dropping spooled notification that cannot be decoded")
+		p.ack()
+		return
+	}
+
+	msg := Message{
+		Id:          spooled.Id,
+		Time:        spooled.Time,
+		UserId:      spooled.UserId,
+		SpanContext: publisher.SpanContextFromTraceParent(spooled.TraceParent),
+	}
+	if err := p.publish(msg); err != nil {
+		if errors.As(err, &encodeError{}) {
+			log.Error().Err(err).Msg("dropping spooled notification that cannot be encoded")
+			p.ack()
+			return
+		}
+		p.disconnect()
+		return
+	}
+	p.ack()
+}
+
+func (p *producer) ack() {
+	if err := p.spool.Ack(); err != nil && !errors.Is(err, spool.ErrEmpty) {
+		log.Error().Err(err).Msg("cannot remove notification from spool")
+	}
+	p.reportDepth()
+}
+
+func (p *producer) reportDepth() {
+	if p.spool != nil {
+		metrics.NotificationsSpooled.Set(float64(p.spool.Depth()))
+	}
+}
+
+// Publish message with the trace context in the headers,
+// so subscribers can continue the trace.
+func (p *producer) publish(msg Message) error {
+	ctx := trace.ContextWithSpanContext(context.Background(), msg.SpanContext)
+	ctx, span := otel.Tracer("usermanager/rabbit").Start(ctx,
+		p.exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingDestinationName(p.exchange),
+		))
+	defer span.End()
+
+	publishing, err := p.encoding.encode(msg)
+	if err != nil {
+		err = encodeError{err}
+	} else {
+		otel.GetTextMapPropagator().Inject(ctx, headersCarrier(publishing.Headers))
+		err = p.ch.Publish(
+			p.exchange,
+			"",
+			false,
+			false,
+			publishing,
+		)
+	}
+
+	if err != nil {
+		tracing.RecordError(span, err)
+		metrics.NotificationsFailed.WithLabelValues(publisher.TransportRabbit).Inc()
+		log.Error().Err(err).Msg("cannot publish message")
+		return err
+	}
+
+	metrics.NotificationsPublished.WithLabelValues(publisher.TransportRabbit).Inc()
+
+	log.Info().Msgf("notification for user %v successfully published", msg.UserId)
+	return nil
+}
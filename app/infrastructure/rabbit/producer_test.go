@@ -0,0 +1,142 @@
+package rabbit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"usermanager/app/infrastructure/spool"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// channel that records published user ids or fails
+type fakeChannel struct {
+	published []string
+	messages  []amqp.Publishing
+	err       error
+	closed    bool
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.published = append(c.published, string(msg.Body))
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *fakeChannel) NotifyClose(ch chan *amqp.Error) chan *amqp.Error {
+	return ch
+}
+
+func (c *fakeChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+// dial that fails until the channel is set
+type fakeDialer struct {
+	ch *fakeChannel
+}
+
+func (d *fakeDialer) dial() (amqpChannel, error) {
+	if d.ch == nil {
+		return nil, errors.New("connection refused")
+	}
+	return d.ch, nil
+}
+
+func createProducer(t *testing.T) (*producer, *fakeDialer) {
+	s, err := spool.NewSpool(spool.Config{Path: filepath.Join(t.TempDir(), "notifications.spool")})
+	assert.Nil(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	dialer := &fakeDialer{}
+	p := newProducer(Config{Exchange: "users", ReconnectDelay: time.Hour, Spool: s}, dialer.dial)
+	p.connect()
+	return p, dialer
+}
+
+func TestProducer_Disconnected_ShouldSpoolAndDrainInOrder(t *testing.T) {
+	p, dialer := createProducer(t)
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	// arrange, first two while rabbit is down
+	assert.Nil(t, p.handle(Message{UserId: ids[0]}))
+	assert.Nil(t, p.handle(Message{UserId: ids[1]}))
+	dialer.ch = &fakeChannel{}
+	p.connect()
+
+	// act, new message while the spool is not drained yet
+	assert.Nil(t, p.handle(Message{UserId: ids[2]}))
+	for p.drainable() != nil {
+		p.drainOne()
+	}
+
+	// assert
+	assert.Equal(t, []string{ids[0].String(), ids[1].String(), ids[2].String()}, dialer.ch.published)
+	assert.Equal(t, 0, p.spool.Depth())
+}
+
+func TestProducer_SpooledCloudEvent_ShouldKeepIdAndTime(t *testing.T) {
+	p, dialer := createProducer(t)
+	p.encoding = encoding{mode: CloudEventsBinary, source: "/usermanager"}
+	msg := Message{Id: uuid.New(), Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), UserId: uuid.New()}
+
+	// act
+	assert.Nil(t, p.handle(msg))
+	dialer.ch = &fakeChannel{}
+	p.connect()
+	p.drainOne()
+
+	// assert
+	assert.Len(t, dialer.ch.messages, 1)
+	headers := dialer.ch.messages[0].Headers
+	assert.Equal(t, msg.Id.String(), headers["cloudEvents:id"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", headers["cloudEvents:time"])
+}
+
+func TestProducer_PublishFailed_ShouldSpoolAndReconnect(t *testing.T) {
+	p, dialer := createProducer(t)
+	dialer.ch = &fakeChannel{err: errors.New("channel closed")}
+	p.connect()
+
+	// act
+	err := p.handle(Message{UserId: uuid.New()})
+
+	// assert
+	assert.Nil(t, err)
+	assert.True(t, dialer.ch.closed)
+	assert.Nil(t, p.ch)
+	assert.NotNil(t, p.retry)
+	assert.Equal(t, 1, p.spool.Depth())
+}
+
+func TestProducer_EncodeFailed_ShouldNotSpoolOrReconnect(t *testing.T) {
+	p, dialer := createProducer(t)
+	p.encoding = encoding{mode: CloudEventsStructured, source: "/usermanager"}
+	dialer.ch = &fakeChannel{}
+	p.connect()
+
+	// act, json cannot encode years after 9999
+	err := p.handle(Message{Id: uuid.New(), Time: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), UserId: uuid.New()})
+
+	// assert
+	assert.NotNil(t, err)
+	assert.False(t, dialer.ch.closed)
+	assert.NotNil(t, p.ch)
+	assert.Equal(t, 0, p.spool.Depth())
+}
+
+func TestProducer_DisconnectedWithoutSpool_ShouldReturnErr(t *testing.T) {
+	p := newProducer(Config{Exchange: "users", ReconnectDelay: time.Hour}, (&fakeDialer{}).dial)
+	p.connect()
+
+	err := p.handle(Message{UserId: uuid.New()})
+
+	assert.Equal(t, errNotConnected, err)
+}
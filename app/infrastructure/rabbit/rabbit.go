@@ -1,89 +1,212 @@
 package rabbit
 
 import (
-	"usermanager/app/config"
+	"context"
+	"errors"
+	"time"
+	"usermanager/app/infrastructure/publisher"
+	"usermanager/app/infrastructure/spool"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	defaultReconnectDelay = 5 * time.Second
+	defaultConfirmTimeout = 5 * time.Second
+)
+
+var (
+	errNotConnected = errors.New("not connected to rabbit")
+	// broker refused the message
+	errNacked = errors.New("rabbit did not accept the message")
+	// no confirmation in time, the message may or may not be stored
+	errConfirmTimeout = errors.New("rabbit did not confirm the message in time")
+	errChannelClosed  = errors.New("rabbit channel closed before the message was confirmed")
+)
+
+// Rabbit producer settings
+type Config struct {
+	Url      string
+	Exchange string
+	// delay between connection attempts while rabbit is unreachable
+	ReconnectDelay time.Duration
+	// how long publish waits for the broker to confirm the message
+	ConfirmTimeout time.Duration
+	// buffers messages while rabbit is unreachable, without it they
+	// fail to publish
+	Spool spool.Spool
+	// none, structured or binary, see CloudEvents
+	CloudEvents string
+	// source attribute of the cloud events
+	CloudEventsSource string
+}
+
 type RMQ struct {
-	PublishChannel chan uuid.UUID
+	PublishChannel chan Message
 }
 
-// Create rabbitMQ connector and producer. Returns the channel where
-// messages should be pushed in order to be published to rabbit queue.
-func NewRMQ() *RMQ {
-	publishChannel := make(chan uuid.UUID)
-	initProducer(publishChannel)
+// Message to be published. Span context of the request that caused
+// the change is kept so the publish span joins the same trace.
+type Message struct {
+	Id          uuid.UUID
+	Time        time.Time
+	UserId      uuid.UUID
+	SpanContext trace.SpanContext
+	// receives the publish error if set
+	Result chan<- error
+}
+
+// Create rabbitMQ connector and producer that publishes to the fanout
+// exchange. The producer keeps reconnecting while rabbit is unreachable.
+// Returns the channel where messages should be pushed in order to be
+// published to rabbit queue.
+func NewRMQ(c Config) *RMQ {
+	if c.Url == "" {
+		log.Printf("Rabbit url is not defined")
+	}
+	if c.ReconnectDelay <= 0 {
+		c.ReconnectDelay = defaultReconnectDelay
+	}
+	if c.ConfirmTimeout <= 0 {
+		c.ConfirmTimeout = defaultConfirmTimeout
+	}
+	if c.CloudEventsSource == "" {
+		c.CloudEventsSource = defaultCloudEventsSource
+	}
+
+	p := newProducer(c, dial(c.Url, c.Exchange, c.ConfirmTimeout))
+	p.connect()
+
+	publishChannel := make(chan Message)
+	go p.run(publishChannel)
 
 	return &RMQ{
 		PublishChannel: publishChannel,
 	}
 }
 
-// Create rmq connection, open a channel and declare queue.
-// If everything is done successfully, a new goroutine is started
-// that listens to the channel where messages for publish are sent.
-func initProducer(publishChannel chan uuid.UUID) {
-	if config.EnvConfig.RabbitUrl == "" {
-		log.Printf("Rabbit url is not defined")
+// Hand the message over to the producer goroutine and wait until it
+// is published or spooled. Returns the publish error, or ctx error if
+// ctx is done first.
+func (r *RMQ) Publish(ctx context.Context, msg publisher.Message) error {
+	result := make(chan error, 1)
+	select {
+	case r.PublishChannel <- Message{Id: msg.Id, Time: msg.Time, UserId: msg.UserId, SpanContext: msg.SpanContext, Result: result}:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 
-	conn, err := amqp.Dial(config.EnvConfig.RabbitUrl)
-	if err != nil {
-		log.Error().Err(err).Msg("cannot connect to rabbit")
-		return
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		log.Error().Err(err).Msg("cannot open rabbit channel")
-		return
+// Part of the amqp channel used by the producer.
+type amqpChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Channel that closes its connection too.
+type connChannel struct {
+	*amqp.Channel
+	conn *amqp.Connection
+}
+
+func (c *connChannel) Close() error {
+	c.Channel.Close()
+	return c.conn.Close()
+}
+
+// Channel in confirm mode. Publish returns once the broker confirmed
+// the message, so a message is never considered published, and removed
+// from the spool, before the broker has it.
+type confirmedChannel struct {
+	amqpChannel
+	confirms <-chan amqp.Confirmation
+	timeout  time.Duration
+	// delivery tag of the last published message
+	published uint64
+}
+
+func (c *confirmedChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if err := c.amqpChannel.Publish(exchange, key, mandatory, immediate, msg); err != nil {
+		return err
 	}
+	c.published++
 
-	err = ch.ExchangeDeclare(
-		config.EnvConfig.NotificationQueue, // name
-		"fanout",                           // type
-		true,                               // durable
-		false,                              // auto-deleted
-		false,                              // internal
-		false,                              // no-wait
-		nil,                                // arguments
-	)
-
-	if err != nil {
-		log.Error().Err(err).Msg("cannot decalare rabbit exchange")
-		return
+	timeout := time.NewTimer(c.timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case confirm, ok := <-c.confirms:
+			if !ok {
+				return errChannelClosed
+			}
+			// confirmation of an earlier message that timed out
+			if confirm.DeliveryTag < c.published {
+				continue
+			}
+			if !confirm.Ack {
+				return errNacked
+			}
+			return nil
+		case <-timeout.C:
+			return errConfirmTimeout
+		}
 	}
+}
 
-	log.Info().Msg("rmq ready to send messages")
+// Create rmq connection, open a channel in confirm mode and declare
+// the exchange.
+func dial(url string, exchange string, confirmTimeout time.Duration) func() (amqpChannel, error) {
+	return func() (amqpChannel, error) {
+		conn, err := amqp.Dial(url)
+		if err != nil {
+			log.Error().Err(err).Msg("cannot connect to rabbit")
+			return nil, err
+		}
 
-	// start the listener process who will listen on publishChanell
-	// for messages to be pushed to queue
-	go listenForMessages(ch, publishChannel)
-}
+		ch, err := conn.Channel()
+		if err != nil {
+			log.Error().Err(err).Msg("cannot open rabbit channel")
+			conn.Close()
+			return nil, err
+		}
 
-// listens on the channel for messages to be sent to the queue
-func listenForMessages(ch *amqp.Channel, publishChannel chan uuid.UUID) {
-	for userId := range publishChannel {
-		err := ch.Publish(
-			config.EnvConfig.NotificationQueue,
-			"",
-			false,
-			false,
-			amqp.Publishing{
-				ContentType: "text/plain",
-				Body:        []byte(userId.String()),
-			},
+		err = ch.ExchangeDeclare(
+			exchange, // name
+			"fanout", // type
+			true,     // durable
+			false,    // auto-deleted
+			false,    // internal
+			false,    // no-wait
+			nil,      // arguments
 		)
 
 		if err != nil {
-			log.Error().Err(err).Msg("cannot publish message")
-			continue
+			log.Error().Err(err).Msg("cannot decalare rabbit exchange")
+			conn.Close()
+			return nil, err
+		}
+
+		if err := ch.Confirm(false); err != nil {
+			log.Error().Err(err).Msg("cannot put rabbit channel in confirm mode")
+			conn.Close()
+			return nil, err
 		}
 
-		log.Info().Msgf("notification for user %v successfully published", userId)
+		return &confirmedChannel{
+			amqpChannel: &connChannel{Channel: ch, conn: conn},
+			confirms:    ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+			timeout:     confirmTimeout,
+		}, nil
 	}
 }
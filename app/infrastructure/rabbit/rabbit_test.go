@@ -0,0 +1,73 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func confirmed(ch *fakeChannel, confirms ...amqp.Confirmation) *confirmedChannel {
+	c := make(chan amqp.Confirmation, len(confirms))
+	for _, confirm := range confirms {
+		c <- confirm
+	}
+	return &confirmedChannel{amqpChannel: ch, confirms: c, timeout: 10 * time.Millisecond}
+}
+
+func TestConfirmedPublish_Acked_ShouldPass(t *testing.T) {
+	ch := confirmed(&fakeChannel{}, amqp.Confirmation{DeliveryTag: 1, Ack: true})
+
+	err := ch.Publish("users", "", false, false, amqp.Publishing{})
+
+	assert.Nil(t, err)
+}
+
+func TestConfirmedPublish_Nacked_ShouldReturnErr(t *testing.T) {
+	ch := confirmed(&fakeChannel{}, amqp.Confirmation{DeliveryTag: 1, Ack: false})
+
+	err := ch.Publish("users", "", false, false, amqp.Publishing{})
+
+	assert.Equal(t, errNacked, err)
+}
+
+func TestConfirmedPublish_NotConfirmed_ShouldTimeOut(t *testing.T) {
+	ch := confirmed(&fakeChannel{})
+
+	err := ch.Publish("users", "", false, false, amqp.Publishing{})
+
+	assert.Equal(t, errConfirmTimeout, err)
+}
+
+func TestConfirmedPublish_LateConfirmOfEarlierMessage_ShouldBeSkipped(t *testing.T) {
+	ch := confirmed(&fakeChannel{},
+		amqp.Confirmation{DeliveryTag: 1, Ack: true},
+		amqp.Confirmation{DeliveryTag: 2, Ack: false})
+	ch.published = 1
+
+	err := ch.Publish("users", "", false, false, amqp.Publishing{})
+
+	assert.Equal(t, errNacked, err)
+}
+
+func TestProducer_DrainNacked_ShouldKeepMessageSpooled(t *testing.T) {
+	p, _ := createProducer(t)
+	assert.Nil(t, p.handle(Message{UserId: uuid.New()}))
+
+	// arrange, the broker refuses the message
+	fake := &fakeChannel{}
+	p.dial = func() (amqpChannel, error) {
+		return confirmed(fake, amqp.Confirmation{DeliveryTag: 1, Ack: false}), nil
+	}
+	p.connect()
+
+	// act
+	p.drainOne()
+
+	// assert
+	assert.Equal(t, 1, p.spool.Depth())
+	assert.Nil(t, p.ch)
+	assert.True(t, fake.closed)
+}
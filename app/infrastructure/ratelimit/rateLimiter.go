@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// How often buckets of inactive clients are removed.
+const sweepInterval = time.Minute
+
+// Buckets not used for this long are removed. Such bucket
+// is already full, so removing it doesn't change the limit.
+const idleTimeout = 10 * time.Minute
+
+// Token bucket limit. Zero rate means no limit.
+type Limit struct {
+	// requests per second
+	Rate float64
+	// requests allowed at once
+	Burst int
+}
+
+type Config struct {
+	// limit for methods without override
+	Default Limit
+	// overrides by method name (CreateUser) or full
+	// method name (/proto.UserService/CreateUser)
+	Methods map[string]Limit
+}
+
+type RateLimiter interface {
+	// Take a token for the client key and method. If the limit is
+	// exceeded, returns false and the time after which to retry.
+	Allow(key string, method string) (bool, time.Duration)
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	config    Config
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// Create in memory rate limiter with a bucket per client and method.
+// Methods without override share one bucket per client.
+func NewRateLimiter(c Config) *rateLimiter {
+	return &rateLimiter{
+		config:    c,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *rateLimiter) Allow(key string, method string) (bool, time.Duration) {
+	limit, group := l.limitFor(method)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucketKey := group + "|" + key
+	b, ok := l.buckets[bucketKey]
+	if !ok {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst)}
+		l.buckets[bucketKey] = b
+	}
+	b.lastUsed = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Returns limit for the method and name of the bucket group.
+func (l *rateLimiter) limitFor(method string) (Limit, string) {
+	if limit, ok := l.config.Methods[method]; ok {
+		return limit, method
+	}
+	name := method[strings.LastIndex(method, "/")+1:]
+	if limit, ok := l.config.Methods[name]; ok {
+		return limit, name
+	}
+	return l.config.Default, ""
+}
+
+// Remove idle buckets. Must be called with lock held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastUsed) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
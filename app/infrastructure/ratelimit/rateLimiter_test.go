@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const createUser = "/proto.UserService/CreateUser"
+const getUsers = "/proto.UserService/GetUsers"
+
+func createLimiter(now *time.Time) *rateLimiter {
+	limiter := NewRateLimiter(Config{
+		Default: Limit{Rate: 10, Burst: 2},
+		Methods: map[string]Limit{"CreateUser": {Rate: 1, Burst: 1}},
+	})
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestAllow_WithinBurst_ShouldAllow(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	first, _ := limiter.Allow("client", getUsers)
+	second, _ := limiter.Allow("client", getUsers)
+
+	assert.True(t, first)
+	assert.True(t, second)
+}
+
+func TestAllow_BurstExceeded_ShouldReturnRetryAfter(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("client", getUsers)
+	limiter.Allow("client", getUsers)
+
+	// act
+	allowed, retryAfter := limiter.Allow("client", getUsers)
+
+	// assert
+	assert.False(t, allowed)
+	assert.Equal(t, 100*time.Millisecond, retryAfter)
+}
+
+func TestAllow_AfterRetryAfter_ShouldAllow(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("client", createUser)
+	_, retryAfter := limiter.Allow("client", createUser)
+	now = now.Add(retryAfter)
+
+	// act
+	allowed, _ := limiter.Allow("client", createUser)
+
+	// assert
+	assert.True(t, allowed)
+}
+
+func TestAllow_RejectedRequest_ShouldNotTakeToken(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("client", createUser)
+	limiter.Allow("client", createUser)
+	limiter.Allow("client", createUser)
+	now = now.Add(time.Second)
+
+	// act
+	allowed, _ := limiter.Allow("client", createUser)
+
+	// assert
+	assert.True(t, allowed)
+}
+
+func TestAllow_MethodOverride_ShouldUseSeparateLimit(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("client", createUser)
+
+	// act
+	createAllowed, _ := limiter.Allow("client", createUser)
+	getAllowed, _ := limiter.Allow("client", getUsers)
+
+	// assert
+	assert.False(t, createAllowed)
+	assert.True(t, getAllowed)
+}
+
+func TestAllow_DifferentClients_ShouldHaveSeparateBuckets(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("first", createUser)
+
+	// act
+	allowed, _ := limiter.Allow("second", createUser)
+
+	// assert
+	assert.True(t, allowed)
+}
+
+func TestAllow_ZeroRate_ShouldNotLimit(t *testing.T) {
+	limiter := NewRateLimiter(Config{})
+
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow("client", getUsers)
+		assert.True(t, allowed)
+	}
+}
+
+func TestAllow_IdleBuckets_ShouldBeRemoved(t *testing.T) {
+	now := time.Now()
+	limiter := createLimiter(&now)
+
+	// arrange
+	limiter.Allow("idle", getUsers)
+	now = now.Add(idleTimeout + time.Second)
+
+	// act
+	limiter.Allow("active", getUsers)
+
+	// assert
+	assert.Len(t, limiter.buckets, 1)
+}
@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/cache"
+	"usermanager/app/infrastructure/metrics"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Deadline of the cache invalidation, which doesn't use the request
+// context, so a canceled request still invalidates the user.
+const invalidateTimeout = 5 * time.Second
+
+// User cache settings
+type CacheConfig struct {
+	// how long a user stays cached
+	TTL time.Duration
+	// how long a missing user stays cached, zero doesn't cache it
+	NotFoundTTL time.Duration
+	// the user is removed once more this long after a write, in case a
+	// concurrent Get read it before the write and cached it after the
+	// first removal. Zero removes it only once.
+	InvalidateDelay time.Duration
+}
+
+// Implemented by repositories with read replicas. The cache is filled
+// from the primary, so it doesn't keep a user the replica hasn't
+// updated yet.
+type primaryReader interface {
+	GetPrimary(ctx context.Context, id uuid.UUID) (domain.User, error)
+}
+
+// Cached lookup result, user is nil if it was not found.
+type cachedUser struct {
+	User *domain.User `json:"user,omitempty"`
+}
+
+// Decorator that serves Get from the cache. Every write invalidates
+// the user, so the next Get reads it from the repository again.
+// Writes of other instances are invalidated with Invalidate.
+type cachedUserRepo struct {
+	UserRepo
+	cache  cache.Cache
+	config CacheConfig
+}
+
+// Create user repository that caches Get of the wrapped repository.
+// Cache errors are logged and the repository is used instead.
+func NewCachedUserRepo(r UserRepo, c cache.Cache, conf CacheConfig) *cachedUserRepo {
+	return &cachedUserRepo{
+		UserRepo: r,
+		cache:    c,
+		config:   conf,
+	}
+}
+
+func (r *cachedUserRepo) Add(ctx context.Context, user domain.User) (domain.User, error) {
+	stored, err := r.UserRepo.Add(ctx, user)
+	r.invalidate(ctx, user.Id)
+	return stored, err
+}
+
+func (r *cachedUserRepo) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	stored, err := r.UserRepo.Update(ctx, user)
+	r.invalidate(ctx, user.Id)
+	return stored, err
+}
+
+func (r *cachedUserRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.UserRepo.Delete(ctx, id)
+	r.invalidate(ctx, id)
+	return err
+}
+
+// Get user from the cache, or from the repository and cache it.
+// NotFound is cached for NotFoundTTL. The password hash is never
+// cached nor returned, callers that need it read the wrapped repository.
+func (r *cachedUserRepo) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	if cached, ok := r.lookup(ctx, id); ok {
+		metrics.UserCacheRequests.WithLabelValues("hit").Inc()
+		if cached.User == nil {
+			return domain.User{}, status.Error(codes.NotFound, "no user in database")
+		}
+		return *cached.User, nil
+	}
+	metrics.UserCacheRequests.WithLabelValues("miss").Inc()
+
+	user, err := r.load(ctx, id)
+	switch {
+	case err == nil:
+		user.Password = ""
+		r.store(ctx, id, cachedUser{User: &user}, r.config.TTL)
+	case status.Code(err) == codes.NotFound && r.config.NotFoundTTL > 0:
+		r.store(ctx, id, cachedUser{}, r.config.NotFoundTTL)
+	}
+	return user, err
+}
+
+// Remove the user from the cache, for writes made by other instances.
+func (r *cachedUserRepo) Invalidate(ctx context.Context, id uuid.UUID) {
+	r.remove(ctx, id)
+}
+
+func (r *cachedUserRepo) load(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	if primary, ok := r.UserRepo.(primaryReader); ok {
+		return primary.GetPrimary(ctx, id)
+	}
+	return r.UserRepo.Get(ctx, id)
+}
+
+func (r *cachedUserRepo) lookup(ctx context.Context, id uuid.UUID) (cachedUser, bool) {
+	var cached cachedUser
+	value, err := r.cache.Get(ctx, userKey(id))
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			log.Ctx(ctx).Warn().Err(err).Msgf("cannot read user %v from cache", id)
+		}
+		return cached, false
+	}
+	if err := json.Unmarshal(value, &cached); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("cannot decode cached user %v", id)
+		return cached, false
+	}
+	return cached, true
+}
+
+func (r *cachedUserRepo) store(ctx context.Context, id uuid.UUID, cached cachedUser, ttl time.Duration) {
+	value, err := json.Marshal(cached)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("cannot encode user %v for cache", id)
+		return
+	}
+	if err := r.cache.Set(ctx, userKey(id), value, ttl); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("cannot cache user %v", id)
+	}
+}
+
+// Remove the user from the cache after a write, also when the write
+// failed, as it may have been applied anyway, and again after
+// InvalidateDelay.
+func (r *cachedUserRepo) invalidate(ctx context.Context, id uuid.UUID) {
+	r.remove(ctx, id)
+	if r.config.InvalidateDelay > 0 {
+		logger := log.Ctx(ctx)
+		time.AfterFunc(r.config.InvalidateDelay, func() {
+			r.remove(logger.WithContext(context.Background()), id)
+		})
+	}
+}
+
+func (r *cachedUserRepo) remove(ctx context.Context, id uuid.UUID) {
+	deleteCtx, cancel := context.WithTimeout(context.Background(), invalidateTimeout)
+	defer cancel()
+
+	if err := r.cache.Delete(deleteCtx, userKey(id)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot remove user %v from cache", id)
+	}
+}
+
+func userKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
@@ -0,0 +1,195 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/cache"
+	"usermanager/app/infrastructure/metrics"
+	repo "usermanager/app/infrastructure/repositories"
+	"usermanager/app/infrastructure/repositories/mocks"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createCachedUserRepo() (repo.UserRepo, *mocks.UserRepoMock) {
+	mockedRepo := &mocks.UserRepoMock{}
+	r := repo.NewCachedUserRepo(mockedRepo, cache.NewLRU(10), repo.CacheConfig{TTL: time.Minute, NotFoundTTL: time.Minute})
+	return r, mockedRepo
+}
+
+func TestCachedGet_SecondGet_ShouldBeServedFromCache(t *testing.T) {
+	r, mockedRepo := createCachedUserRepo()
+	hits := testutil.ToFloat64(metrics.UserCacheRequests.WithLabelValues("hit"))
+	misses := testutil.ToFloat64(metrics.UserCacheRequests.WithLabelValues("miss"))
+
+	// arrange
+	user := domain.User{Id: uuid.New(), Nickname: "ben"}
+	mockedRepo.
+		On("Get", mock.Anything, user.Id).
+		Return(user, nil).
+		Once()
+
+	// act
+	_, _ = r.Get(context.Background(), user.Id)
+	cached, err := r.Get(context.Background(), user.Id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, user, cached)
+	mockedRepo.AssertNumberOfCalls(t, "Get", 1)
+	assert.Equal(t, hits+1, testutil.ToFloat64(metrics.UserCacheRequests.WithLabelValues("hit")))
+	assert.Equal(t, misses+1, testutil.ToFloat64(metrics.UserCacheRequests.WithLabelValues("miss")))
+}
+
+func TestCachedGet_ShouldNotCachePassword(t *testing.T) {
+	c := cache.NewLRU(10)
+	mockedRepo := &mocks.UserRepoMock{}
+	r := repo.NewCachedUserRepo(mockedRepo, c, repo.CacheConfig{TTL: time.Minute})
+
+	// arrange
+	user := domain.User{Id: uuid.New(), Nickname: "ben", Password: "hash"}
+	mockedRepo.On("Get", mock.Anything, user.Id).Return(user, nil).Once()
+
+	// act
+	got, err := r.Get(context.Background(), user.Id)
+	cached, _ := r.Get(context.Background(), user.Id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Empty(t, got.Password)
+	assert.Empty(t, cached.Password)
+	value, err := c.Get(context.Background(), "user:"+user.Id.String())
+	assert.Nil(t, err)
+	assert.NotContains(t, string(value), "hash")
+}
+
+func TestCachedGet_NotFound_ShouldBeCached(t *testing.T) {
+	r, mockedRepo := createCachedUserRepo()
+
+	// arrange
+	id := uuid.New()
+	mockedRepo.
+		On("Get", mock.Anything, id).
+		Return(domain.User{}, status.Error(codes.NotFound, "no user in database")).
+		Once()
+
+	// act
+	_, _ = r.Get(context.Background(), id)
+	_, err := r.Get(context.Background(), id)
+
+	// assert
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockedRepo.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestCachedGet_RepoErr_ShouldNotBeCached(t *testing.T) {
+	r, mockedRepo := createCachedUserRepo()
+
+	// arrange
+	id := uuid.New()
+	mockedRepo.
+		On("Get", mock.Anything, id).
+		Return(domain.User{}, status.Error(codes.Internal, "connection reset"))
+
+	// act
+	_, _ = r.Get(context.Background(), id)
+	_, err := r.Get(context.Background(), id)
+
+	// assert
+	assert.Equal(t, codes.Internal, status.Code(err))
+	mockedRepo.AssertNumberOfCalls(t, "Get", 2)
+}
+
+func TestCachedUpdate_Failed_ShouldStillInvalidate(t *testing.T) {
+	r, mockedRepo := createCachedUserRepo()
+
+	// arrange
+	user := domain.User{Id: uuid.New(), Nickname: "ben"}
+	mockedRepo.
+		On("Get", mock.Anything, user.Id).
+		Return(user, nil)
+	mockedRepo.
+		On("Update", mock.Anything, user).
+		Return(domain.User{}, errors.New("timeout"))
+	_, _ = r.Get(context.Background(), user.Id)
+
+	// act
+	_, _ = r.Update(context.Background(), user)
+	_, _ = r.Get(context.Background(), user.Id)
+
+	// assert
+	mockedRepo.AssertNumberOfCalls(t, "Get", 2)
+}
+
+func TestCachedUpdate_StaleGetAfterInvalidation_ShouldBeRemovedAfterDelay(t *testing.T) {
+	mockedRepo := &mocks.UserRepoMock{}
+	r := repo.NewCachedUserRepo(mockedRepo, cache.NewLRU(10),
+		repo.CacheConfig{TTL: time.Minute, InvalidateDelay: 10 * time.Millisecond})
+
+	// arrange, a concurrent Get read the user before the update and
+	// caches it after the update removed it
+	stale := domain.User{Id: uuid.New(), Nickname: "ben"}
+	updated := domain.User{Id: stale.Id, Nickname: "benny"}
+	mockedRepo.On("Update", mock.Anything, updated).Return(updated, nil)
+	mockedRepo.On("Get", mock.Anything, stale.Id).Return(stale, nil).Once()
+	mockedRepo.On("Get", mock.Anything, stale.Id).Return(updated, nil)
+
+	// act
+	_, _ = r.Update(context.Background(), updated)
+	_, _ = r.Get(context.Background(), stale.Id)
+
+	// assert
+	assert.Eventually(t, func() bool {
+		user, _ := r.Get(context.Background(), stale.Id)
+		return user.Nickname == "benny"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestCachedInvalidate_ShouldRemoveUser(t *testing.T) {
+	mockedRepo := &mocks.UserRepoMock{}
+	r := repo.NewCachedUserRepo(mockedRepo, cache.NewLRU(10), repo.CacheConfig{TTL: time.Minute})
+
+	// arrange
+	user := domain.User{Id: uuid.New(), Nickname: "ben"}
+	mockedRepo.On("Get", mock.Anything, user.Id).Return(user, nil)
+	_, _ = r.Get(context.Background(), user.Id)
+
+	// act, like a notification of a write on another instance
+	r.Invalidate(context.Background(), user.Id)
+	_, _ = r.Get(context.Background(), user.Id)
+
+	// assert
+	mockedRepo.AssertNumberOfCalls(t, "Get", 2)
+}
+
+// repository with replicas, Get would read a lagging replica
+type primaryRepo struct {
+	*mocks.UserRepoMock
+	primary domain.User
+}
+
+func (r primaryRepo) GetPrimary(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return r.primary, nil
+}
+
+func TestCachedGet_WithPrimary_ShouldReadPrimary(t *testing.T) {
+	mockedRepo := &mocks.UserRepoMock{}
+	user := domain.User{Id: uuid.New(), Nickname: "benny"}
+	r := repo.NewCachedUserRepo(primaryRepo{mockedRepo, user}, cache.NewLRU(10), repo.CacheConfig{TTL: time.Minute})
+
+	// act
+	got, err := r.Get(context.Background(), user.Id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, user, got)
+	mockedRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
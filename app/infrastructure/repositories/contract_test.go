@@ -0,0 +1,83 @@
+package repo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/cache"
+	"usermanager/app/infrastructure/db"
+	repo "usermanager/app/infrastructure/repositories"
+	"usermanager/app/infrastructure/repositories/repotest"
+
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Postgres contract tests run only when this DSN is set. Users
+// table of that database is truncated before every test.
+const postgresDsnEnv = "USERMANAGER_TEST_POSTGRES_DSN"
+
+func TestMemoryUserRepo_Contract(t *testing.T) {
+	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+		return repo.NewMemoryUserRepo()
+	})
+}
+
+func TestCachedUserRepo_Contract(t *testing.T) {
+	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+		return repo.NewCachedUserRepo(repo.NewMemoryUserRepo(), cache.NewLRU(100),
+			repo.CacheConfig{TTL: time.Minute, NotFoundTTL: time.Minute})
+	})
+}
+
+func TestRedisCachedUserRepo_Contract(t *testing.T) {
+	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+		server := miniredis.RunT(t)
+		c, err := cache.NewRedis(cache.RedisConfig{Url: "redis://" + server.Addr(), Prefix: "usermanager:"})
+		if err != nil {
+			t.Fatalf("cannot create redis cache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return repo.NewCachedUserRepo(repo.NewMemoryUserRepo(), c,
+			repo.CacheConfig{TTL: time.Minute, NotFoundTTL: time.Minute})
+	})
+}
+
+func TestPostgresUserRepo_Contract(t *testing.T) {
+	dsn := os.Getenv(postgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%v is not set", postgresDsnEnv)
+	}
+
+	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("cannot open postgres: %v", err)
+	}
+	if err := gdb.AutoMigrate(&domain.User{}); err != nil {
+		t.Fatalf("cannot migrate postgres: %v", err)
+	}
+
+	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+		if err := gdb.Exec("TRUNCATE TABLE users").Error; err != nil {
+			t.Fatalf("cannot truncate users: %v", err)
+		}
+		return repo.NewUserRepo(gdb)
+	})
+}
+
+func TestSqliteUserRepo_Contract(t *testing.T) {
+	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+		c := db.Config{Driver: db.DriverSqlite, Path: filepath.Join(t.TempDir(), "users.db")}
+		if err := db.MigrateDb(c); err != nil {
+			t.Fatalf("cannot migrate sqlite: %v", err)
+		}
+		gdb, err := db.OpenDb(c)
+		if err != nil {
+			t.Fatalf("cannot open sqlite: %v", err)
+		}
+		return repo.NewUserRepo(gdb)
+	})
+}
@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"context"
+	"usermanager/app/domain"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// Dead letters matching all set fields.
+type DeadLetterFilter struct {
+	Transport string
+	UserId    uuid.UUID
+}
+
+type DeadLetterRepo interface {
+	Add(ctx context.Context, letter domain.DeadLetter) error
+	Get(ctx context.Context, id uuid.UUID) (domain.DeadLetter, error)
+	// Oldest dead letters first, limit 0 returns all of them.
+	List(ctx context.Context, filter DeadLetterFilter, offset int, limit int) ([]domain.DeadLetter, error)
+	Count(ctx context.Context, filter DeadLetterFilter) (int64, error)
+	CountByTransport(ctx context.Context) (map[string]int64, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Count another failed attempt and keep its error.
+	RecordFailure(ctx context.Context, id uuid.UUID, errMsg string) error
+}
+
+type deadLetterRepo struct {
+	db *gorm.DB
+}
+
+// Create new dead letter repository with Gorm ORM library.
+func NewDeadLetterRepo(gormDb *gorm.DB) *deadLetterRepo {
+	return &deadLetterRepo{db: gormDb}
+}
+
+// Add dead letter method. Returns an error if ocurred.
+func (r *deadLetterRepo) Add(ctx context.Context, letter domain.DeadLetter) error {
+	if err := r.db.WithContext(ctx).Create(&letter).Error; err != nil {
+		return queryErr(err)
+	}
+	return nil
+}
+
+// Get dead letter by id. Returns NotFound error if there is no such
+// dead letter.
+func (r *deadLetterRepo) Get(ctx context.Context, id uuid.UUID) (domain.DeadLetter, error) {
+	var letter domain.DeadLetter
+	res := r.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&letter)
+
+	if res.Error != nil {
+		return letter, queryErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return letter, status.Error(codes.NotFound, "no dead letter in database")
+	}
+	return letter, nil
+}
+
+func (r *deadLetterRepo) List(ctx context.Context, filter DeadLetterFilter, offset int, limit int) ([]domain.DeadLetter, error) {
+	query := r.filtered(ctx, filter).Order("created_at, id").Offset(offset)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var letters []domain.DeadLetter
+	if err := query.Find(&letters).Error; err != nil {
+		return nil, queryErr(err)
+	}
+	return letters, nil
+}
+
+func (r *deadLetterRepo) Count(ctx context.Context, filter DeadLetterFilter) (int64, error) {
+	var count int64
+	if err := r.filtered(ctx, filter).Count(&count).Error; err != nil {
+		return 0, queryErr(err)
+	}
+	return count, nil
+}
+
+// Count dead letters method. Returns number of dead letters for each
+// transport or error if ocurred.
+func (r *deadLetterRepo) CountByTransport(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Transport string
+		Count     int64
+	}
+	err := r.db.
+		WithContext(ctx).
+		Model(&domain.DeadLetter{}).
+		Select("transport, count(*) as count").
+		Group("transport").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, queryErr(err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Transport] = row.Count
+	}
+	return counts, nil
+}
+
+// Delete dead letter method. Returns NotFound error if there is no
+// such dead letter.
+func (r *deadLetterRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&domain.DeadLetter{}, "id = ?", id)
+
+	if res.Error != nil {
+		return queryErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return status.Error(codes.NotFound, "no dead letter in database")
+	}
+	return nil
+}
+
+func (r *deadLetterRepo) RecordFailure(ctx context.Context, id uuid.UUID, errMsg string) error {
+	res := r.db.
+		WithContext(ctx).
+		Model(&domain.DeadLetter{Id: id}).
+		Updates(map[string]interface{}{
+			"attempts": gorm.Expr("attempts + 1"),
+			"error":    errMsg,
+		})
+
+	if res.Error != nil {
+		return queryErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return status.Error(codes.NotFound, "no dead letter in database")
+	}
+	return nil
+}
+
+func (r *deadLetterRepo) filtered(ctx context.Context, filter DeadLetterFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&domain.DeadLetter{})
+	if filter.Transport != "" {
+		query = query.Where("transport = ?", filter.Transport)
+	}
+	if filter.UserId != uuid.Nil {
+		query = query.Where("user_id = ?", filter.UserId)
+	}
+	return query
+}
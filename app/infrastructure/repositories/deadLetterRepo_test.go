@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createDeadLetterRepo(t *testing.T) *deadLetterRepo {
+	c := db.Config{Driver: db.DriverSqlite, Path: filepath.Join(t.TempDir(), "deadletters.db")}
+	assert.Nil(t, db.MigrateDb(c))
+	gdb, err := db.OpenDb(c)
+	assert.Nil(t, err)
+	return NewDeadLetterRepo(gdb)
+}
+
+func newDeadLetter(transport string) domain.DeadLetter {
+	return domain.DeadLetter{
+		Id:        uuid.New(),
+		Transport: transport,
+		UserId:    uuid.New(),
+		Error:     "connection refused",
+		Attempts:  1,
+	}
+}
+
+func TestDeadLetterRepo_ListAndCount_ShouldApplyFilter(t *testing.T) {
+	r := createDeadLetterRepo(t)
+	ctx := context.Background()
+
+	// arrange
+	first := newDeadLetter("rabbitmq")
+	second := newDeadLetter("kafka")
+	third := newDeadLetter("rabbitmq")
+	for _, letter := range []domain.DeadLetter{first, second, third} {
+		assert.Nil(t, r.Add(ctx, letter))
+	}
+
+	// act
+	letters, listErr := r.List(ctx, DeadLetterFilter{Transport: "rabbitmq"}, 0, 0)
+	count, countErr := r.Count(ctx, DeadLetterFilter{Transport: "rabbitmq"})
+	byUser, _ := r.List(ctx, DeadLetterFilter{UserId: second.UserId}, 0, 10)
+	byTransport, _ := r.CountByTransport(ctx)
+
+	// assert
+	assert.Nil(t, listErr)
+	assert.Nil(t, countErr)
+	assert.Equal(t, int64(2), count)
+	assert.Len(t, letters, 2)
+	assert.Len(t, byUser, 1)
+	assert.Equal(t, second.Id, byUser[0].Id)
+	assert.Equal(t, map[string]int64{"rabbitmq": 2, "kafka": 1}, byTransport)
+}
+
+func TestDeadLetterRepo_RecordFailure_ShouldCountAttempt(t *testing.T) {
+	r := createDeadLetterRepo(t)
+	ctx := context.Background()
+	letter := newDeadLetter("nats")
+	assert.Nil(t, r.Add(ctx, letter))
+
+	// act
+	err := r.RecordFailure(ctx, letter.Id, "no responders")
+	stored, _ := r.Get(ctx, letter.Id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Equal(t, "no responders", stored.Error)
+}
+
+func TestDeadLetterRepo_DeleteMissing_ShouldReturnNotFound(t *testing.T) {
+	r := createDeadLetterRepo(t)
+
+	err := r.Delete(context.Background(), uuid.New())
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
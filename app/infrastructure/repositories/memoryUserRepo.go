@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type memoryUserRepo struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]domain.User
+	now   func() time.Time
+}
+
+// Create user repository that keeps users in memory. It has the same
+// uniqueness, filtering and error semantics as the Postgres repository,
+// for tests and local development.
+func NewMemoryUserRepo() *memoryUserRepo {
+	return &memoryUserRepo{
+		users: make(map[uuid.UUID]domain.User),
+		now:   time.Now,
+	}
+}
+
+// Add user method. Returns stored user or error if ocurred.
+func (r *memoryUserRepo) Add(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := contextErr(ctx.Err()); err != nil {
+		return domain.User{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.Id]; exists {
+		return domain.User{}, status.Error(codes.Internal, "user id already exist")
+	}
+	if err := r.checkUnique(user); err != nil {
+		return domain.User{}, err
+	}
+
+	now := r.now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	r.users[user.Id] = user
+	return user, nil
+}
+
+// Update user method. Like gorm Updates, only non empty fields
+// are changed. Returns stored user or error if ocurred.
+func (r *memoryUserRepo) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := contextErr(ctx.Err()); err != nil {
+		return domain.User{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.users[user.Id]
+	if !exists {
+		return domain.User{}, status.Error(codes.NotFound, "no user in database")
+	}
+
+	updateField(&stored.Firstname, user.Firstname)
+	updateField(&stored.Lastname, user.Lastname)
+	updateField(&stored.Nickname, user.Nickname)
+	updateField(&stored.Password, user.Password)
+	updateField(&stored.Email, user.Email)
+	updateField(&stored.Country, user.Country)
+
+	if err := r.checkUnique(stored); err != nil {
+		return domain.User{}, err
+	}
+
+	stored.UpdatedAt = r.now()
+	r.users[user.Id] = stored
+	return stored, nil
+}
+
+// Delete user method. Returns error if ocurred.
+func (r *memoryUserRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := contextErr(ctx.Err()); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[id]; !exists {
+		return status.Error(codes.NotFound, "no user in database")
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// Get user by id. Returns NotFound error if there is no such user.
+func (r *memoryUserRepo) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	if err := contextErr(ctx.Err()); err != nil {
+		return domain.User{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return domain.User{}, status.Error(codes.NotFound, "no user in database")
+	}
+	return user, nil
+}
+
+// Get user page method based on the provided filter params. Users are
+// ordered by creation time, like in the Postgres repository.
+// Returns list of users or error if ocurred.
+func (r *memoryUserRepo) GetPage(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions,
+	offset int32, limit int32) ([]domain.User, error) {
+
+	if err := contextErr(ctx.Err()); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	users := make([]domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		if matchesFilter(user, filter) {
+			users = append(users, user)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].Id.String() < users[j].Id.String()
+	})
+
+	if int(offset) >= len(users) {
+		return []domain.User{}, nil
+	}
+	if offset > 0 {
+		users = users[offset:]
+	}
+	if limit > 0 && int(limit) < len(users) {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// Count users by country method. Returns number of users
+// for each country or error if ocurred.
+func (r *memoryUserRepo) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	if err := contextErr(ctx.Err()); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, user := range r.users {
+		counts[user.Country]++
+	}
+	return counts, nil
+}
+
+// Nickname and email have to be unique, like the unique
+// constraints in Postgres. Must be called with lock held.
+func (r *memoryUserRepo) checkUnique(user domain.User) error {
+	for _, other := range r.users {
+		if other.Id == user.Id {
+			continue
+		}
+		if other.Nickname == user.Nickname {
+			return alreadyExist("nickname", domain.ErrorReasonNicknameTaken)
+		}
+		if other.Email == user.Email {
+			return alreadyExist("email", domain.ErrorReasonEmailTaken)
+		}
+	}
+	return nil
+}
+
+func updateField(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// Same conditions as the where clauses of the Postgres query.
+func matchesFilter(user domain.User, filter *proto.UserPageRequest_UserFilterOptions) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Country != "" && user.Country != filter.Country {
+		return false
+	}
+	if filter.CreatedFrom != nil && !user.CreatedAt.After(filter.CreatedFrom.AsTime()) {
+		return false
+	}
+	if filter.CreatedTo != nil && !user.CreatedAt.Before(filter.CreatedTo.AsTime()) {
+		return false
+	}
+	return true
+}
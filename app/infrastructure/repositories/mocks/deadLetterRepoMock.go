@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"context"
+	"usermanager/app/domain"
+	repo "usermanager/app/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type DeadLetterRepoMock struct {
+	mock.Mock
+}
+
+func (r *DeadLetterRepoMock) Add(ctx context.Context, letter domain.DeadLetter) error {
+	args := r.Called(ctx, letter)
+	return args.Error(0)
+}
+
+func (r *DeadLetterRepoMock) Get(ctx context.Context, id uuid.UUID) (domain.DeadLetter, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).(domain.DeadLetter), args.Error(1)
+}
+
+func (r *DeadLetterRepoMock) List(ctx context.Context, filter repo.DeadLetterFilter, offset int, limit int) ([]domain.DeadLetter, error) {
+	args := r.Called(ctx, filter, offset, limit)
+	letters, _ := args.Get(0).([]domain.DeadLetter)
+	return letters, args.Error(1)
+}
+
+func (r *DeadLetterRepoMock) Count(ctx context.Context, filter repo.DeadLetterFilter) (int64, error) {
+	args := r.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (r *DeadLetterRepoMock) CountByTransport(ctx context.Context) (map[string]int64, error) {
+	args := r.Called(ctx)
+	counts, _ := args.Get(0).(map[string]int64)
+	return counts, args.Error(1)
+}
+
+func (r *DeadLetterRepoMock) Delete(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (r *DeadLetterRepoMock) RecordFailure(ctx context.Context, id uuid.UUID, errMsg string) error {
+	args := r.Called(ctx, id, errMsg)
+	return args.Error(0)
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"usermanager/app/domain"
 	proto "usermanager/app/ui/protos/user"
 
@@ -12,41 +13,52 @@ type UserRepoMock struct {
 	mock.Mock
 }
 
-func (r *UserRepoMock) Add(user domain.User) error {
-	args := r.Called(user)
+func (r *UserRepoMock) Add(ctx context.Context, user domain.User) (domain.User, error) {
+	args := r.Called(ctx, user)
 
-	var r0 error
-	if rf, ok := args.Get(0).(func(domain.User) error); ok {
-		r0 = rf(user)
+	var r0 domain.User
+	if rf, ok := args.Get(0).(func(context.Context, domain.User) domain.User); ok {
+		r0 = rf(ctx, user)
 	} else {
-		r0 = args.Error(0)
+		r0 = args.Get(0).(domain.User)
 	}
 
-	return r0
+	return r0, args.Error(1)
 }
 
-func (r *UserRepoMock) Update(user domain.User) error {
-	args := r.Called(user)
+func (r *UserRepoMock) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	args := r.Called(ctx, user)
 
-	var r0 error
-	if rf, ok := args.Get(0).(func(domain.User) error); ok {
-		r0 = rf(user)
+	var r0 domain.User
+	if rf, ok := args.Get(0).(func(context.Context, domain.User) domain.User); ok {
+		r0 = rf(ctx, user)
 	} else {
-		r0 = args.Error(0)
+		r0 = args.Get(0).(domain.User)
 	}
 
-	return r0
+	return r0, args.Error(1)
+}
+
+func (r *UserRepoMock) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).(domain.User), args.Error(1)
 }
 
 // not implemented
 // we don't need get page mock at the moment
-func (r *UserRepoMock) GetPage(filter *proto.UserPageRequest_UserFilterOptions,
+func (r *UserRepoMock) GetPage(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions,
 	offset int32, limit int32) (users []domain.User, err error) {
 	return users, nil
 }
 
 // not implemented
 // we don't need delete mock at the moment
-func (r *UserRepoMock) Delete(id uuid.UUID) error {
+func (r *UserRepoMock) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
+
+// not implemented
+// we don't need count by country mock at the moment
+func (r *UserRepoMock) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"usermanager/app/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookRepoMock struct {
+	mock.Mock
+}
+
+func (r *WebhookRepoMock) Add(ctx context.Context, webhook domain.Webhook) error {
+	args := r.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (r *WebhookRepoMock) Update(ctx context.Context, webhook domain.Webhook) error {
+	args := r.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (r *WebhookRepoMock) Get(ctx context.Context, id uuid.UUID) (domain.Webhook, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).(domain.Webhook), args.Error(1)
+}
+
+func (r *WebhookRepoMock) List(ctx context.Context) ([]domain.Webhook, error) {
+	args := r.Called(ctx)
+	webhooks, _ := args.Get(0).([]domain.Webhook)
+	return webhooks, args.Error(1)
+}
+
+func (r *WebhookRepoMock) Delete(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (r *WebhookRepoMock) AddDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	args := r.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (r *WebhookRepoMock) ListDeliveries(ctx context.Context, webhookId uuid.UUID, limit int) ([]domain.WebhookDelivery, error) {
+	args := r.Called(ctx, webhookId, limit)
+	deliveries, _ := args.Get(0).([]domain.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+func (r *WebhookRepoMock) RecordSuccess(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (r *WebhookRepoMock) RecordFailure(ctx context.Context, id uuid.UUID, disableAfter int) (bool, error) {
+	args := r.Called(ctx, id, disableAfter)
+	return args.Bool(0), args.Error(1)
+}
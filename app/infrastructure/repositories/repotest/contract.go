@@ -0,0 +1,394 @@
+// Package repotest contains the contract every UserRepo implementation
+// has to satisfy. Run it from the tests of an implementation:
+//
+//	repotest.RunUserRepoContract(t, func(t *testing.T) repo.UserRepo {
+//		return repo.NewMemoryUserRepo()
+//	})
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	repo "usermanager/app/infrastructure/repositories"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Returns an empty repository. It is called once per test case.
+type NewUserRepo func(t *testing.T) repo.UserRepo
+
+// Run all contract test cases as subtests of t.
+func RunUserRepoContract(t *testing.T, newRepo NewUserRepo) {
+	cases := []struct {
+		name string
+		test func(t *testing.T, r repo.UserRepo)
+	}{
+		{"Add_ShouldPass", testAdd},
+		{"Add_WithoutCreatedAt_ShouldSetIt", testAddSetsCreatedAt},
+		{"Add_NickAlreadyExist_ShouldReturnInvalidArgument", testAddNickExist},
+		{"Add_EmailAlreadyExist_ShouldReturnInvalidArgument", testAddEmailExist},
+		{"Add_Concurrently_OnlyOneShouldPass", testAddConcurrently},
+		{"Update_ShouldChangeOnlyProvidedFields", testUpdate},
+		{"Update_UserDoesntExist_ShouldReturnNotFound", testUpdateNotFound},
+		{"Update_NickAlreadyExist_ShouldReturnInvalidArgument", testUpdateNickExist},
+		{"Update_EmailAlreadyExist_ShouldReturnInvalidArgument", testUpdateEmailExist},
+		{"Delete_ShouldPass", testDelete},
+		{"Delete_UserDoesntExist_ShouldReturnNotFound", testDeleteNotFound},
+		{"Get_ShouldReturnUser", testGet},
+		{"Get_UserDoesntExist_ShouldReturnNotFound", testGetNotFound},
+		{"Get_AfterAdd_ShouldReturnUser", testGetAfterAdd},
+		{"Get_AfterUpdate_ShouldReturnChanges", testGetAfterUpdate},
+		{"Get_AfterDelete_ShouldReturnNotFound", testGetAfterDelete},
+		{"GetPage_CountryFilter_ShouldReturnMatchingUsers", testGetPageCountry},
+		{"GetPage_CreatedFilter_ShouldExcludeBounds", testGetPageCreated},
+		{"GetPage_OffsetAndLimit_ShouldReturnOrderedPage", testGetPagePagination},
+		{"CountByCountry_ShouldPass", testCountByCountry},
+		{"ContextCanceled_ShouldReturnCanceled", testContextCanceled},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, newRepo(t))
+		})
+	}
+}
+
+// base time of the test users, truncated to the Postgres precision
+var base = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newUser(n int, country string, createdAt time.Time) domain.User {
+	return domain.User{
+		Id:        uuid.New(),
+		Firstname: "first",
+		Lastname:  "last",
+		Nickname:  fmt.Sprintf("nick%d", n),
+		Password:  "pass",
+		Email:     fmt.Sprintf("user%d@test.com", n),
+		Country:   country,
+		CreatedAt: createdAt,
+	}
+}
+
+func addUsers(t *testing.T, r repo.UserRepo, users ...domain.User) {
+	for _, u := range users {
+		if _, err := r.Add(context.Background(), u); err != nil {
+			t.Fatalf("cannot add user %v: %v", u.Nickname, err)
+		}
+	}
+}
+
+func allUsers(t *testing.T, r repo.UserRepo) []domain.User {
+	users, err := r.GetPage(context.Background(), nil, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot get users: %v", err)
+	}
+	return users
+}
+
+func ids(users []domain.User) []uuid.UUID {
+	res := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		res = append(res, u.Id)
+	}
+	return res
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, msg string) {
+	t.Helper()
+	assert.Equal(t, code, status.Code(err))
+	if msg != "" {
+		assert.Equal(t, msg, status.Convert(err).Message())
+	}
+}
+
+// Asserts the ErrorInfo reason clients match on.
+func assertReason(t *testing.T, err error, reason string) {
+	t.Helper()
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			assert.Equal(t, reason, info.Reason)
+			assert.Equal(t, domain.ErrorDomain, info.Domain)
+			return
+		}
+	}
+	t.Errorf("no error info in %v", err)
+}
+
+func testAdd(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+
+	stored, err := r.Add(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.True(t, base.Equal(stored.CreatedAt))
+	users := allUsers(t, r)
+	assert.Len(t, users, 1)
+	assert.Equal(t, user.Id, users[0].Id)
+	assert.Equal(t, user.Nickname, users[0].Nickname)
+	assert.Equal(t, user.Email, users[0].Email)
+	assert.True(t, base.Equal(users[0].CreatedAt))
+}
+
+func testAddSetsCreatedAt(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", time.Time{})
+	before := time.Now().Add(-time.Second)
+
+	stored, err := r.Add(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.True(t, stored.CreatedAt.After(before))
+	fetched, err := r.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.True(t, stored.CreatedAt.Equal(fetched.CreatedAt))
+}
+
+func testAddNickExist(t *testing.T, r repo.UserRepo) {
+	addUsers(t, r, newUser(1, "RS", base))
+	user := newUser(2, "RS", base)
+	user.Nickname = "nick1"
+
+	_, err := r.Add(context.Background(), user)
+
+	assertStatus(t, err, codes.InvalidArgument, "nickname already exist")
+	assertReason(t, err, domain.ErrorReasonNicknameTaken)
+	assert.Len(t, allUsers(t, r), 1)
+}
+
+func testAddEmailExist(t *testing.T, r repo.UserRepo) {
+	addUsers(t, r, newUser(1, "RS", base))
+	user := newUser(2, "RS", base)
+	user.Email = "user1@test.com"
+
+	_, err := r.Add(context.Background(), user)
+
+	assertStatus(t, err, codes.InvalidArgument, "email already exist")
+	assertReason(t, err, domain.ErrorReasonEmailTaken)
+	assert.Len(t, allUsers(t, r), 1)
+}
+
+func testAddConcurrently(t *testing.T, r repo.UserRepo) {
+	const n = 10
+	errs := make([]error, n)
+
+	// act
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := newUser(i, "RS", base)
+			user.Nickname = "same"
+			_, errs[i] = r.Add(context.Background(), user)
+		}(i)
+	}
+	wg.Wait()
+
+	// assert
+	passed := 0
+	for _, err := range errs {
+		if err == nil {
+			passed++
+			continue
+		}
+		assertStatus(t, err, codes.InvalidArgument, "nickname already exist")
+	}
+	assert.Equal(t, 1, passed)
+	assert.Len(t, allUsers(t, r), 1)
+}
+
+func testUpdate(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+	addUsers(t, r, user)
+
+	stored, err := r.Update(context.Background(), domain.User{Id: user.Id, Firstname: "changed", Country: "DE"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "changed", stored.Firstname)
+	assert.Equal(t, user.Nickname, stored.Nickname)
+	assert.True(t, base.Equal(stored.CreatedAt))
+	users := allUsers(t, r)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "changed", users[0].Firstname)
+	assert.Equal(t, "DE", users[0].Country)
+	assert.Equal(t, user.Lastname, users[0].Lastname)
+	assert.Equal(t, user.Nickname, users[0].Nickname)
+	assert.Equal(t, user.Email, users[0].Email)
+	assert.True(t, base.Equal(users[0].CreatedAt))
+}
+
+func testUpdateNotFound(t *testing.T, r repo.UserRepo) {
+	_, err := r.Update(context.Background(), domain.User{Id: uuid.New(), Firstname: "changed"})
+
+	assertStatus(t, err, codes.NotFound, "no user in database")
+}
+
+func testUpdateNickExist(t *testing.T, r repo.UserRepo) {
+	first, second := newUser(1, "RS", base), newUser(2, "RS", base)
+	addUsers(t, r, first, second)
+
+	_, err := r.Update(context.Background(), domain.User{Id: second.Id, Nickname: first.Nickname})
+
+	assertStatus(t, err, codes.InvalidArgument, "nickname already exist")
+}
+
+func testUpdateEmailExist(t *testing.T, r repo.UserRepo) {
+	first, second := newUser(1, "RS", base), newUser(2, "RS", base)
+	addUsers(t, r, first, second)
+
+	_, err := r.Update(context.Background(), domain.User{Id: second.Id, Email: first.Email})
+
+	assertStatus(t, err, codes.InvalidArgument, "email already exist")
+}
+
+func testDelete(t *testing.T, r repo.UserRepo) {
+	first, second := newUser(1, "RS", base), newUser(2, "RS", base.Add(time.Hour))
+	addUsers(t, r, first, second)
+
+	err := r.Delete(context.Background(), first.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id}, ids(allUsers(t, r)))
+}
+
+func testDeleteNotFound(t *testing.T, r repo.UserRepo) {
+	err := r.Delete(context.Background(), uuid.New())
+
+	assertStatus(t, err, codes.NotFound, "no user in database")
+}
+
+func testGet(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+	addUsers(t, r, user, newUser(2, "DE", base))
+
+	stored, err := r.Get(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, stored.Id)
+	assert.Equal(t, user.Nickname, stored.Nickname)
+	assert.Equal(t, user.Country, stored.Country)
+}
+
+func testGetNotFound(t *testing.T, r repo.UserRepo) {
+	_, err := r.Get(context.Background(), uuid.New())
+
+	assertStatus(t, err, codes.NotFound, "no user in database")
+}
+
+func testGetAfterAdd(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+	_, err := r.Get(context.Background(), user.Id)
+	assertStatus(t, err, codes.NotFound, "no user in database")
+
+	addUsers(t, r, user)
+	stored, err := r.Get(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Nickname, stored.Nickname)
+}
+
+func testGetAfterUpdate(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+	addUsers(t, r, user)
+	_, err := r.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+
+	_, err = r.Update(context.Background(), domain.User{Id: user.Id, Firstname: "changed"})
+	assert.Nil(t, err)
+	stored, err := r.Get(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "changed", stored.Firstname)
+}
+
+func testGetAfterDelete(t *testing.T, r repo.UserRepo) {
+	user := newUser(1, "RS", base)
+	addUsers(t, r, user)
+	_, err := r.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+
+	err = r.Delete(context.Background(), user.Id)
+	assert.Nil(t, err)
+	_, err = r.Get(context.Background(), user.Id)
+
+	assertStatus(t, err, codes.NotFound, "no user in database")
+}
+
+func testGetPageCountry(t *testing.T, r repo.UserRepo) {
+	rs1, de, rs2 := newUser(1, "RS", base), newUser(2, "DE", base.Add(time.Hour)), newUser(3, "RS", base.Add(2*time.Hour))
+	addUsers(t, r, rs1, de, rs2)
+
+	users, err := r.GetPage(context.Background(), &proto.UserPageRequest_UserFilterOptions{Country: "RS"}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{rs1.Id, rs2.Id}, ids(users))
+}
+
+func testGetPageCreated(t *testing.T, r repo.UserRepo) {
+	first, second, third := newUser(1, "RS", base), newUser(2, "RS", base.Add(time.Hour)), newUser(3, "RS", base.Add(2*time.Hour))
+	addUsers(t, r, first, second, third)
+
+	users, err := r.GetPage(context.Background(), &proto.UserPageRequest_UserFilterOptions{
+		CreatedFrom: timestamppb.New(first.CreatedAt),
+		CreatedTo:   timestamppb.New(third.CreatedAt),
+	}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{second.Id}, ids(users))
+}
+
+func testGetPagePagination(t *testing.T, r repo.UserRepo) {
+	// arrange, added in reverse so the order doesn't come from insertion
+	var users []domain.User
+	for i := 0; i < 5; i++ {
+		users = append(users, newUser(i, "RS", base.Add(time.Duration(i)*time.Hour)))
+	}
+	for i := len(users) - 1; i >= 0; i-- {
+		addUsers(t, r, users[i])
+	}
+
+	// act
+	page, pageErr := r.GetPage(context.Background(), nil, 1, 2)
+	last, lastErr := r.GetPage(context.Background(), nil, 4, 2)
+	after, afterErr := r.GetPage(context.Background(), nil, 5, 2)
+
+	// assert
+	assert.Nil(t, pageErr)
+	assert.Nil(t, lastErr)
+	assert.Nil(t, afterErr)
+	assert.Equal(t, []uuid.UUID{users[1].Id, users[2].Id}, ids(page))
+	assert.Equal(t, []uuid.UUID{users[4].Id}, ids(last))
+	assert.Empty(t, after)
+	assert.Equal(t, ids(users), ids(allUsers(t, r)))
+}
+
+func testCountByCountry(t *testing.T, r repo.UserRepo) {
+	addUsers(t, r, newUser(1, "RS", base), newUser(2, "DE", base), newUser(3, "RS", base))
+
+	counts, err := r.CountByCountry(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"RS": 2, "DE": 1}, counts)
+}
+
+func testContextCanceled(t *testing.T, r repo.UserRepo) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, addErr := r.Add(ctx, newUser(1, "RS", base))
+	_, pageErr := r.GetPage(ctx, nil, 0, 10)
+
+	assertStatus(t, addErr, codes.Canceled, "")
+	assertStatus(t, pageErr, codes.Canceled, "")
+	assert.Empty(t, allUsers(t, r))
+}
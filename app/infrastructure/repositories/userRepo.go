@@ -1,23 +1,41 @@
 package repo
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"usermanager/app/domain"
 	proto "usermanager/app/ui/protos/user"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 const UNIQUE_INDEX_VIOLATION_CODE = "23505"
 
+// Postgres cancels a query running longer than statement_timeout with it.
+const QUERY_CANCELED_CODE = "57014"
+
+// Sqlite errors name the column instead of the constraint,
+// like "UNIQUE constraint failed: users.nickname".
+var sqliteUniqueColumns = map[string]string{
+	domain.UniqueConstraintNickname: "users.nickname",
+	domain.UniqueConstraintEmail:    "users.email",
+}
+
 type UserRepo interface {
-	Add(user domain.User) error
-	Update(user domain.User) error
-	GetPage(filter *proto.UserPageRequest_UserFilterOptions, offset int32, limit int32) (users []domain.User, err error)
-	Delete(id uuid.UUID) error
+	Add(ctx context.Context, user domain.User) (domain.User, error)
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.User, error)
+	GetPage(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions, offset int32, limit int32) (users []domain.User, err error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	CountByCountry(ctx context.Context) (map[string]int64, error)
 }
 
 type userRepo struct {
@@ -29,29 +47,66 @@ func NewUserRepo(gormDb *gorm.DB) *userRepo {
 	return &userRepo{db: gormDb}
 }
 
-// Add user method. Returns an error if ocurred.
-func (r *userRepo) Add(user domain.User) error {
-	if err := r.db.Create(&user).Error; err != nil {
-		return handleErr(err)
+// Add user method. Creation time is set by the repository unless
+// provided. Returns stored user or error if ocurred.
+func (r *userRepo) Add(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := r.db.WithContext(ctx).Create(&user).Error; err != nil {
+		return domain.User{}, handleErr(err)
 	}
-	return nil
+	return user, nil
+}
+
+// Update user method. Only non empty fields are changed.
+// Returns stored user or error if ocurred.
+func (r *userRepo) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	var stored domain.User
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.
+			Model(&domain.User{}).
+			Where("id = ?", user.Id).
+			Updates(domain.User{
+				Firstname: user.Firstname,
+				Lastname:  user.Lastname,
+				Nickname:  user.Nickname,
+				Password:  user.Password,
+				Email:     user.Email,
+				Country:   user.Country})
+
+		if res.Error != nil {
+			return handleErr(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return status.Error(codes.NotFound, "no user in database")
+		}
+
+		// read the row back in the same transaction, so it is the
+		// updated one with the stored creation time
+		if err := tx.Where("id = ?", user.Id).Take(&stored).Error; err != nil {
+			if err := contextErr(err); err != nil {
+				return err
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = handleErr(err)
+		}
+		return domain.User{}, err
+	}
+	return stored, nil
 }
 
-// Update user method. Returns an error if ocurred.
-func (r *userRepo) Update(user domain.User) error {
-	res := r.db.
-		Model(&user).
-		Where("id = ?", user.Id).
-		Updates(domain.User{
-			Firstname: user.Firstname,
-			Lastname:  user.Lastname,
-			Nickname:  user.Nickname,
-			Password:  user.Password,
-			Email:     user.Email,
-			Country:   user.Country})
+// Delete user method. Returns error if ocurred.
+func (r *userRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(domain.User{}, id)
 
 	if res.Error != nil {
-		return handleErr(res.Error)
+		if err := contextErr(res.Error); err != nil {
+			return err
+		}
+		return status.Errorf(codes.Internal, "cannot delete user %v", res.Error)
 	}
 	if res.RowsAffected == 0 {
 		return status.Error(codes.NotFound, "no user in database")
@@ -59,28 +114,47 @@ func (r *userRepo) Update(user domain.User) error {
 	return nil
 }
 
-// Delete user method. Returns error if ocurred.
-func (r *userRepo) Delete(id uuid.UUID) error {
-	res := r.db.Delete(domain.User{}, id)
+// Get user by id. Returns NotFound error if there is no such user.
+func (r *userRepo) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return r.get(r.db.WithContext(ctx), id)
+}
+
+// Get user by id from the primary, replicas may not have the latest
+// write yet.
+func (r *userRepo) GetPrimary(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return r.get(r.db.WithContext(ctx).Clauses(dbresolver.Write), id)
+}
+
+func (r *userRepo) get(db *gorm.DB, id uuid.UUID) (domain.User, error) {
+	var user domain.User
+	res := db.Where("id = ?", id).Limit(1).Find(&user)
 
 	if res.Error != nil {
-		return status.Errorf(codes.Internal, "cannot delete user %v", res.Error)
+		if err := contextErr(res.Error); err != nil {
+			return user, err
+		}
+		return user, status.Error(codes.Internal, res.Error.Error())
 	}
 	if res.RowsAffected == 0 {
-		return status.Error(codes.NotFound, "no user in database")
+		return user, status.Error(codes.NotFound, "no user in database")
 	}
-	return nil
+	return user, nil
 }
 
-// Get user page method based on the provided filter params.
+// Get user page method based on the provided filter params. Users are
+// ordered by creation time so that pages are stable.
 // Returns list of users or error if ocurred.
-func (r *userRepo) GetPage(filter *proto.UserPageRequest_UserFilterOptions,
+func (r *userRepo) GetPage(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions,
 	offset int32, limit int32) (users []domain.User, err error) {
 
-	// set limit and offset
+	// set limit and offset, no limit means all users
 	selectQuery := r.db.
-		Limit(int(limit)).
+		WithContext(ctx).
+		Order("created_at, id").
 		Offset(int(offset))
+	if limit > 0 {
+		selectQuery = selectQuery.Limit(int(limit))
+	}
 
 	// based on filter params create where condition
 	if filter != nil {
@@ -100,30 +174,101 @@ func (r *userRepo) GetPage(filter *proto.UserPageRequest_UserFilterOptions,
 
 	// check for the users
 	if err := selectQuery.Find(&users).Error; err != nil {
+		if err := contextErr(err); err != nil {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	return users, nil
 }
 
+// Count users by country method. Returns number of users
+// for each country or error if ocurred.
+func (r *userRepo) CountByCountry(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Country string
+		Count   int64
+	}
+
+	err := r.db.
+		WithContext(ctx).
+		Model(&domain.User{}).
+		Select("country, count(*) as count").
+		Group("country").
+		Scan(&rows).Error
+
+	if err != nil {
+		if err := contextErr(err); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Country] = row.Count
+	}
+	return counts, nil
+}
+
 // If there was an error it is important to handle it
 // and check the uniqueness of the name and email.
 func handleErr(err error) error {
+	if err := contextErr(err); err != nil {
+		return err
+	}
 	if isUniqueConstraintError(err, domain.UniqueConstraintNickname) {
-		return status.Error(codes.InvalidArgument, "nickname already exist")
+		return alreadyExist("nickname", domain.ErrorReasonNicknameTaken)
 	}
 	if isUniqueConstraintError(err, domain.UniqueConstraintEmail) {
-		return status.Error(codes.InvalidArgument, "email already exist")
+		return alreadyExist("email", domain.ErrorReasonEmailTaken)
 	}
 	return status.Error(codes.Internal, err.Error())
 }
 
-// Based on a constraint and pg error code check
+// Unique constraint error with the reason in the details.
+func alreadyExist(field, reason string) error {
+	st := status.New(codes.InvalidArgument, field+" already exist")
+
+	details, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: domain.ErrorDomain,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return details.Err()
+}
+
+// If the query failed because the request was canceled or its
+// deadline or the statement timeout exceeded, returns error with the
+// matching status code. Otherwise returns nil, so database errors are
+// not hidden behind the state of the request context.
+func contextErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &pgErr) && pgErr.Code == QUERY_CANCELED_CODE {
+		return status.Error(codes.DeadlineExceeded, "database query deadline exceeded")
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, "database query canceled")
+	}
+	return nil
+}
+
+// Based on a constraint and pg or sqlite error code check
 // whether the condition of uniqueness is violated.
 func isUniqueConstraintError(err error, constraintName string) bool {
-	if pqErr, ok := err.(*pgconn.PgError); ok {
+	var pqErr *pgconn.PgError
+	if errors.As(err, &pqErr) {
 		return pqErr.Code == UNIQUE_INDEX_VIOLATION_CODE &&
 			pqErr.ConstraintName == constraintName
 	}
+	var sqliteErr sqlite3.Error
+	if column, ok := sqliteUniqueColumns[constraintName]; ok && errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+			strings.HasSuffix(sqliteErr.Error(), column)
+	}
 	return false
 }
@@ -1,10 +1,13 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"regexp"
 	"testing"
+	"time"
 	"usermanager/app/domain"
 	proto "usermanager/app/ui/protos/user"
 
@@ -16,6 +19,7 @@ import (
 	"google.golang.org/grpc/status"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 func createUserRepo() (*userRepo, sqlmock.Sqlmock) {
@@ -55,7 +59,7 @@ func TestAdd_NickAlreadyExist_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Add(domain.User{})
+	_, res := userRepo.Add(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -81,7 +85,7 @@ func TestAdd_EmailAlreadyExist_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Add(domain.User{})
+	_, res := userRepo.Add(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -103,7 +107,7 @@ func TestAdd_ErrOcurred_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Add(domain.User{})
+	_, res := userRepo.Add(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -122,7 +126,7 @@ func TestAdd_ShouldPass(t *testing.T) {
 	mock.ExpectCommit()
 
 	// act
-	res := userRepo.Add(domain.User{})
+	_, res := userRepo.Add(context.Background(), domain.User{})
 
 	// assert
 	assert.Nil(t, res)
@@ -138,10 +142,10 @@ func TestUpdate_UserDoesntExist_ShouldReturnErr(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users"`)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectCommit()
+	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Update(domain.User{})
+	_, res := userRepo.Update(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -167,7 +171,7 @@ func TestUpdate_NickAlreadyExist_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Update(domain.User{})
+	_, res := userRepo.Update(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -193,7 +197,7 @@ func TestUpdate_EmailAlreadyExist_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Update(domain.User{})
+	_, res := userRepo.Update(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -215,7 +219,7 @@ func TestUpdate_ErrOcurred_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	res := userRepo.Update(domain.User{})
+	_, res := userRepo.Update(context.Background(), domain.User{})
 
 	// assert
 	assert.NotNil(t, res)
@@ -228,16 +232,25 @@ func TestUpdate_ShouldPass(t *testing.T) {
 	userRepo, mock := createUserRepo()
 
 	// arrange
+	createdAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "first_name", "created_at"}).
+		AddRow(uuid.Nil, "changed", createdAt)
+
 	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users"`)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1 LIMIT $2`)).
+		WillReturnRows(rows)
 	mock.ExpectCommit()
 
 	// act
-	res := userRepo.Update(domain.User{})
+	stored, res := userRepo.Update(context.Background(), domain.User{Firstname: "changed"})
 
 	// assert
 	assert.Nil(t, res)
+	assert.Equal(t, "changed", stored.Firstname)
+	assert.Equal(t, createdAt, stored.CreatedAt)
+	assert.Nil(t, mock.ExpectationsWereMet())
 }
 
 func TestDelete_ErrOcurred_ShouldReturnErr(t *testing.T) {
@@ -256,7 +269,7 @@ func TestDelete_ErrOcurred_ShouldReturnErr(t *testing.T) {
 	mock.ExpectRollback()
 
 	// act
-	err := userRepo.Delete(userId)
+	err := userRepo.Delete(context.Background(), userId)
 
 	// assert
 	assert.NotNil(t, err)
@@ -279,7 +292,7 @@ func TestDeleteUser_UserNotFound_ShouldReturnErr(t *testing.T) {
 	mock.ExpectCommit()
 
 	// act
-	res := userRepo.Delete(mockUserId)
+	res := userRepo.Delete(context.Background(), mockUserId)
 
 	// assert
 	assert.NotNil(t, res)
@@ -300,12 +313,53 @@ func TestDeleteUser_ShouldPass(t *testing.T) {
 	mock.ExpectCommit()
 
 	// act
-	res := userRepo.Delete(mockUserId)
+	res := userRepo.Delete(context.Background(), mockUserId)
 
 	// assert
 	assert.Nil(t, res)
 }
 
+func TestGetUser_ErrOcurred_ShouldReturnErr(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1 LIMIT $2`)).
+		WillReturnError(errors.New("test err"))
+
+	// act
+	_, err := userRepo.Get(context.Background(), uuid.New())
+
+	// assert
+	statusErr := status.Convert(err)
+	assert.Equal(t, codes.Internal, statusErr.Code())
+	assert.Equal(t, "test err", statusErr.Message())
+}
+
+func TestGetPrimary_WithReplica_ShouldReadPrimary(t *testing.T) {
+	primaryDb, primaryMock, _ := sqlmock.New()
+	replicaDb, replicaMock, _ := sqlmock.New()
+	gdb, err := gorm.Open(postgres.New(postgres.Config{DriverName: "postgres", Conn: primaryDb}), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, gdb.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{postgres.New(postgres.Config{DriverName: "postgres", Conn: replicaDb})},
+	})))
+	userRepo := NewUserRepo(gdb)
+
+	// arrange
+	id := uuid.New()
+	primaryMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1 LIMIT $2`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(id.String(), "aki"))
+
+	// act
+	user, err := userRepo.GetPrimary(context.Background(), id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "aki", user.Nickname)
+	assert.Nil(t, primaryMock.ExpectationsWereMet())
+	assert.Nil(t, replicaMock.ExpectationsWereMet())
+}
+
 func TestGetUserList_ErrOcurred_ShouldReturnErr(t *testing.T) {
 	userRepo, mock := createUserRepo()
 
@@ -317,7 +371,7 @@ func TestGetUserList_ErrOcurred_ShouldReturnErr(t *testing.T) {
 		WillReturnError(errors.New(expectedErr))
 
 	// act
-	res, err := userRepo.GetPage(&proto.UserPageRequest_UserFilterOptions{}, 1, 1)
+	res, err := userRepo.GetPage(context.Background(), &proto.UserPageRequest_UserFilterOptions{}, 1, 1)
 
 	// assert
 	assert.Equal(t, 0, len(res))
@@ -339,10 +393,134 @@ func TestGetUserList_ShouldPass(t *testing.T) {
 		WillReturnRows(rows)
 
 	// act
-	res, err := userRepo.GetPage(&proto.UserPageRequest_UserFilterOptions{}, 1, 2)
+	res, err := userRepo.GetPage(context.Background(), &proto.UserPageRequest_UserFilterOptions{}, 1, 2)
 
 	// assert
 	assert.Nil(t, err)
 	assert.NotNil(t, res)
 	assert.Equal(t, 2, len(res))
 }
+
+func TestGetUserList_NoLimit_ShouldNotLimitQuery(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	mock.ExpectQuery(`^SELECT \* FROM "users" ORDER BY created_at, id$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"))
+
+	// act
+	res, err := userRepo.GetPage(context.Background(), nil, 0, 0)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+}
+
+func TestGetUserList_DeadlineExceeded_ShouldReturnDeadlineExceeded(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// like pgconn, the driver wraps the context error
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users"`)).
+		WillReturnError(fmt.Errorf("timeout: %w", context.DeadlineExceeded))
+
+	// act
+	res, err := userRepo.GetPage(ctx, &proto.UserPageRequest_UserFilterOptions{}, 1, 1)
+
+	// assert
+	assert.Nil(t, res)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestGetUserList_StatementTimeout_ShouldReturnDeadlineExceeded(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users"`)).
+		WillReturnError(&pgconn.PgError{
+			Code:    QUERY_CANCELED_CODE,
+			Message: "canceling statement due to statement timeout",
+		})
+
+	// act
+	res, err := userRepo.GetPage(context.Background(), &proto.UserPageRequest_UserFilterOptions{}, 1, 1)
+
+	// assert
+	assert.Nil(t, res)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestContextErr_DbErr_ShouldNotMapToContextStatus(t *testing.T) {
+	assert.Nil(t, contextErr(errors.New("connection reset")))
+	assert.Equal(t, codes.Canceled, status.Code(contextErr(fmt.Errorf("query: %w", context.Canceled))))
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(contextErr(context.DeadlineExceeded)))
+}
+
+func TestAdd_ContextCanceled_ShouldReturnCanceled(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mock.ExpectBegin().WillReturnError(context.Canceled)
+
+	// act
+	_, res := userRepo.Add(ctx, domain.User{})
+
+	// assert
+	assert.Equal(t, codes.Canceled, status.Code(res))
+}
+
+func TestDeleteUser_DeadlineExceeded_ShouldReturnDeadlineExceeded(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users"`)).
+		WillReturnError(context.DeadlineExceeded)
+	mock.ExpectRollback()
+
+	// act
+	res := userRepo.Delete(context.Background(), uuid.New())
+
+	// assert
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(res))
+}
+
+func TestCountByCountry_ShouldPass(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	rows := sqlmock.NewRows([]string{"country", "count"}).
+		AddRow("RS", 3).
+		AddRow("DE", 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT country, count(*) as count FROM "users" GROUP BY "country"`)).
+		WillReturnRows(rows)
+
+	// act
+	res, err := userRepo.CountByCountry(context.Background())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"RS": 3, "DE": 1}, res)
+}
+
+func TestCountByCountry_ErrOcurred_ShouldReturnErr(t *testing.T) {
+	userRepo, mock := createUserRepo()
+
+	// arrange
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT country, count(*) as count FROM "users"`)).
+		WillReturnError(errors.New("test err"))
+
+	// act
+	res, err := userRepo.CountByCountry(context.Background())
+
+	// assert
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
@@ -0,0 +1,172 @@
+package repo
+
+import (
+	"context"
+	"usermanager/app/domain"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type WebhookRepo interface {
+	Add(ctx context.Context, webhook domain.Webhook) error
+	Update(ctx context.Context, webhook domain.Webhook) error
+	Get(ctx context.Context, id uuid.UUID) (domain.Webhook, error)
+	List(ctx context.Context) ([]domain.Webhook, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	AddDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookId uuid.UUID, limit int) ([]domain.WebhookDelivery, error)
+	// Reset failures of the webhook after successful delivery.
+	RecordSuccess(ctx context.Context, id uuid.UUID) error
+	// Count failed delivery and disable the webhook when it failed
+	// disableAfter times in a row. Returns whether it was disabled.
+	RecordFailure(ctx context.Context, id uuid.UUID, disableAfter int) (bool, error)
+}
+
+type webhookRepo struct {
+	db *gorm.DB
+}
+
+// Create new webhook repository with Gorm ORM library.
+func NewWebhookRepo(gormDb *gorm.DB) *webhookRepo {
+	return &webhookRepo{db: gormDb}
+}
+
+// Add webhook method. Returns an error if ocurred.
+func (r *webhookRepo) Add(ctx context.Context, webhook domain.Webhook) error {
+	if err := r.db.WithContext(ctx).Create(&webhook).Error; err != nil {
+		return queryErr(err)
+	}
+	return nil
+}
+
+// Update all webhook fields. Returns an error if ocurred.
+func (r *webhookRepo) Update(ctx context.Context, webhook domain.Webhook) error {
+	res := r.db.
+		WithContext(ctx).
+		Model(&webhook).
+		Select("url", "event_types", "secret", "enabled", "consecutive_failures").
+		Updates(&webhook)
+
+	if res.Error != nil {
+		return queryErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return status.Error(codes.NotFound, "no webhook in database")
+	}
+	return nil
+}
+
+// Get webhook by id. Returns NotFound error if there is no such webhook.
+func (r *webhookRepo) Get(ctx context.Context, id uuid.UUID) (domain.Webhook, error) {
+	var webhook domain.Webhook
+	res := r.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&webhook)
+
+	if res.Error != nil {
+		return webhook, queryErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return webhook, status.Error(codes.NotFound, "no webhook in database")
+	}
+	return webhook, nil
+}
+
+// List all webhooks ordered by creation time.
+func (r *webhookRepo) List(ctx context.Context) ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	if err := r.db.WithContext(ctx).Order("created_at, id").Find(&webhooks).Error; err != nil {
+		return nil, queryErr(err)
+	}
+	return webhooks, nil
+}
+
+// Delete webhook with its delivery log. Returns error if ocurred.
+func (r *webhookRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&domain.Webhook{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return status.Error(codes.NotFound, "no webhook in database")
+		}
+		return tx.Delete(&domain.WebhookDelivery{}, "webhook_id = ?", id).Error
+	})
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return queryErr(err)
+}
+
+// Add delivery attempt to the delivery log.
+func (r *webhookRepo) AddDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).Create(&delivery).Error; err != nil {
+		return queryErr(err)
+	}
+	return nil
+}
+
+// List newest deliveries of the webhook, at most limit of them.
+func (r *webhookRepo) ListDeliveries(ctx context.Context, webhookId uuid.UUID, limit int) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.db.
+		WithContext(ctx).
+		Where("webhook_id = ?", webhookId).
+		Order("created_at desc, attempt desc").
+		Limit(limit).
+		Find(&deliveries).Error
+
+	if err != nil {
+		return nil, queryErr(err)
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepo) RecordSuccess(ctx context.Context, id uuid.UUID) error {
+	err := r.db.
+		WithContext(ctx).
+		Model(&domain.Webhook{}).
+		Where("id = ? AND consecutive_failures > 0", id).
+		Update("consecutive_failures", 0).Error
+
+	if err != nil {
+		return queryErr(err)
+	}
+	return nil
+}
+
+func (r *webhookRepo) RecordFailure(ctx context.Context, id uuid.UUID, disableAfter int) (bool, error) {
+	disabled := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Model(&domain.Webhook{}).
+			Where("id = ?", id).
+			Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+		if err != nil || disableAfter <= 0 {
+			return err
+		}
+
+		res := tx.
+			Model(&domain.Webhook{}).
+			Where("id = ? AND enabled AND consecutive_failures >= ?", id, disableAfter).
+			Update("enabled", false)
+		disabled = res.RowsAffected > 0
+		return res.Error
+	})
+
+	if err != nil {
+		return false, queryErr(err)
+	}
+	return disabled, nil
+}
+
+// Error of a query with the matching status code.
+func queryErr(err error) error {
+	if err := contextErr(err); err != nil {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
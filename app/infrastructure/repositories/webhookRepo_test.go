@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createWebhookRepo(t *testing.T) *webhookRepo {
+	c := db.Config{Driver: db.DriverSqlite, Path: filepath.Join(t.TempDir(), "webhooks.db")}
+	assert.Nil(t, db.MigrateDb(c))
+	gdb, err := db.OpenDb(c)
+	assert.Nil(t, err)
+	return NewWebhookRepo(gdb)
+}
+
+func newWebhook() domain.Webhook {
+	return domain.Webhook{
+		Id:         uuid.New(),
+		Url:        "https://partner.example.com/hooks",
+		EventTypes: []domain.UserEventType{domain.UserCreated, domain.UserDeleted},
+		Secret:     "secret",
+		Enabled:    true,
+	}
+}
+
+func TestWebhookRepo_AddAndGet_ShouldPass(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhook := newWebhook()
+
+	// act
+	addErr := r.Add(context.Background(), webhook)
+	stored, getErr := r.Get(context.Background(), webhook.Id)
+
+	// assert
+	assert.Nil(t, addErr)
+	assert.Nil(t, getErr)
+	assert.Equal(t, webhook.Url, stored.Url)
+	assert.Equal(t, webhook.EventTypes, stored.EventTypes)
+	assert.True(t, stored.Enabled)
+}
+
+func TestWebhookRepo_GetMissing_ShouldReturnNotFound(t *testing.T) {
+	r := createWebhookRepo(t)
+
+	_, err := r.Get(context.Background(), uuid.New())
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWebhookRepo_Update_ShouldReplaceFields(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhook := newWebhook()
+	assert.Nil(t, r.Add(context.Background(), webhook))
+
+	// act
+	webhook.Url = "https://other.example.com"
+	webhook.EventTypes = nil
+	webhook.Enabled = false
+	err := r.Update(context.Background(), webhook)
+
+	// assert
+	assert.Nil(t, err)
+	stored, _ := r.Get(context.Background(), webhook.Id)
+	assert.Equal(t, "https://other.example.com", stored.Url)
+	assert.Empty(t, stored.EventTypes)
+	assert.False(t, stored.Enabled)
+}
+
+func TestWebhookRepo_Delete_ShouldDeleteDeliveries(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhook := newWebhook()
+	assert.Nil(t, r.Add(context.Background(), webhook))
+	assert.Nil(t, r.AddDelivery(context.Background(), domain.WebhookDelivery{Id: uuid.New(), WebhookId: webhook.Id}))
+
+	// act
+	err := r.Delete(context.Background(), webhook.Id)
+	missingErr := r.Delete(context.Background(), webhook.Id)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(missingErr))
+	deliveries, _ := r.ListDeliveries(context.Background(), webhook.Id, 10)
+	assert.Empty(t, deliveries)
+}
+
+func TestWebhookRepo_ListDeliveries_ShouldReturnNewestFirst(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhookId := uuid.New()
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, r.AddDelivery(context.Background(), domain.WebhookDelivery{
+			Id:        uuid.New(),
+			WebhookId: webhookId,
+			Attempt:   i,
+			CreatedAt: time.Now().Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	deliveries, err := r.ListDeliveries(context.Background(), webhookId, 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, 3, deliveries[0].Attempt)
+	assert.Equal(t, 2, deliveries[1].Attempt)
+}
+
+func TestWebhookRepo_RecordFailure_ShouldDisableAfterLimit(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhook := newWebhook()
+	assert.Nil(t, r.Add(context.Background(), webhook))
+
+	// act
+	first, firstErr := r.RecordFailure(context.Background(), webhook.Id, 2)
+	second, secondErr := r.RecordFailure(context.Background(), webhook.Id, 2)
+
+	// assert
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	assert.False(t, first)
+	assert.True(t, second)
+	stored, _ := r.Get(context.Background(), webhook.Id)
+	assert.False(t, stored.Enabled)
+	assert.Equal(t, 2, stored.ConsecutiveFailures)
+}
+
+func TestWebhookRepo_RecordSuccess_ShouldResetFailures(t *testing.T) {
+	r := createWebhookRepo(t)
+	webhook := newWebhook()
+	assert.Nil(t, r.Add(context.Background(), webhook))
+	_, _ = r.RecordFailure(context.Background(), webhook.Id, 5)
+
+	err := r.RecordSuccess(context.Background(), webhook.Id)
+
+	assert.Nil(t, err)
+	stored, _ := r.Get(context.Background(), webhook.Id)
+	assert.Equal(t, 0, stored.ConsecutiveFailures)
+}
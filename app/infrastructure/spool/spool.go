@@ -0,0 +1,345 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Fsync policies of the spool file.
+const (
+	// sync after every append and ack, nothing is lost on power failure
+	FsyncAlways = "always"
+	// sync every FsyncInterval, at most one interval is lost
+	FsyncInterval = "interval"
+	// leave it to the operating system
+	FsyncNever = "never"
+)
+
+// length and crc32 of the record payload
+const headerSize = 8
+
+var (
+	// Spool has no records.
+	ErrEmpty = errors.New("spool is empty")
+	// Record doesn't fit into the spool max size.
+	ErrFull = errors.New("spool is full")
+)
+
+// Spool settings
+type Config struct {
+	// spool file, read position is kept in <Path>.offset
+	Path string
+	// max size of the spool file, zero is unlimited. Acked records are
+	// compacted away when the limit is reached.
+	MaxBytes int64
+	// always, interval or never
+	Fsync         string
+	FsyncInterval time.Duration
+}
+
+// Append-only queue of records on disk. Records are read in the order
+// they were appended and survive process restarts until they are acked.
+type Spool interface {
+	Append(record []byte) error
+	// Oldest record that is not acked yet, ErrEmpty if there is none.
+	Peek() ([]byte, error)
+	// Remove the oldest record.
+	Ack() error
+	// Number of records that are not acked.
+	Depth() int
+	Close() error
+}
+
+type fileSpool struct {
+	mu     sync.Mutex
+	config Config
+	file   *os.File
+	offset *os.File
+	// read position and end of the last complete record
+	readAt int64
+	size   int64
+	depth  int
+	dirty  bool
+	stop   chan struct{}
+}
+
+// Open the spool file, or create it if it doesn't exist. Records left
+// by the previous process are counted, and an incomplete record at the
+// end, written while the process crashed, is dropped.
+func NewSpool(c Config) (*fileSpool, error) {
+	file, err := os.OpenFile(c.Path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open spool: %w", err)
+	}
+	offset, err := os.OpenFile(c.Path+".offset", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot open spool offset: %w", err)
+	}
+
+	s := &fileSpool{
+		config: c,
+		file:   file,
+		offset: offset,
+		stop:   make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		s.file.Close()
+		s.offset.Close()
+		return nil, err
+	}
+
+	if c.Fsync == FsyncInterval && c.FsyncInterval > 0 {
+		go s.syncEvery(c.FsyncInterval)
+	}
+	return s, nil
+}
+
+func (s *fileSpool) Append(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := int64(headerSize + len(record))
+	if s.config.MaxBytes > 0 && s.size+n > s.config.MaxBytes {
+		// acked records don't count, drop them to make room
+		if s.size-s.readAt+n > s.config.MaxBytes {
+			return ErrFull
+		}
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, n)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(record))
+	copy(buf[headerSize:], record)
+	if _, err := s.file.WriteAt(buf, s.size); err != nil {
+		return fmt.Errorf("cannot append to spool: %w", err)
+	}
+
+	s.size += n
+	s.depth++
+	return s.synced(s.file)
+}
+
+func (s *fileSpool) Peek() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.depth == 0 {
+		return nil, ErrEmpty
+	}
+	record, _, err := s.read(s.readAt)
+	return record, err
+}
+
+func (s *fileSpool) Ack() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.depth == 0 {
+		return ErrEmpty
+	}
+	_, next, err := s.read(s.readAt)
+	if err != nil {
+		return err
+	}
+
+	s.depth--
+	if s.depth == 0 {
+		// drained, start over so the file doesn't grow forever
+		if err := s.file.Truncate(0); err != nil {
+			return fmt.Errorf("cannot truncate spool: %w", err)
+		}
+		s.size = 0
+		next = 0
+	}
+	s.readAt = next
+	return s.writeOffset()
+}
+
+func (s *fileSpool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
+
+// Sync and close the spool files.
+func (s *fileSpool) Close() error {
+	close(s.stop)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	syncErr := s.file.Sync()
+	if err := s.offset.Sync(); syncErr == nil {
+		syncErr = err
+	}
+	s.file.Close()
+	s.offset.Close()
+	return syncErr
+}
+
+// Load read position and count records after it. Must be called
+// before the spool is used.
+func (s *fileSpool) recover() error {
+	buf := make([]byte, 8)
+	if _, err := s.offset.ReadAt(buf, 0); err == nil {
+		s.readAt = int64(binary.BigEndian.Uint64(buf))
+	} else if err != io.EOF {
+		return fmt.Errorf("cannot read spool offset: %w", err)
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read spool: %w", err)
+	}
+	if s.readAt > info.Size() {
+		s.readAt = 0
+	}
+
+	s.size = info.Size()
+	end := s.readAt
+	for end < s.size {
+		_, next, err := s.read(end)
+		if err != nil {
+			log.Warn().Err(err).Msgf("dropping incomplete spool record at %v", end)
+			break
+		}
+		end = next
+		s.depth++
+	}
+
+	if end < s.size {
+		if err := s.file.Truncate(end); err != nil {
+			return fmt.Errorf("cannot truncate spool: %w", err)
+		}
+	}
+	s.size = end
+	return nil
+}
+
+// Move records that are not acked to the start of a new file. The
+// offset is reset before the new file replaces the old one, a crash in
+// between sends acked records again rather than losing any. Must be
+// called with lock held.
+func (s *fileSpool) compact() error {
+	path := s.config.Path + ".compact"
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot compact spool: %w", err)
+	}
+	live := io.NewSectionReader(s.file, s.readAt, s.size-s.readAt)
+	if _, err := io.Copy(file, live); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot compact spool: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot sync spool: %w", err)
+	}
+
+	size := s.size - s.readAt
+	buf := make([]byte, 8)
+	if _, err := s.offset.WriteAt(buf, 0); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write spool offset: %w", err)
+	}
+	if err := s.offset.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot sync spool offset: %w", err)
+	}
+	if err := os.Rename(path, s.config.Path); err != nil {
+		file.Close()
+		// still the old file, put its offset back
+		binary.BigEndian.PutUint64(buf, uint64(s.readAt))
+		s.offset.WriteAt(buf, 0)
+		return fmt.Errorf("cannot compact spool: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.readAt = 0
+	s.size = size
+	return nil
+}
+
+// Record at the position and position of the next one.
+func (s *fileSpool) read(at int64) ([]byte, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := s.file.ReadAt(header, at); err != nil {
+		return nil, 0, fmt.Errorf("cannot read spool record: %w", err)
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if at+headerSize+length > s.size {
+		return nil, 0, errors.New("spool record is incomplete")
+	}
+
+	record := make([]byte, length)
+	if _, err := s.file.ReadAt(record, at+headerSize); err != nil {
+		return nil, 0, fmt.Errorf("cannot read spool record: %w", err)
+	}
+	if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("spool record checksum mismatch")
+	}
+	return record, at + headerSize + length, nil
+}
+
+func (s *fileSpool) writeOffset() error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(s.readAt))
+	if _, err := s.offset.WriteAt(buf, 0); err != nil {
+		return fmt.Errorf("cannot write spool offset: %w", err)
+	}
+	return s.synced(s.offset, s.file)
+}
+
+// Sync files if the policy says so, otherwise mark them for the
+// interval sync. Must be called with lock held.
+func (s *fileSpool) synced(files ...*os.File) error {
+	if s.config.Fsync != FsyncAlways {
+		s.dirty = true
+		return nil
+	}
+	for _, f := range files {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("cannot sync spool: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *fileSpool) syncEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		if s.dirty {
+			if err := s.file.Sync(); err != nil {
+				log.Error().Err(err).Msg("cannot sync spool")
+			}
+			if err := s.offset.Sync(); err != nil {
+				log.Error().Err(err).Msg("cannot sync spool offset")
+			}
+			s.dirty = false
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,133 @@
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openSpool(t *testing.T, path string, maxBytes int64) *fileSpool {
+	s, err := NewSpool(Config{Path: path, MaxBytes: maxBytes, Fsync: FsyncAlways})
+	assert.Nil(t, err)
+	return s
+}
+
+func TestSpool_AppendPeekAck_ShouldKeepOrder(t *testing.T) {
+	s := openSpool(t, filepath.Join(t.TempDir(), "notifications.spool"), 0)
+	defer s.Close()
+
+	// act
+	assert.Nil(t, s.Append([]byte("first")))
+	assert.Nil(t, s.Append([]byte("second")))
+
+	// assert
+	assert.Equal(t, 2, s.Depth())
+	first, _ := s.Peek()
+	assert.Equal(t, "first", string(first))
+	assert.Nil(t, s.Ack())
+	second, _ := s.Peek()
+	assert.Equal(t, "second", string(second))
+	assert.Nil(t, s.Ack())
+	_, err := s.Peek()
+	assert.Equal(t, ErrEmpty, err)
+	assert.Equal(t, 0, s.Depth())
+}
+
+func TestSpool_Reopened_ShouldKeepUnackedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.spool")
+	s := openSpool(t, path, 0)
+	for _, r := range []string{"first", "second", "third"} {
+		assert.Nil(t, s.Append([]byte(r)))
+	}
+	assert.Nil(t, s.Ack())
+	assert.Nil(t, s.Close())
+
+	// act
+	reopened := openSpool(t, path, 0)
+	defer reopened.Close()
+
+	// assert
+	assert.Equal(t, 2, reopened.Depth())
+	record, _ := reopened.Peek()
+	assert.Equal(t, "second", string(record))
+}
+
+func TestSpool_IncompleteLastRecord_ShouldBeDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.spool")
+	s := openSpool(t, path, 0)
+	assert.Nil(t, s.Append([]byte("complete")))
+	assert.Nil(t, s.Close())
+
+	// arrange, record header without the payload
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 1, 2, 3, 4, 'x'})
+	assert.Nil(t, err)
+	f.Close()
+
+	// act
+	reopened := openSpool(t, path, 0)
+	defer reopened.Close()
+
+	// assert
+	assert.Equal(t, 1, reopened.Depth())
+	assert.Nil(t, reopened.Append([]byte("next")))
+	assert.Nil(t, reopened.Ack())
+	record, _ := reopened.Peek()
+	assert.Equal(t, "next", string(record))
+}
+
+func TestSpool_MaxBytes_ShouldReturnErrFull(t *testing.T) {
+	s := openSpool(t, filepath.Join(t.TempDir(), "notifications.spool"), 20)
+	defer s.Close()
+
+	assert.Nil(t, s.Append([]byte("0123456789")))
+	err := s.Append([]byte("0123456789"))
+
+	assert.Equal(t, ErrFull, err)
+	assert.Equal(t, 1, s.Depth())
+}
+
+func TestSpool_MaxBytesWithAckedRecords_ShouldCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.spool")
+	s := openSpool(t, path, 36)
+
+	// arrange
+	assert.Nil(t, s.Append([]byte("0123456789")))
+	assert.Nil(t, s.Append([]byte("abcdefghij")))
+	assert.Nil(t, s.Ack())
+
+	// act
+	err := s.Append([]byte("ABCDEFGHIJ"))
+
+	// assert
+	assert.Nil(t, err)
+	info, statErr := os.Stat(path)
+	assert.Nil(t, statErr)
+	assert.Equal(t, int64(36), info.Size())
+	assert.Nil(t, s.Close())
+
+	s = openSpool(t, path, 36)
+	defer s.Close()
+	assert.Equal(t, 2, s.Depth())
+	record, _ := s.Peek()
+	assert.Equal(t, "abcdefghij", string(record))
+	assert.Nil(t, s.Ack())
+	record, _ = s.Peek()
+	assert.Equal(t, "ABCDEFGHIJ", string(record))
+}
+
+func TestSpool_Drained_ShouldTruncateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.spool")
+	s := openSpool(t, path, 0)
+	defer s.Close()
+
+	assert.Nil(t, s.Append([]byte("record")))
+	assert.Nil(t, s.Ack())
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}
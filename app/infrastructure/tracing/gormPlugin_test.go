@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testUser struct {
+	Id      int
+	Country string
+}
+
+func createTracedDb(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, *tracetest.SpanRecorder) {
+	mockDb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create a stub db connection: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: mockDb}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	if err := db.Use(NewGormPlugin()); err != nil {
+		t.Fatalf("failed to register plugin: %v", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	return db, mock, recorder
+}
+
+func TestGormPlugin_Query_ShouldCreateChildSpan(t *testing.T) {
+	db, mock, recorder := createTracedDb(t)
+
+	// arrange
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "test_users" WHERE country = $1`)).
+		WithArgs("RS").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "country"}).AddRow(1, "RS"))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+
+	// act
+	var users []testUser
+	err := db.WithContext(ctx).Where("country = ?", "RS").Find(&users).Error
+	parent.End()
+
+	// assert
+	assert.Nil(t, err)
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "gorm.select", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	for _, a := range spans[0].Attributes() {
+		if a.Key == "db.statement" {
+			assert.Equal(t, `SELECT * FROM "test_users" WHERE country = $1`, a.Value.AsString())
+		}
+	}
+}
+
+func TestGormPlugin_QueryErr_ShouldRecordErr(t *testing.T) {
+	db, mock, recorder := createTracedDb(t)
+
+	// arrange
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "test_users"`)).
+		WillReturnError(errors.New("test err"))
+
+	// act
+	var users []testUser
+	err := db.WithContext(context.Background()).Find(&users).Error
+
+	// assert
+	assert.NotNil(t, err)
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestGormPlugin_Sqlite_ShouldSetDbSystem(t *testing.T) {
+	// arrange
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	if err := db.Use(NewGormPlugin()); err != nil {
+		t.Fatalf("failed to register plugin: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	// act
+	var n int
+	err = db.WithContext(context.Background()).Raw("SELECT 1").Scan(&n).Error
+
+	// assert
+	assert.Nil(t, err)
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Contains(t, spans[0].Attributes(), semconv.DBSystemSqlite)
+}
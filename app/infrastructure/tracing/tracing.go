@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOtlp   = "otlp"
+)
+
+const DefaultServiceName = "usermanager"
+
+type Config struct {
+	// none (default), stdout or otlp
+	Exporter string
+	// collector address for otlp exporter, host:port
+	OtlpEndpoint string
+	// send spans to collector without TLS
+	OtlpInsecure bool
+	ServiceName  string
+	// fraction of new traces that are sampled, 0 means all of them
+	SampleRatio float64
+}
+
+// Set up global tracer provider and W3C trace context propagation.
+// Returns function that flushes remaining spans on shutdown.
+func Init(ctx context.Context, c Config) (func(context.Context) error, error) {
+	// propagate context even when we don't export our own spans
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := c.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if c.SampleRatio > 0 && c.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(c.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, c Config) (sdktrace.SpanExporter, error) {
+	switch c.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOtlp:
+		opts := []otlptracegrpc.Option{}
+		if c.OtlpEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(c.OtlpEndpoint))
+		}
+		if c.OtlpInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %v", c.Exporter)
+	}
+}
+
+// Mark span as failed if error occurred.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
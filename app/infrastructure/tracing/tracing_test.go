@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInit_NoneExporter_ShouldPass(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{Exporter: ExporterNone})
+
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestInit_StdoutExporter_ShouldPass(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{Exporter: ExporterStdout})
+
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestInit_UnsupportedExporter_ShouldReturnErr(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{Exporter: "jaeger"})
+
+	assert.Nil(t, shutdown)
+	assert.NotNil(t, err)
+}
+
+func TestRecordError_ShouldSetErrorStatus(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, span := provider.Tracer("test").Start(context.Background(), "test")
+	RecordError(span, errors.New("test err"))
+	span.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "test err", spans[0].Status().Description)
+}
+
+func TestRecordError_NoErr_ShouldKeepStatus(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, span := provider.Tracer("test").Start(context.Background(), "test")
+	RecordError(span, nil)
+	span.End()
+
+	assert.Equal(t, codes.Unset, recorder.Ended()[0].Status().Code)
+}
@@ -0,0 +1,370 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
+	"usermanager/app/infrastructure/metrics"
+	repo "usermanager/app/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Webhook delivery settings
+type Config struct {
+	// deliveries running at once
+	Workers int
+	// deadline of one request
+	Timeout time.Duration
+	// attempts of one delivery, including the first one
+	MaxAttempts int
+	// delay before the first retry, doubled for every next one
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// webhook is disabled after this many failed deliveries
+	// in a row, zero never disables it
+	DisableAfter int
+	// allow loopback, private and link-local addresses,
+	// for development only
+	AllowInsecure bool
+}
+
+// JSON body of the webhook request.
+type Payload struct {
+	Sequence uint64    `json:"sequence"`
+	Type     string    `json:"type"`
+	UserId   string    `json:"user_id"`
+	Time     time.Time `json:"time"`
+	// not set for deleted users
+	User *User `json:"user,omitempty"`
+}
+
+type User struct {
+	Id        string     `json:"id"`
+	Firstname string     `json:"firstname"`
+	Lastname  string     `json:"lastname"`
+	Nickname  string     `json:"nickname"`
+	Email     string     `json:"email"`
+	Country   string     `json:"country"`
+	Created   *time.Time `json:"created,omitempty"`
+}
+
+type Dispatcher interface {
+	// Deliver user events to the webhooks until ctx is done.
+	Run(ctx context.Context)
+}
+
+type dispatcher struct {
+	repo    repo.WebhookRepo
+	hub     events.UserEventHub
+	config  Config
+	client  *http.Client
+	workers chan struct{}
+	wg      sync.WaitGroup
+	// waits before retry, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Create dispatcher that delivers events from the hub to every
+// enabled webhook subscribed to the event type.
+func NewDispatcher(r repo.WebhookRepo, hub events.UserEventHub, c Config) *dispatcher {
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 1
+	}
+	return &dispatcher{
+		repo:   r,
+		hub:    hub,
+		config: c,
+		client: &http.Client{
+			Timeout:   c.Timeout,
+			Transport: transport(c.AllowInsecure),
+			// a redirect could send the payload to another host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		workers: make(chan struct{}, c.Workers),
+		sleep:   sleep,
+	}
+}
+
+// HTTP transport that refuses to connect to internal addresses. The
+// address is checked after the host is resolved, so a host name that
+// resolves to an internal address is rejected too. Proxies are not
+// used, as the check would see only the proxy address.
+func transport(allowInsecure bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if allowInsecure {
+		return t
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !domain.WebhookAddressAllowed(ip) {
+				return fmt.Errorf("webhook address %v is not allowed", host)
+			}
+			return nil
+		},
+	}
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// Subscribe to the hub and dispatch events. When the dispatcher falls
+// behind and the hub closes the subscription, it resubscribes from the
+// last dispatched event. Waits for running deliveries before returning.
+func (d *dispatcher) Run(ctx context.Context) {
+	defer d.wg.Wait()
+
+	var from events.ResumePoint
+	for {
+		sub, err := d.hub.Subscribe(from, nil)
+		if err != nil {
+			log.Error().Err(err).Msg("webhook events were lost, continuing with new events")
+			from = events.ResumePoint{}
+			continue
+		}
+
+		lastSequence := d.consume(ctx, sub)
+		sub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		if lastSequence > 0 {
+			from = events.ResumePoint{AfterSequence: lastSequence}
+		}
+	}
+}
+
+// Dispatch events until the subscription is closed or ctx is done.
+// Returns sequence of the last dispatched event.
+func (d *dispatcher) consume(ctx context.Context, sub events.Subscription) uint64 {
+	var lastSequence uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return lastSequence
+		case event, ok := <-sub.Events():
+			if !ok {
+				log.Warn().Err(sub.Err()).Msg("webhook events subscription closed")
+				return lastSequence
+			}
+			d.dispatch(ctx, event)
+			lastSequence = event.Sequence
+		}
+	}
+}
+
+// Start delivery of the event to every subscribed webhook. Blocks
+// while all workers are busy.
+func (d *dispatcher) dispatch(ctx context.Context, event domain.UserEvent) {
+	webhooks, err := d.repo.List(ctx)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot list webhooks for event %v", event.Sequence)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Enabled || !webhook.Accepts(event.Type) {
+			continue
+		}
+
+		select {
+		case d.workers <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		d.wg.Add(1)
+		go func(webhook domain.Webhook) {
+			defer func() {
+				<-d.workers
+				d.wg.Done()
+			}()
+			d.deliver(ctx, webhook, event)
+		}(webhook)
+	}
+}
+
+// Post the event to the webhook, retrying with exponential backoff.
+// Every attempt is recorded in the delivery log. Failed delivery is
+// counted and may disable the webhook.
+func (d *dispatcher) deliver(ctx context.Context, webhook domain.Webhook, event domain.UserEvent) {
+	body, err := json.Marshal(payload(event))
+	if err != nil {
+		log.Error().Err(err).Msg("cannot encode webhook payload")
+		return
+	}
+	deliveryId := uuid.New()
+
+	for attempt := 1; ; attempt++ {
+		delivery := d.send(ctx, webhook, event, deliveryId, body, attempt)
+		if err := d.repo.AddDelivery(ctx, delivery); err != nil {
+			log.Error().Err(err).Msgf("cannot record delivery to webhook %v", webhook.Id)
+		}
+
+		if delivery.Success {
+			metrics.WebhookDeliveries.WithLabelValues("success").Inc()
+			if err := d.repo.RecordSuccess(ctx, webhook.Id); err != nil {
+				log.Error().Err(err).Msgf("cannot reset failures of webhook %v", webhook.Id)
+			}
+			return
+		}
+		metrics.WebhookDeliveries.WithLabelValues("failure").Inc()
+
+		if attempt >= d.config.MaxAttempts || !d.stillEnabled(ctx, webhook.Id) {
+			break
+		}
+		if err := d.sleep(ctx, d.backoff(attempt)); err != nil {
+			return
+		}
+	}
+
+	disabled, err := d.repo.RecordFailure(ctx, webhook.Id, d.config.DisableAfter)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot record failure of webhook %v", webhook.Id)
+	}
+	if disabled {
+		log.Warn().Msgf("webhook %v disabled after %v failed deliveries in a row",
+			webhook.Id, d.config.DisableAfter)
+	}
+}
+
+// Single signed request. Returns the attempt for the delivery log.
+func (d *dispatcher) send(ctx context.Context, webhook domain.Webhook, event domain.UserEvent,
+	deliveryId uuid.UUID, body []byte, attempt int) domain.WebhookDelivery {
+
+	delivery := domain.WebhookDelivery{
+		Id:            uuid.New(),
+		WebhookId:     webhook.Id,
+		EventSequence: event.Sequence,
+		EventType:     event.Type,
+		UserId:        event.UserId,
+		Attempt:       attempt,
+	}
+
+	start := time.Now()
+	statusCode, err := d.post(ctx, webhook, event, deliveryId, body)
+	delivery.Duration = time.Since(start)
+	delivery.StatusCode = statusCode
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return delivery
+}
+
+// Post body with the signature headers. Any status other than 2xx is
+// an error. Returns response status, zero if there was no response.
+func (d *dispatcher) post(ctx context.Context, webhook domain.Webhook, event domain.UserEvent,
+	deliveryId uuid.UUID, body []byte) (int, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("cannot create request: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "usermanager-webhooks")
+	req.Header.Set(EventHeader, eventName(event.Type))
+	req.Header.Set(DeliveryHeader, deliveryId.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Webhook could be disabled or deleted while the delivery was retried.
+func (d *dispatcher) stillEnabled(ctx context.Context, id uuid.UUID) bool {
+	webhook, err := d.repo.Get(ctx, id)
+	if status.Code(err) == codes.NotFound {
+		return false
+	}
+	return err != nil || webhook.Enabled
+}
+
+// Delay before the retry after the attempt.
+func (d *dispatcher) backoff(attempt int) time.Duration {
+	delay := d.config.Backoff
+	for i := 1; i < attempt && (d.config.MaxBackoff <= 0 || delay < d.config.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if d.config.MaxBackoff > 0 && delay > d.config.MaxBackoff {
+		delay = d.config.MaxBackoff
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Event type as "user.created", "user.updated" or "user.deleted".
+func eventName(t domain.UserEventType) string {
+	return "user." + t.String()
+}
+
+func payload(event domain.UserEvent) Payload {
+	p := Payload{
+		Sequence: event.Sequence,
+		Type:     eventName(event.Type),
+		UserId:   event.UserId.String(),
+		Time:     event.Time,
+	}
+	if u := event.User; u != nil {
+		p.User = &User{
+			Id:        u.Id.String(),
+			Firstname: u.Firstname,
+			Lastname:  u.Lastname,
+			Nickname:  u.Nickname,
+			Email:     u.Email,
+			Country:   u.Country,
+		}
+		if !u.CreatedAt.IsZero() {
+			created := u.CreatedAt
+			p.User.Created = &created
+		}
+	}
+	return p
+}
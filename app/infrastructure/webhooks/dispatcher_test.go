@@ -0,0 +1,237 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/db"
+	"usermanager/app/infrastructure/events"
+	repo "usermanager/app/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver that answers with the statuses in order, then with 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if len(r.statuses) > 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+	}
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// fake subscription with the events already in the channel
+type fakeSubscription struct {
+	events chan domain.UserEvent
+}
+
+func (s *fakeSubscription) Events() <-chan domain.UserEvent { return s.events }
+func (s *fakeSubscription) Err() error                      { return nil }
+func (s *fakeSubscription) Close()                          {}
+
+// fake hub that returns one subscription, then blocks
+type fakeHub struct {
+	events.UserEventHub
+	sub *fakeSubscription
+}
+
+func (h *fakeHub) Subscribe(from events.ResumePoint, filter events.EventFilter) (events.Subscription, error) {
+	sub := h.sub
+	h.sub = &fakeSubscription{events: make(chan domain.UserEvent)}
+	return sub, nil
+}
+
+func createRepo(t *testing.T) repo.WebhookRepo {
+	c := db.Config{Driver: db.DriverSqlite, Path: filepath.Join(t.TempDir(), "webhooks.db")}
+	assert.Nil(t, db.MigrateDb(c))
+	gdb, err := db.OpenDb(c)
+	assert.Nil(t, err)
+	return repo.NewWebhookRepo(gdb)
+}
+
+func addWebhook(t *testing.T, r repo.WebhookRepo, url string, eventTypes ...domain.UserEventType) domain.Webhook {
+	webhook := domain.Webhook{Id: uuid.New(), Url: url, EventTypes: eventTypes, Secret: "secret", Enabled: true}
+	assert.Nil(t, r.Add(context.Background(), webhook))
+	return webhook
+}
+
+func createdEvent() domain.UserEvent {
+	userId := uuid.New()
+	return domain.UserEvent{
+		Sequence: 7,
+		Type:     domain.UserCreated,
+		UserId:   userId,
+		User:     &domain.User{Id: userId, Nickname: "ana", Country: "RS"},
+		Time:     time.Now().UTC(),
+	}
+}
+
+// dispatcher that records retry delays instead of waiting, test
+// servers listen on loopback so internal addresses are allowed
+func createDispatcher(r repo.WebhookRepo, hub events.UserEventHub, c Config) (*dispatcher, *[]time.Duration) {
+	c.AllowInsecure = true
+	d := NewDispatcher(r, hub, c)
+	var delays []time.Duration
+	d.sleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+	return d, &delays
+}
+
+func TestRun_ShouldDeliverSignedEventToSubscribedWebhooks(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	r := createRepo(t)
+
+	// arrange
+	webhook := addWebhook(t, r, server.URL, domain.UserCreated)
+	addWebhook(t, r, server.URL, domain.UserDeleted)
+	event := createdEvent()
+	hub := &fakeHub{sub: &fakeSubscription{events: make(chan domain.UserEvent, 1)}}
+	hub.sub.events <- event
+	d, _ := createDispatcher(r, hub, Config{MaxAttempts: 3})
+
+	// act
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	// the delivery is recorded after the response, cancel only then
+	var deliveries []domain.WebhookDelivery
+	assert.Eventually(t, func() bool {
+		deliveries, _ = r.ListDeliveries(context.Background(), webhook.Id, 10)
+		return len(deliveries) == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	// assert
+	assert.Equal(t, 1, rec.count())
+	req, body := rec.requests[0], rec.bodies[0]
+	assert.Equal(t, "user.created", req.Header.Get(EventHeader))
+	assert.True(t, Verify("secret", req.Header.Get(TimestampHeader), body, req.Header.Get(SignatureHeader), time.Minute))
+
+	var p Payload
+	assert.Nil(t, json.Unmarshal(body, &p))
+	assert.Equal(t, event.UserId.String(), p.UserId)
+	assert.Equal(t, "ana", p.User.Nickname)
+
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+}
+
+func TestDeliver_FailedAttempts_ShouldRetryWithBackoff(t *testing.T) {
+	rec := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	r := createRepo(t)
+	webhook := addWebhook(t, r, server.URL)
+	d, delays := createDispatcher(r, nil, Config{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: time.Minute})
+
+	// act
+	d.deliver(context.Background(), webhook, createdEvent())
+
+	// assert
+	assert.Equal(t, 3, rec.count())
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *delays)
+	assert.Equal(t, rec.requests[0].Header.Get(DeliveryHeader), rec.requests[2].Header.Get(DeliveryHeader))
+
+	deliveries, _ := r.ListDeliveries(context.Background(), webhook.Id, 10)
+	assert.Len(t, deliveries, 3)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, "unexpected response status 502", deliveries[1].Error)
+}
+
+func TestDeliver_RepeatedFailures_ShouldDisableWebhook(t *testing.T) {
+	rec := &receiver{statuses: []int{500, 500, 500, 500}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	r := createRepo(t)
+	webhook := addWebhook(t, r, server.URL)
+	d, _ := createDispatcher(r, nil, Config{MaxAttempts: 2, DisableAfter: 2})
+
+	// act
+	d.deliver(context.Background(), webhook, createdEvent())
+	afterFirst, _ := r.Get(context.Background(), webhook.Id)
+	d.deliver(context.Background(), webhook, createdEvent())
+	afterSecond, _ := r.Get(context.Background(), webhook.Id)
+
+	// assert
+	assert.Equal(t, 4, rec.count())
+	assert.True(t, afterFirst.Enabled)
+	assert.False(t, afterSecond.Enabled)
+	assert.Equal(t, 2, afterSecond.ConsecutiveFailures)
+}
+
+func TestDeliver_WebhookDeleted_ShouldStopRetrying(t *testing.T) {
+	rec := &receiver{statuses: []int{500, 500}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	r := createRepo(t)
+	webhook := addWebhook(t, r, server.URL)
+	assert.Nil(t, r.Delete(context.Background(), webhook.Id))
+	d, _ := createDispatcher(r, nil, Config{MaxAttempts: 5})
+
+	d.deliver(context.Background(), webhook, createdEvent())
+
+	assert.Equal(t, 1, rec.count())
+}
+
+func TestDeliver_LoopbackAddress_ShouldNotConnect(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	r := createRepo(t)
+	webhook := addWebhook(t, r, server.URL)
+	d := NewDispatcher(r, nil, Config{MaxAttempts: 1})
+
+	// act
+	d.deliver(context.Background(), webhook, createdEvent())
+
+	// assert
+	assert.Equal(t, 0, rec.count())
+	deliveries, _ := r.ListDeliveries(context.Background(), webhook.Id, 10)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Success)
+	assert.Contains(t, deliveries[0].Error, "webhook address 127.0.0.1 is not allowed")
+}
+
+func TestBackoff_ShouldDoubleUpToMax(t *testing.T) {
+	d := NewDispatcher(nil, nil, Config{Backoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(40))
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Headers of every webhook request.
+const (
+	SignatureHeader = "X-Usermanager-Signature"
+	TimestampHeader = "X-Usermanager-Timestamp"
+	EventHeader     = "X-Usermanager-Event"
+	// same for every attempt of one delivery, so receivers can
+	// drop duplicates
+	DeliveryHeader = "X-Usermanager-Delivery"
+)
+
+const signaturePrefix = "sha256="
+
+// Signature of the request body. It is HMAC-SHA256 of
+// "<unix timestamp>.<body>" with the webhook secret, hex encoded
+// and prefixed with "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check signature and timestamp headers of a received request. Requests
+// older than tolerance are rejected, so they can't be replayed.
+func Verify(secret string, timestampHeader string, body []byte, signature string, tolerance time.Duration) bool {
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
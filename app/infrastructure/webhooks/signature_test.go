@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify_SignedBody_ShouldPass(t *testing.T) {
+	body := []byte(`{"type":"user.created"}`)
+	timestamp := time.Now().Unix()
+
+	signature := Sign("secret", timestamp, body)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, Verify("secret", strconv.FormatInt(timestamp, 10), body, signature, time.Minute))
+}
+
+func TestVerify_TamperedOrOld_ShouldFail(t *testing.T) {
+	body := []byte(`{"type":"user.created"}`)
+	now := time.Now().Unix()
+	old := time.Now().Add(-time.Hour).Unix()
+
+	assert.False(t, Verify("secret", strconv.FormatInt(now, 10), []byte(`{}`), Sign("secret", now, body), time.Minute))
+	assert.False(t, Verify("other", strconv.FormatInt(now, 10), body, Sign("secret", now, body), time.Minute))
+	assert.False(t, Verify("secret", strconv.FormatInt(old, 10), body, Sign("secret", old, body), time.Minute))
+	assert.False(t, Verify("secret", "yesterday", body, Sign("secret", now, body), time.Minute))
+}
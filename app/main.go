@@ -1,71 +1,601 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
 	"time"
 
 	"usermanager/app/config"
+	"usermanager/app/infrastructure/cache"
 	"usermanager/app/infrastructure/db"
+	"usermanager/app/infrastructure/events"
+	"usermanager/app/infrastructure/idempotency"
+	"usermanager/app/infrastructure/logging"
+	"usermanager/app/infrastructure/metrics"
 	notif "usermanager/app/infrastructure/notification"
+	"usermanager/app/infrastructure/publisher"
 	"usermanager/app/infrastructure/rabbit"
+	"usermanager/app/infrastructure/ratelimit"
 	repo "usermanager/app/infrastructure/repositories"
+	"usermanager/app/infrastructure/spool"
+	"usermanager/app/infrastructure/tracing"
+	"usermanager/app/infrastructure/webhooks"
 	"usermanager/app/services"
+	"usermanager/app/ui/auth"
+	"usermanager/app/ui/gateway"
 	h "usermanager/app/ui/grpcServers/health"
 	u "usermanager/app/ui/grpcServers/user"
+	i "usermanager/app/ui/interceptors"
+	"usermanager/app/ui/tlsconfig"
+	"usermanager/app/ui/web"
+	"usermanager/pkg/userevents"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"gorm.io/gorm"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
+	// load config from defaults, config file, env variables and flags
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	if opts.Print {
+		cfg.Print(os.Stdout)
+		return
+	}
+
 	// mock wait for the rmq containers to start properly
 	time.Sleep(time.Second * 15)
 
-	// load env variables
-	config.Load()
+	// configure log level and format, redact emails and passwords
+	if err := logging.Setup(logging.Config{
+		Level:  cfg.LogLevel,
+		Format: cfg.LogFormat,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("cannot set up logging")
+	}
 
 	// run database migrations
-	if err := db.MigrateDb(); err != nil {
+	if err := db.MigrateDb(dbConfig(cfg)); err != nil {
 		log.Fatal().Err(err).Msg("cannot run db migrations")
 	}
 
+	// set up trace exporter, flush remaining spans on exit
+	shutdownTracing, err := tracing.Init(context.Background(), traceConfig(cfg))
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("cannot flush traces")
+		}
+	}()
+
 	// start grpc server
-	runGrpcServer()
+	runGrpcServer(cfg)
 }
 
-func runGrpcServer() {
-	port := fmt.Sprintf(":%v", config.EnvConfig.ServerPort)
+func runGrpcServer(cfg *config.Config) {
+	port := fmt.Sprintf(":%v", cfg.ServerPort)
 
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatal().Err(err).Msgf("Failed to listen on port %v",
-			config.EnvConfig.ServerPort)
+			cfg.ServerPort)
 	}
 
 	// connect to db
-	db, err := db.OpenDb()
+	db, err := db.OpenDb(dbConfig(cfg))
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
 	}
 
-	// create repo, rmq wrapper, notification and user service
-	userRepo := repo.NewUserRepo(db)
-	rmq := rabbit.NewRMQ()
-	notifService := notif.NewNotificationService(rmq)
-	userService := services.NewUserService(userRepo, notifService)
+	// expose db pool stats
+	if sqlDb, err := db.DB(); err == nil {
+		if err := metrics.RegisterDB(sqlDb, cfg.DbName); err != nil {
+			log.Error().Err(err).Msg("Failed to register db metrics")
+		}
+	}
+
+	// create repo, notification publishers, event hub and user service,
+	// notifications that fail to publish are kept as dead letters
+	userRepo := newUserRepo(cfg, db)
+	deadLetterRepo := repo.NewDeadLetterRepo(db)
+	publishers := newPublishers(cfg)
+	notifService := notif.NewNotificationService(newPublisher(publishers, deadLetterRepo), cfg.NotifTimeout)
+	eventHub := events.NewUserEventHub(cfg.WatchHistorySize,
+		cfg.WatchBufferSize)
+	userService := services.NewUserService(userRepo, notifService, eventHub,
+		cfg.HashConcurrency)
+
+	// manage webhooks and deliver user events to them
+	webhookRepo := repo.NewWebhookRepo(db)
+	webhookService := services.NewWebhookService(webhookRepo)
+	go webhooks.NewDispatcher(webhookRepo, eventHub, webhookConfig(cfg)).
+		Run(context.Background())
+
+	// list and replay dead letters with the plain transport publishers
+	deadLetterService := services.NewDeadLetterService(deadLetterRepo, publishers)
+
+	// idempotency keys make mutating requests safe to retry
+	idempotencyStore := idempotency.NewIdempotencyStore(cfg.IdempotencyTTL)
+
+	// start metrics endpoint and refresh business metrics
+	if cfg.MetricsPort != "" {
+		go runMetricsServer(cfg)
+		go metrics.RefreshUsersByCountry(context.Background(), userRepo,
+			cfg.MetricsInterval)
+		go metrics.RefreshDeadLetters(context.Background(), deadLetterRepo,
+			cfg.MetricsInterval)
+	}
+
+	serverOptions := []grpc.ServerOption{}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		otelgrpc.UnaryServerInterceptor(),
+		i.MetricsUnaryInterceptor(),
+		i.LoggingUnaryInterceptor(),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		otelgrpc.StreamServerInterceptor(),
+		i.MetricsStreamInterceptor(),
+		i.LoggingStreamInterceptor(),
+	}
+
+	// encrypt transport and verify client certificates if configured
+	mtls := cfg.TlsClientCAFile != ""
+	if cfg.TlsCertFile != "" {
+		tlsConfig, err := tlsconfig.NewServerTLSConfig(tlsConfig(cfg))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load TLS certificates")
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else {
+		log.Warn().Msg("TLS is disabled, traffic is not encrypted")
+		mtls = false
+	}
+
+	// client address forwarded by the gateway or a trusted proxy
+	proxies := trustedProxies(cfg)
+	unaryInterceptors = append(unaryInterceptors, i.ClientAddressUnaryInterceptor(proxies))
+	streamInterceptors = append(streamInterceptors, i.ClientAddressStreamInterceptor(proxies))
+
+	// authenticate callers with jwt, api key or client certificate, the
+	// gateway certificate identifies the gateway, not its callers
+	if cfg.AuthEnabled {
+		authenticator, err := auth.NewAuthenticator(authConfig(cfg, mtls, proxies.Subjects))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create authenticator")
+		}
+		unaryInterceptors = append(unaryInterceptors, i.AuthUnaryInterceptor(authenticator))
+		streamInterceptors = append(streamInterceptors, i.AuthStreamInterceptor(authenticator))
+	} else if mtls {
+		unaryInterceptors = append(unaryInterceptors, i.ClientCertUnaryInterceptor(proxies.Subjects))
+		streamInterceptors = append(streamInterceptors, i.ClientCertStreamInterceptor(proxies.Subjects))
+	} else {
+		log.Warn().Msg("Authentication is disabled")
+	}
+
+	// limit request rate per principal, or per ip for anonymous callers
+	rateLimiter := ratelimit.NewRateLimiter(rateLimitConfig(cfg))
+	unaryInterceptors = append(unaryInterceptors, i.RateLimitUnaryInterceptor(rateLimiter))
+	streamInterceptors = append(streamInterceptors, i.RateLimitStreamInterceptor(rateLimiter))
+
+	// check caller's role against the per rpc policy
+	if cfg.AuthzEnabled {
+		authorizer := auth.NewAuthorizer()
+		metadataRoles := cfg.AuthzMetadataRoles
+		if metadataRoles {
+			log.Warn().Msg("Roles are read from request metadata, use it only for development")
+		}
+		unaryInterceptors = append(unaryInterceptors, i.AuthzUnaryInterceptor(authorizer, metadataRoles))
+		streamInterceptors = append(streamInterceptors, i.AuthzStreamInterceptor(authorizer, metadataRoles))
+	} else {
+		log.Warn().Msg("Authorization is disabled")
+	}
+
+	unaryInterceptors = append(unaryInterceptors, i.IdempotencyUnaryInterceptor(idempotencyStore))
+
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 
-	g := grpc.NewServer()
+	g := grpc.NewServer(serverOptions...)
 
 	// create and register user grpc server
-	u.NewUserGrpcServer(g, userService)
+	u.NewUserGrpcServer(g, userService, webhookService, deadLetterService, cfg.WebhookInsecure)
 
 	// create and register health grpc server
 	h.NewHealthGrpcServer(g)
 
+	// let grpcurl and other tools discover services without proto files
+	if cfg.GrpcReflection {
+		reflection.Register(g)
+	}
+
+	// expose user service over http/json
+	if cfg.GatewayPort != "" {
+		go runGatewayServer(cfg)
+	}
+
+	// serve the same services over grpc-web and connect for browsers
+	if cfg.WebPort != "" {
+		go runWebServer(g, cfg)
+	}
+
 	if err := g.Serve(lis); err != nil {
 		log.Fatal().Err(err).Msgf("Failed to serve gRPC server over port %v",
-			config.EnvConfig.ServerPort)
+			cfg.ServerPort)
+	}
+}
+
+func runMetricsServer(cfg *config.Config) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%v", cfg.MetricsPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Info().Msgf("Serving metrics on port %v", cfg.MetricsPort)
+	if err := server.ListenAndServe(); err != nil {
+		log.Error().Err(err).Msgf("Failed to serve metrics over port %v",
+			cfg.MetricsPort)
+	}
+}
+
+// Serve http/json gateway that forwards requests to the grpc server,
+// so they go through the same interceptors.
+func runGatewayServer(cfg *config.Config) {
+	gatewayConfig := gateway.Config{
+		GrpcEndpoint: fmt.Sprintf("localhost:%v", cfg.ServerPort),
+	}
+
+	// trust the server certificate, or the CA file if it is signed by one
+	if cfg.TlsCertFile != "" {
+		caFile := cfg.GatewayGrpcCAFile
+		if caFile == "" {
+			caFile = cfg.TlsCertFile
+		}
+		tlsConfig, err := tlsconfig.NewClientTLSConfig(caFile, "localhost")
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load gateway TLS config")
+			return
+		}
+		// present the client certificate if the server verifies them
+		if cfg.GatewayCertFile != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.GatewayCertFile, cfg.GatewayKeyFile)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load gateway client certificate")
+				return
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		gatewayConfig.TLS = tlsConfig
+	}
+
+	handler, err := gateway.NewGatewayHandler(context.Background(), gatewayConfig)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create gateway")
+		return
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%v", cfg.GatewayPort),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Info().Msgf("Serving http gateway on port %v", cfg.GatewayPort)
+	if err := server.ListenAndServe(); err != nil {
+		log.Error().Err(err).Msgf("Failed to serve http gateway over port %v",
+			cfg.GatewayPort)
+	}
+}
+
+// Serve grpc services over grpc-web, connect and grpc protocols
+// on http/1.1 and http/2 listener.
+func runWebServer(g *grpc.Server, cfg *config.Config) {
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%v", cfg.WebPort),
+		Handler: web.NewHandler(g, web.Config{
+			AllowedOrigins: cfg.WebAllowedOrigins,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Info().Msgf("Serving grpc-web and connect on port %v", cfg.WebPort)
+
+	var err error
+	if cfg.TlsCertFile != "" {
+		webTlsConfig := tlsConfig(cfg)
+		webTlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		server.TLSConfig, err = tlsconfig.NewServerTLSConfig(webTlsConfig)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load web TLS certificates")
+			return
+		}
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to serve grpc-web and connect over port %v",
+			cfg.WebPort)
+	}
+}
+
+// Publishers of user change notifications by configured transport.
+func newPublishers(cfg *config.Config) map[string]publisher.Publisher {
+	publishers := make(map[string]publisher.Publisher, len(cfg.NotifTransports))
+	for _, transport := range cfg.NotifTransports {
+		switch transport {
+		case publisher.TransportRabbit:
+			publishers[transport] = rabbit.NewRMQ(rabbitConfig(cfg))
+		case publisher.TransportNats:
+			natsPublisher, err := publisher.NewNatsPublisher(publisher.NatsConfig{
+				Url:     cfg.NatsUrl,
+				Subject: cfg.NatsSubject,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create nats publisher")
+			}
+			publishers[transport] = natsPublisher
+		case publisher.TransportKafka:
+			publishers[transport] = publisher.NewKafkaPublisher(publisher.KafkaConfig{
+				Brokers: cfg.KafkaBrokers,
+				Topic:   cfg.KafkaTopic,
+			})
+		case publisher.TransportWebhook:
+			publishers[transport] = publisher.NewWebhookPublisher(publisher.WebhookConfig{
+				Url:     cfg.NotifWebhookUrl,
+				Timeout: cfg.NotifWebhookTimeout,
+			})
+		case publisher.TransportMemory:
+			publishers[transport] = publisher.NewMemoryBus()
+		}
+	}
+	return publishers
+}
+
+// Publisher of user change notifications to every transport at once.
+// Notifications a transport failed to publish are stored as dead letters.
+func newPublisher(publishers map[string]publisher.Publisher, deadLetters repo.DeadLetterRepo) publisher.Publisher {
+	deadLettering := make([]publisher.Publisher, 0, len(publishers))
+	for transport, p := range publishers {
+		deadLettering = append(deadLettering, publisher.NewDeadLetterPublisher(transport, p, deadLetters))
+	}
+
+	if len(deadLettering) == 1 {
+		return deadLettering[0]
+	}
+	return publisher.NewFanout(deadLettering...)
+}
+
+// Rabbit producer settings from config, the spool is opened if it
+// is enabled.
+func rabbitConfig(cfg *config.Config) rabbit.Config {
+	c := rabbit.Config{
+		Url:               cfg.RabbitUrl,
+		Exchange:          cfg.NotificationQueue,
+		ReconnectDelay:    cfg.RabbitReconnect,
+		ConfirmTimeout:    cfg.RabbitConfirm,
+		CloudEvents:       cfg.RabbitCloudEvents,
+		CloudEventsSource: cfg.CloudEventsSource,
+	}
+	if cfg.SpoolPath == "" {
+		return c
+	}
+
+	s, err := spool.NewSpool(spool.Config{
+		Path:          cfg.SpoolPath,
+		MaxBytes:      int64(cfg.SpoolMaxBytes),
+		Fsync:         cfg.SpoolFsync,
+		FsyncInterval: cfg.SpoolFsyncInterval,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open notification spool")
+	}
+	if depth := s.Depth(); depth > 0 {
+		log.Info().Msgf("%v spooled notifications will be published once rabbit is reachable", depth)
+	}
+	c.Spool = s
+	return c
+}
+
+// Database settings from config
+func dbConfig(cfg *config.Config) db.Config {
+	return db.Config{
+		Driver:           cfg.DbDriver,
+		Path:             cfg.DbPath,
+		Host:             cfg.DbHost,
+		Port:             cfg.DbPort,
+		User:             cfg.DbUser,
+		Pass:             cfg.DbPass,
+		Name:             cfg.DbName,
+		SslMode:          cfg.SslMode,
+		MaxOpenConns:     cfg.DbMaxOpenConns,
+		MaxIdleConns:     cfg.DbMaxIdleConns,
+		ConnMaxLifetime:  cfg.DbConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.DbConnMaxIdleTime,
+		StatementTimeout: cfg.DbStatementTimeout,
+		ReplicaHosts:     cfg.DbReplicaHosts,
+	}
+}
+
+// User repository, with user lookups cached if the cache is enabled.
+func newUserRepo(cfg *config.Config, db *gorm.DB) repo.UserRepo {
+	userRepo := repo.NewUserRepo(db)
+
+	var c cache.Cache
+	switch cfg.UserCache {
+	case cache.BackendMemory:
+		c = cache.NewLRU(cfg.UserCacheSize)
+	case cache.BackendRedis:
+		r, err := cache.NewRedis(cache.RedisConfig{Url: cfg.RedisUrl, Prefix: cfg.RedisPrefix})
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create user cache")
+		}
+		c = r
+	default:
+		return userRepo
+	}
+
+	log.Info().Msgf("User lookups are cached in %v", cfg.UserCache)
+	cached := repo.NewCachedUserRepo(userRepo, c, repo.CacheConfig{
+		TTL:             cfg.UserCacheTTL,
+		NotFoundTTL:     cfg.UserCacheMissingTTL,
+		InvalidateDelay: cfg.UserCacheRedelete,
+	})
+
+	// redis is shared, memory caches of the other instances learn
+	// about the writes from the notifications
+	if cfg.UserCache == cache.BackendMemory {
+		go invalidateOnNotifications(cfg, cached.Invalidate)
+	}
+	return cached
+}
+
+// Remove users changed by any instance from the memory cache. Every
+// instance consumes the notification exchange with its own transient
+// queue. Without rabbit notifications other instances see the change
+// after the cache TTL.
+func invalidateOnNotifications(cfg *config.Config, invalidate func(ctx context.Context, id uuid.UUID)) {
+	if cfg.RabbitUrl == "" || !contains(cfg.NotifTransports, publisher.TransportRabbit) {
+		log.Warn().Msg("User cache is invalidated only by this instance, enable rabbitmq notifications to invalidate it everywhere")
+		return
+	}
+
+	consumer := userevents.NewConsumer(userevents.Config{
+		Url:            cfg.RabbitUrl,
+		Exchange:       cfg.NotificationQueue,
+		Queue:          cfg.NotificationQueue + ".cache." + uuid.NewString(),
+		ReconnectDelay: cfg.RabbitReconnect,
+		Transient:      true,
+	})
+	err := consumer.Subscribe(context.Background(), func(ctx context.Context, e userevents.Event) error {
+		invalidate(ctx, e.UserId)
+		return nil
+	})
+	log.Error().Err(err).Msg("User cache invalidation stopped")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery settings from config
+func webhookConfig(cfg *config.Config) webhooks.Config {
+	return webhooks.Config{
+		Workers:       cfg.WebhookWorkers,
+		Timeout:       cfg.WebhookTimeout,
+		MaxAttempts:   cfg.WebhookMaxAttempts,
+		Backoff:       cfg.WebhookBackoff,
+		MaxBackoff:    cfg.WebhookMaxBackoff,
+		DisableAfter:  cfg.WebhookDisableAfter,
+		AllowInsecure: cfg.WebhookInsecure,
+	}
+}
+
+// Authentication settings from config
+func authConfig(cfg *config.Config, clientCerts bool, proxySubjects []string) auth.Config {
+	apiKeys := map[string]auth.APIKey{}
+	for _, k := range cfg.ApiKeys {
+		apiKeys[k.Key] = auth.APIKey{Name: k.Name, Role: k.Role}
+	}
+
+	return auth.Config{
+		JwtAlgorithm:  cfg.JwtAlgorithm,
+		JwtKeyFile:    cfg.JwtKeyFile,
+		JwksFile:      cfg.JwksFile,
+		JwtIssuer:     cfg.JwtIssuer,
+		JwtAudience:   cfg.JwtAudience,
+		ApiKeys:       apiKeys,
+		ClientCerts:   clientCerts,
+		ProxySubjects: proxySubjects,
+	}
+}
+
+// Trusted proxy networks and the subject of the gateway client
+// certificate, if the gateway presents one.
+func trustedProxies(cfg *config.Config) i.TrustedProxies {
+	var proxies i.TrustedProxies
+	for _, n := range cfg.TrustedProxies {
+		if _, network, err := net.ParseCIDR(n); err == nil {
+			proxies.Networks = append(proxies.Networks, network)
+		}
+	}
+
+	if cfg.GatewayPort != "" && cfg.GatewayCertFile != "" {
+		pair, err := tls.LoadX509KeyPair(cfg.GatewayCertFile, cfg.GatewayKeyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load gateway client certificate")
+		}
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to parse gateway client certificate")
+		}
+		proxies.Subjects = []string{auth.CertSubject(cert)}
+	}
+	return proxies
+}
+
+// TLS settings from config
+func tlsConfig(cfg *config.Config) tlsconfig.Config {
+	return tlsconfig.Config{
+		CertFile:     cfg.TlsCertFile,
+		KeyFile:      cfg.TlsKeyFile,
+		ClientCAFile: cfg.TlsClientCAFile,
+		ClientAuth:   cfg.TlsClientAuth,
+	}
+}
+
+// Rate limit settings from config
+func rateLimitConfig(cfg *config.Config) ratelimit.Config {
+	methods := map[string]ratelimit.Limit{}
+	for _, m := range cfg.RateLimitMethods {
+		methods[m.Method] = ratelimit.Limit{Rate: m.Rps, Burst: m.Burst}
+	}
+
+	return ratelimit.Config{
+		Default: ratelimit.Limit{
+			Rate:  cfg.RateLimitRps,
+			Burst: cfg.RateLimitBurst,
+		},
+		Methods: methods,
+	}
+}
+
+// Tracing settings from config
+func traceConfig(cfg *config.Config) tracing.Config {
+	return tracing.Config{
+		Exporter:     cfg.TraceExporter,
+		OtlpEndpoint: cfg.TraceOtlpEndpoint,
+		OtlpInsecure: cfg.TraceOtlpInsecure,
+		SampleRatio:  cfg.TraceSampleRatio,
 	}
 }
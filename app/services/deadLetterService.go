@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/metrics"
+	"usermanager/app/infrastructure/publisher"
+	repo "usermanager/app/infrastructure/repositories"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultReplayLimit = 1000
+
+type DeadLetterService interface {
+	// Dead letters matching the filter and their total count.
+	List(ctx context.Context, req *proto.ListDeadLettersRequest) ([]domain.DeadLetter, int64, error)
+	Replay(ctx context.Context, id string) error
+	// Replay dead letters matching the filter. Returns number of
+	// replayed and failed ones.
+	ReplayAll(ctx context.Context, req *proto.ReplayDeadLettersRequest) (int, int, error)
+}
+
+type deadLetterService struct {
+	repo       repo.DeadLetterRepo
+	publishers map[string]publisher.Publisher
+}
+
+// Create service that lists and replays dead letters. Publishers by
+// transport must not store dead letters themselves, a failed replay
+// is counted on the replayed dead letter.
+func NewDeadLetterService(r repo.DeadLetterRepo, publishers map[string]publisher.Publisher) *deadLetterService {
+	return &deadLetterService{
+		repo:       r,
+		publishers: publishers,
+	}
+}
+
+func (d *deadLetterService) List(ctx context.Context, req *proto.ListDeadLettersRequest) ([]domain.DeadLetter, int64, error) {
+	filter := deadLetterFilter(req.Filter)
+
+	letters, err := d.repo.List(ctx, filter, int(req.Offset), int(req.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := d.repo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return letters, total, nil
+}
+
+// Publish dead letter to its transport again. It is removed when it is
+// published, otherwise the attempt is counted and Unavailable error is
+// returned.
+func (d *deadLetterService) Replay(ctx context.Context, id string) error {
+	letter, err := d.repo.Get(ctx, uuid.MustParse(id))
+	if err != nil {
+		return err
+	}
+	return d.replay(ctx, letter)
+}
+
+func (d *deadLetterService) ReplayAll(ctx context.Context, req *proto.ReplayDeadLettersRequest) (int, int, error) {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultReplayLimit
+	}
+
+	letters, err := d.repo.List(ctx, deadLetterFilter(req.Filter), 0, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	replayed, failed := 0, 0
+	for _, letter := range letters {
+		if ctx.Err() != nil {
+			return replayed, failed, status.FromContextError(ctx.Err()).Err()
+		}
+		if err := d.replay(ctx, letter); err != nil {
+			failed++
+			continue
+		}
+		replayed++
+	}
+	return replayed, failed, nil
+}
+
+func (d *deadLetterService) replay(ctx context.Context, letter domain.DeadLetter) error {
+	p, ok := d.publishers[letter.Transport]
+	if !ok {
+		return status.Errorf(codes.FailedPrecondition, "transport %v is not configured", letter.Transport)
+	}
+
+	msg := publisher.Message{
+		Id:          letter.EventId,
+		Time:        letter.CreatedAt,
+		UserId:      letter.UserId,
+		SpanContext: publisher.SpanContextFromTraceParent(letter.TraceParent),
+	}
+	if letter.EventTime != nil {
+		msg.Time = *letter.EventTime
+	}
+	if msg.Id == uuid.Nil {
+		msg.Id = uuid.New()
+	}
+	if err := p.Publish(ctx, msg); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("replay of dead letter %v failed", letter.Id)
+		if recordErr := d.repo.RecordFailure(ctx, letter.Id, err.Error()); recordErr != nil {
+			log.Ctx(ctx).Error().Err(recordErr).Msgf("cannot record failed replay of dead letter %v", letter.Id)
+		}
+		return status.Errorf(codes.Unavailable, "replay failed: %v", err)
+	}
+
+	if err := d.repo.Delete(ctx, letter.Id); err != nil {
+		// published again, but it would be replayed once more
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot delete replayed dead letter %v", letter.Id)
+		return err
+	}
+	metrics.DeadLetters.WithLabelValues(letter.Transport).Dec()
+
+	log.Ctx(ctx).Info().Msgf("dead letter %v for user %v replayed to %v", letter.Id, letter.UserId, letter.Transport)
+	return nil
+}
+
+func deadLetterFilter(f *proto.DeadLetterFilter) repo.DeadLetterFilter {
+	filter := repo.DeadLetterFilter{}
+	if f == nil {
+		return filter
+	}
+	filter.Transport = f.Transport
+	if f.UserId != "" {
+		filter.UserId = uuid.MustParse(f.UserId)
+	}
+	return filter
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usermanager/app/domain"
+	"usermanager/app/infrastructure/publisher"
+	repo "usermanager/app/infrastructure/repositories"
+	repoMock "usermanager/app/infrastructure/repositories/mocks"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// publisher that fails with err, if set
+type fakePublisher struct {
+	err       error
+	published []publisher.Message
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg publisher.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, msg)
+	return nil
+}
+
+func TestReplayDeadLetter_Published_ShouldDeleteDeadLetter(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	rabbit := &fakePublisher{}
+	deadLetterService := NewDeadLetterService(mockedRepo, map[string]publisher.Publisher{publisher.TransportRabbit: rabbit})
+
+	// arrange
+	letter := domain.DeadLetter{Id: uuid.New(), Transport: publisher.TransportRabbit, UserId: uuid.New()}
+	mockedRepo.On("Get", mock.Anything, letter.Id).Return(letter, nil)
+	mockedRepo.On("Delete", mock.Anything, letter.Id).Return(nil)
+
+	// act
+	err := deadLetterService.Replay(context.Background(), letter.Id.String())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, rabbit.published, 1)
+	assert.Equal(t, letter.UserId, rabbit.published[0].UserId)
+	mockedRepo.AssertExpectations(t)
+}
+
+func TestReplayDeadLetter_Published_ShouldKeepEventIdAndTime(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	rabbit := &fakePublisher{}
+	deadLetterService := NewDeadLetterService(mockedRepo, map[string]publisher.Publisher{publisher.TransportRabbit: rabbit})
+
+	// arrange
+	eventTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	letter := domain.DeadLetter{
+		Id:        uuid.New(),
+		Transport: publisher.TransportRabbit,
+		UserId:    uuid.New(),
+		EventId:   uuid.New(),
+		EventTime: &eventTime,
+		CreatedAt: eventTime.Add(time.Second),
+	}
+	mockedRepo.On("Get", mock.Anything, letter.Id).Return(letter, nil)
+	mockedRepo.On("Delete", mock.Anything, letter.Id).Return(nil)
+
+	// act
+	err := deadLetterService.Replay(context.Background(), letter.Id.String())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, rabbit.published, 1)
+	assert.Equal(t, letter.EventId, rabbit.published[0].Id)
+	assert.Equal(t, eventTime, rabbit.published[0].Time)
+}
+
+func TestReplayDeadLetter_PublishFailed_ShouldRecordFailure(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	kafka := &fakePublisher{err: errors.New("broker unavailable")}
+	deadLetterService := NewDeadLetterService(mockedRepo, map[string]publisher.Publisher{publisher.TransportKafka: kafka})
+
+	// arrange
+	letter := domain.DeadLetter{Id: uuid.New(), Transport: publisher.TransportKafka, UserId: uuid.New()}
+	mockedRepo.On("Get", mock.Anything, letter.Id).Return(letter, nil)
+	mockedRepo.On("RecordFailure", mock.Anything, letter.Id, "broker unavailable").Return(nil)
+
+	// act
+	err := deadLetterService.Replay(context.Background(), letter.Id.String())
+
+	// assert
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	mockedRepo.AssertExpectations(t)
+	mockedRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestReplayDeadLetter_TransportNotConfigured_ShouldReturnFailedPrecondition(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	deadLetterService := NewDeadLetterService(mockedRepo, map[string]publisher.Publisher{})
+
+	// arrange
+	letter := domain.DeadLetter{Id: uuid.New(), Transport: publisher.TransportNats}
+	mockedRepo.On("Get", mock.Anything, letter.Id).Return(letter, nil)
+
+	// act
+	err := deadLetterService.Replay(context.Background(), letter.Id.String())
+
+	// assert
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestReplayDeadLetters_ByFilter_ShouldCountReplayedAndFailed(t *testing.T) {
+	mockedRepo := &repoMock.DeadLetterRepoMock{}
+	deadLetterService := NewDeadLetterService(mockedRepo, map[string]publisher.Publisher{
+		publisher.TransportRabbit: &fakePublisher{},
+	})
+
+	// arrange
+	userId := uuid.New()
+	replayable := domain.DeadLetter{Id: uuid.New(), Transport: publisher.TransportRabbit, UserId: userId}
+	unknown := domain.DeadLetter{Id: uuid.New(), Transport: "sqs", UserId: userId}
+	mockedRepo.
+		On("List", mock.Anything, repo.DeadLetterFilter{UserId: userId}, 0, defaultReplayLimit).
+		Return([]domain.DeadLetter{replayable, unknown}, nil)
+	mockedRepo.On("Delete", mock.Anything, replayable.Id).Return(nil)
+
+	// act
+	replayed, failed, err := deadLetterService.ReplayAll(context.Background(), &proto.ReplayDeadLettersRequest{
+		Filter: &proto.DeadLetterFilter{UserId: userId.String()},
+	})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, 1, failed)
+}
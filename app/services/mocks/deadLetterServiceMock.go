@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type DeadLetterServiceMock struct {
+	mock.Mock
+}
+
+func (d *DeadLetterServiceMock) List(ctx context.Context, req *proto.ListDeadLettersRequest) ([]domain.DeadLetter, int64, error) {
+	args := d.Called(ctx, req)
+	letters, _ := args.Get(0).([]domain.DeadLetter)
+	return letters, args.Get(1).(int64), args.Error(2)
+}
+
+func (d *DeadLetterServiceMock) Replay(ctx context.Context, id string) error {
+	args := d.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (d *DeadLetterServiceMock) ReplayAll(ctx context.Context, req *proto.ReplayDeadLettersRequest) (int, int, error) {
+	args := d.Called(ctx, req)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
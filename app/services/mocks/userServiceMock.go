@@ -1,7 +1,9 @@
 package mocks
 
 import (
+	"context"
 	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
 	proto "usermanager/app/ui/protos/user"
 
 	"github.com/google/uuid"
@@ -12,19 +14,19 @@ type UserServiceMock struct {
 	mock.Mock
 }
 
-func (u *UserServiceMock) Add(req *proto.CreateUserRequest) (uuid.UUID, error) {
-	args := u.Called(req)
+func (u *UserServiceMock) Add(ctx context.Context, req *proto.CreateUserRequest) (uuid.UUID, error) {
+	args := u.Called(ctx, req)
 
 	var r0 uuid.UUID
-	if rf, ok := args.Get(0).(func(*proto.CreateUserRequest) uuid.UUID); ok {
-		r0 = rf(req)
+	if rf, ok := args.Get(0).(func(context.Context, *proto.CreateUserRequest) uuid.UUID); ok {
+		r0 = rf(ctx, req)
 	} else {
 		r0 = args.Get(0).(uuid.UUID)
 	}
 
 	var r1 error
-	if rf, ok := args.Get(1).(func(*proto.CreateUserRequest) error); ok {
-		r1 = rf(req)
+	if rf, ok := args.Get(1).(func(context.Context, *proto.CreateUserRequest) error); ok {
+		r1 = rf(ctx, req)
 	} else {
 		r1 = args.Error(1)
 	}
@@ -32,12 +34,12 @@ func (u *UserServiceMock) Add(req *proto.CreateUserRequest) (uuid.UUID, error) {
 	return r0, r1
 }
 
-func (u *UserServiceMock) Update(req *proto.UpdateUserRequest) error {
-	args := u.Called(req)
+func (u *UserServiceMock) Update(ctx context.Context, req *proto.UpdateUserRequest) error {
+	args := u.Called(ctx, req)
 
 	var r0 error
-	if rf, ok := args.Get(0).(func(*proto.UpdateUserRequest) error); ok {
-		r0 = rf(req)
+	if rf, ok := args.Get(0).(func(context.Context, *proto.UpdateUserRequest) error); ok {
+		r0 = rf(ctx, req)
 	} else {
 		r0 = args.Error(0)
 	}
@@ -45,12 +47,12 @@ func (u *UserServiceMock) Update(req *proto.UpdateUserRequest) error {
 	return r0
 }
 
-func (u *UserServiceMock) Delete(id string) error {
-	args := u.Called(id)
+func (u *UserServiceMock) Delete(ctx context.Context, id string) error {
+	args := u.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := args.Get(0).(func(string) error); ok {
-		r0 = rf(id)
+	if rf, ok := args.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = args.Error(0)
 	}
@@ -58,18 +60,58 @@ func (u *UserServiceMock) Delete(id string) error {
 	return r0
 }
 
-func (u *UserServiceMock) GetPage(req *proto.UserPageRequest) ([]domain.User, error) {
-	args := u.Called(req)
+func (u *UserServiceMock) Get(ctx context.Context, id string) (domain.User, error) {
+	args := u.Called(ctx, id)
+
+	var r0 domain.User
+	if rf, ok := args.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = args.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := args.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = args.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (u *UserServiceMock) GetPage(ctx context.Context, req *proto.UserPageRequest) ([]domain.User, error) {
+	args := u.Called(ctx, req)
 
 	var r0 []domain.User
-	if rf, ok := args.Get(0).(func(*proto.UserPageRequest) []domain.User); ok {
-		r0 = rf(req)
+	if rf, ok := args.Get(0).(func(context.Context, *proto.UserPageRequest) []domain.User); ok {
+		r0 = rf(ctx, req)
 	} else {
 		r0 = args.Get(0).([]domain.User)
 	}
 
 	var r1 error
-	if rf, ok := args.Get(1).(func(*proto.UserPageRequest) error); ok {
+	if rf, ok := args.Get(1).(func(context.Context, *proto.UserPageRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = args.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (u *UserServiceMock) Watch(req *proto.WatchUsersRequest) (events.Subscription, error) {
+	args := u.Called(req)
+
+	var r0 events.Subscription
+	if rf, ok := args.Get(0).(func(*proto.WatchUsersRequest) events.Subscription); ok {
+		r0 = rf(req)
+	} else if args.Get(0) != nil {
+		r0 = args.Get(0).(events.Subscription)
+	}
+
+	var r1 error
+	if rf, ok := args.Get(1).(func(*proto.WatchUsersRequest) error); ok {
 		r1 = rf(req)
 	} else {
 		r1 = args.Error(1)
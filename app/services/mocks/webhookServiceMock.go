@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookServiceMock struct {
+	mock.Mock
+}
+
+func (w *WebhookServiceMock) Create(ctx context.Context, req *proto.CreateWebhookRequest) (domain.Webhook, error) {
+	args := w.Called(ctx, req)
+	return args.Get(0).(domain.Webhook), args.Error(1)
+}
+
+func (w *WebhookServiceMock) List(ctx context.Context) ([]domain.Webhook, error) {
+	args := w.Called(ctx)
+	webhooks, _ := args.Get(0).([]domain.Webhook)
+	return webhooks, args.Error(1)
+}
+
+func (w *WebhookServiceMock) Update(ctx context.Context, req *proto.UpdateWebhookRequest) (domain.Webhook, error) {
+	args := w.Called(ctx, req)
+	return args.Get(0).(domain.Webhook), args.Error(1)
+}
+
+func (w *WebhookServiceMock) Delete(ctx context.Context, id string) error {
+	args := w.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (w *WebhookServiceMock) Deliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) ([]domain.WebhookDelivery, error) {
+	args := w.Called(ctx, req)
+	deliveries, _ := args.Get(0).([]domain.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"runtime"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Hashes passwords with bcrypt. Hashing is cpu bound, so number of
+// concurrent hashes is limited and other requests wait for their turn
+// until their context is done.
+type passwordHasher struct {
+	sem chan struct{}
+}
+
+// Create password hasher that runs at most concurrency hashes at once,
+// number of cpus if concurrency is not positive.
+func newPasswordHasher(concurrency int) *passwordHasher {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	return &passwordHasher{sem: make(chan struct{}, concurrency)}
+}
+
+// Returns hash value of password or error if context is done
+// before the hash could start.
+func (h *passwordHasher) hash(ctx context.Context, password string) (string, error) {
+	select {
+	case h.sem <- struct{}{}:
+	case <-ctx.Done():
+		return "", status.FromContextError(ctx.Err()).Err()
+	}
+	defer func() { <-h.sem }()
+
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return string(hashedBytes), nil
+}
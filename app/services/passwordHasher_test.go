@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHash_ShouldReturnBcryptHash(t *testing.T) {
+	hasher := newPasswordHasher(1)
+
+	hash, err := hasher.hash(context.Background(), "test-pass")
+
+	assert.Nil(t, err)
+	assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("test-pass")))
+}
+
+func TestHash_AllSlotsTaken_ShouldWaitUntilContextDone(t *testing.T) {
+	hasher := newPasswordHasher(1)
+
+	// arrange
+	hasher.sem <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// act
+	hash, err := hasher.hash(ctx, "test-pass")
+
+	// assert
+	assert.Empty(t, hash)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestHash_SlotReleased_ShouldHash(t *testing.T) {
+	hasher := newPasswordHasher(1)
+
+	// arrange
+	hasher.sem <- struct{}{}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-hasher.sem
+	}()
+
+	// act
+	hash, err := hasher.hash(context.Background(), "test-pass")
+
+	// assert
+	assert.Nil(t, err)
+	assert.NotEmpty(t, hash)
+	assert.Len(t, hasher.sem, 0)
+}
+
+func TestNewPasswordHasher_ZeroConcurrency_ShouldUseCpuCount(t *testing.T) {
+	hasher := newPasswordHasher(0)
+
+	assert.True(t, cap(hasher.sem) > 0)
+}
@@ -1,99 +1,216 @@
 package services
 
 import (
+	"context"
 	"strings"
 	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
 	notif "usermanager/app/infrastructure/notification"
 	repo "usermanager/app/infrastructure/repositories"
+	"usermanager/app/infrastructure/tracing"
 	proto "usermanager/app/ui/protos/user"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("usermanager/app/services")
+
 type UserService interface {
-	Add(req *proto.CreateUserRequest) (uuid.UUID, error)
-	Update(req *proto.UpdateUserRequest) error
-	Delete(id string) error
-	GetPage(req *proto.UserPageRequest) ([]domain.User, error)
+	Add(ctx context.Context, req *proto.CreateUserRequest) (uuid.UUID, error)
+	Update(ctx context.Context, req *proto.UpdateUserRequest) error
+	Delete(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (domain.User, error)
+	GetPage(ctx context.Context, req *proto.UserPageRequest) ([]domain.User, error)
+	Watch(req *proto.WatchUsersRequest) (events.Subscription, error)
 }
 
 type userService struct {
-	repo  repo.UserRepo
-	notif notif.NotificationService
+	repo   repo.UserRepo
+	notif  notif.NotificationService
+	events events.UserEventHub
+	hasher *passwordHasher
 }
 
-func NewUserService(r repo.UserRepo, n notif.NotificationService) *userService {
+// Create user service. Password hashing runs at most hashConcurrency
+// hashes at once, number of cpus if it is not positive.
+func NewUserService(r repo.UserRepo, n notif.NotificationService, e events.UserEventHub,
+	hashConcurrency int) *userService {
 	return &userService{
-		repo:  r,
-		notif: n,
+		repo:   r,
+		notif:  n,
+		events: e,
+		hasher: newPasswordHasher(hashConcurrency),
 	}
 }
 
 // Create new user. Returns user id or error if occured.
-func (u userService) Add(req *proto.CreateUserRequest) (uuid.UUID, error) {
+func (u userService) Add(ctx context.Context, req *proto.CreateUserRequest) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Add")
+	defer span.End()
+
+	password, err := u.hasher.hash(ctx, req.Password)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return uuid.Nil, err
+	}
+	span.AddEvent("password hashed")
+
 	// create user domain model and add to db
-	user := userFromCreateReq(req)
-	if err := u.repo.Add(user); err != nil {
+	user := userFromCreateReq(req, password)
+	span.SetAttributes(attribute.String("user.id", user.Id.String()))
+
+	stored, err := u.repo.Add(ctx, user)
+	if err != nil {
+		tracing.RecordError(span, err)
 		return uuid.Nil, err
 	}
 
-	return user.Id, nil
+	u.events.Publish(domain.UserCreated, stored.Id, eventUser(stored))
+
+	return stored.Id, nil
 }
 
 // Update provided user. Returns error if occured.
-func (u *userService) Update(req *proto.UpdateUserRequest) error {
+func (u *userService) Update(ctx context.Context, req *proto.UpdateUserRequest) error {
+	ctx, span := tracer.Start(ctx, "UserService.Update",
+		trace.WithAttributes(attribute.String("user.id", req.Id)))
+	defer span.End()
+
+	password, err := u.hasher.hash(ctx, req.Password)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	span.AddEvent("password hashed")
+
 	// create user domain model and update
-	user := userFromUpdateReq(req)
-	if err := u.repo.Update(user); err != nil {
+	user := userFromUpdateReq(req, password)
+
+	stored, err := u.repo.Update(ctx, user)
+	if err != nil {
+		tracing.RecordError(span, err)
 		return err
 	}
 
+	// stored user has all the fields, also the creation time
+	// that the event filter checks
+	u.events.Publish(domain.UserUpdated, stored.Id, eventUser(stored))
+
 	// notify services subscribed to user change notifications
 	// this can go asynchronously, we don't need the results
-	go u.notif.NotifyAboutUserChange(user.Id)
+	go u.notif.NotifyAboutUserChange(ctx, user.Id)
 
 	return nil
 }
 
 // Delete user with provided id. Returns error if occured.
-func (u *userService) Delete(id string) error {
-	return u.repo.Delete(uuid.MustParse(id))
+func (u *userService) Delete(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "UserService.Delete",
+		trace.WithAttributes(attribute.String("user.id", id)))
+	defer span.End()
+
+	userId := uuid.MustParse(id)
+	if err := u.repo.Delete(ctx, userId); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	u.events.Publish(domain.UserDeleted, userId, nil)
+
+	return nil
+}
+
+// Get user with provided id. Returns user or error if ocurred.
+func (u *userService) Get(ctx context.Context, id string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Get",
+		trace.WithAttributes(attribute.String("user.id", id)))
+	defer span.End()
+
+	user, err := u.repo.Get(ctx, uuid.MustParse(id))
+	tracing.RecordError(span, err)
+	return user, err
 }
 
 // Get user page method. Returns list of users or error if ocurred.
-func (u *userService) GetPage(req *proto.UserPageRequest) ([]domain.User, error) {
-	return u.repo.GetPage(req.Filter, req.Offset, req.Limit)
+func (u *userService) GetPage(ctx context.Context, req *proto.UserPageRequest) ([]domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetPage",
+		trace.WithAttributes(
+			attribute.Int("page.offset", int(req.Offset)),
+			attribute.Int("page.limit", int(req.Limit)),
+		))
+	defer span.End()
+
+	users, err := u.repo.GetPage(ctx, req.Filter, req.Offset, req.Limit)
+	tracing.RecordError(span, err)
+	return users, err
+}
+
+// Subscribe to user changes that match the request filter, starting
+// from the requested resume point. Returns subscription or error if ocurred.
+func (u *userService) Watch(req *proto.WatchUsersRequest) (events.Subscription, error) {
+	from := events.ResumePoint{Epoch: req.Epoch, AfterSequence: req.AfterSequence}
+	if req.Since != nil {
+		from.Since = req.Since.AsTime()
+	}
+
+	return u.events.Subscribe(from, eventFilter(req.Filter))
 }
 
-// Create user domain model from CreateUserRequest.
-func userFromCreateReq(req *proto.CreateUserRequest) domain.User {
+// Create event filter from user filter options. Filter is applied only
+// to the data that event carries, so deleted users are always delivered.
+func eventFilter(filter *proto.UserPageRequest_UserFilterOptions) events.EventFilter {
+	if filter == nil {
+		return nil
+	}
+
+	return func(e domain.UserEvent) bool {
+		if e.User == nil {
+			return true
+		}
+		if filter.Country != "" && !strings.EqualFold(e.User.Country, filter.Country) {
+			return false
+		}
+		if filter.CreatedFrom != nil && !e.User.CreatedAt.After(filter.CreatedFrom.AsTime()) {
+			return false
+		}
+		if filter.CreatedTo != nil && !e.User.CreatedAt.Before(filter.CreatedTo.AsTime()) {
+			return false
+		}
+		return true
+	}
+}
+
+// Copy of the user without password, safe to share with subscribers.
+func eventUser(user domain.User) *domain.User {
+	user.Password = ""
+	return &user
+}
+
+// Create user domain model from CreateUserRequest and hashed password.
+func userFromCreateReq(req *proto.CreateUserRequest, password string) domain.User {
 	return domain.User{
 		Id:        uuid.New(),
 		Firstname: req.Firstname,
 		Lastname:  req.Lastname,
 		Nickname:  req.Nickname,
-		Password:  hashPassword(req.Password),
+		Password:  password,
 		Email:     req.Email,
 		Country:   strings.ToUpper(req.Country),
 	}
 }
 
-// Create user update model from UpdateUserRequest
-func userFromUpdateReq(req *proto.UpdateUserRequest) domain.User {
+// Create user update model from UpdateUserRequest and hashed password
+func userFromUpdateReq(req *proto.UpdateUserRequest, password string) domain.User {
 	return domain.User{
 		Id:        uuid.MustParse(req.Id),
 		Firstname: req.Firstname,
 		Lastname:  req.Lastname,
 		Nickname:  req.Nickname,
-		Password:  hashPassword(req.Password),
+		Password:  password,
 		Email:     req.Email,
 		Country:   strings.ToUpper(req.Country),
 	}
 }
-
-// Returns hash value of password
-func hashPassword(password string) string {
-	hashedBytes, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hashedBytes)
-}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
+	eventsMock "usermanager/app/infrastructure/events/mocks"
 	notifMock "usermanager/app/infrastructure/notification/mocks"
 	repoMock "usermanager/app/infrastructure/repositories/mocks"
 	proto "usermanager/app/ui/protos/user"
@@ -12,16 +15,40 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// repo Add and Update result, the user as it was passed in
+func storedUser(_ context.Context, user domain.User) domain.User {
+	return user
+}
+
 // create user service with mocked objects
 func createUserService() (UserService, *repoMock.UserRepoMock, *notifMock.NotificationServiceMock) {
+	userService, mockedUserRepo, mockedNotifService, mockedEventHub := createUserServiceWithHub()
+
+	mockedEventHub.
+		On("Publish", mock.Anything, mock.Anything, mock.Anything).
+		Return()
+
+	return userService, mockedUserRepo, mockedNotifService
+}
+
+// create user service with mocked objects, including the event hub
+func createUserServiceWithHub() (UserService, *repoMock.UserRepoMock,
+	*notifMock.NotificationServiceMock, *eventsMock.UserEventHubMock) {
 	mockedUserRepo := &repoMock.UserRepoMock{}
 	mockedNotifService := &notifMock.NotificationServiceMock{}
+	mockedEventHub := &eventsMock.UserEventHubMock{}
 
-	userService := NewUserService(mockedUserRepo, mockedNotifService)
-	return userService, mockedUserRepo, mockedNotifService
+	userService := NewUserService(mockedUserRepo, mockedNotifService, mockedEventHub, 0)
+	return userService, mockedUserRepo, mockedNotifService, mockedEventHub
 }
 
 func TestAdd_RepoAddErr_ShouldReturnErr(t *testing.T) {
@@ -33,11 +60,11 @@ func TestAdd_RepoAddErr_ShouldReturnErr(t *testing.T) {
 	req := &proto.CreateUserRequest{}
 
 	mockedUserRepo.
-		On("Add", mock.AnythingOfType("User")).
-		Return(expectedErr)
+		On("Add", mock.Anything, mock.AnythingOfType("User")).
+		Return(domain.User{}, expectedErr)
 
 	// act
-	res, err := userService.Add(req)
+	res, err := userService.Add(context.Background(), req)
 
 	// assert
 	assert.NotNil(t, err)
@@ -72,11 +99,11 @@ func TestAdd_RepoAddPass_ShouldReturnResult(t *testing.T) {
 	})
 
 	mockedUserRepo.
-		On("Add", userParamMatcher).
-		Return(nil)
+		On("Add", mock.Anything, userParamMatcher).
+		Return(storedUser, nil)
 
 	// act
-	res, err := userService.Add(req)
+	res, err := userService.Add(context.Background(), req)
 
 	// assert
 	assert.NotNil(t, res)
@@ -99,11 +126,11 @@ func TestUpdate_RepoUpdateErr_ShouldReturnErr(t *testing.T) {
 	}
 
 	mockedUserRepo.
-		On("Update", mock.AnythingOfType("User")).
-		Return(expectedErr)
+		On("Update", mock.Anything, mock.AnythingOfType("User")).
+		Return(domain.User{}, expectedErr)
 
 	// act
-	err := userService.Update(req)
+	err := userService.Update(context.Background(), req)
 
 	// assert
 	assert.NotNil(t, err)
@@ -138,15 +165,15 @@ func TestUpdate_RepoUpdatePass_ShouldReturnResult(t *testing.T) {
 	})
 
 	mockedUserRepo.
-		On("Update", userParamMatcher).
-		Return(nil)
+		On("Update", mock.Anything, userParamMatcher).
+		Return(storedUser, nil)
 
 	mockedNotifService.
-		On("NotifyAboutUserChange", uuid.MustParse(req.Id)).
+		On("NotifyAboutUserChange", mock.Anything, uuid.MustParse(req.Id)).
 		Return(nil)
 
 	// act
-	err := userService.Update(req)
+	err := userService.Update(context.Background(), req)
 
 	// assert
 	assert.Nil(t, err)
@@ -159,15 +186,15 @@ func TestUpdate_RepoUpdatePass_ShouldSendNotification(t *testing.T) {
 	req := &proto.UpdateUserRequest{Id: "eb24efdf-0043-4df7-b736-1486068abf03"}
 
 	mockedNotifService.
-		On("NotifyAboutUserChange", uuid.MustParse(req.Id)).
+		On("NotifyAboutUserChange", mock.Anything, uuid.MustParse(req.Id)).
 		Return(nil)
 
 	mockedUserRepo.
-		On("Update", mock.AnythingOfType("User")).
-		Return(nil)
+		On("Update", mock.Anything, mock.AnythingOfType("User")).
+		Return(storedUser, nil)
 
 	// act
-	err := userService.Update(req)
+	err := userService.Update(context.Background(), req)
 
 	// because NotifyAboutUserChange will run async in another goroutine,
 	// we need make sure that NotifyAboutUserChange is called before we
@@ -176,7 +203,217 @@ func TestUpdate_RepoUpdatePass_ShouldSendNotification(t *testing.T) {
 
 	// assert
 	assert.Nil(t, err)
-	mockedNotifService.AssertCalled(t, "NotifyAboutUserChange", uuid.MustParse(req.Id))
+	mockedNotifService.AssertCalled(t, "NotifyAboutUserChange", mock.Anything, uuid.MustParse(req.Id))
+}
+
+func TestAdd_RepoAddPass_ShouldPublishEventWithoutPassword(t *testing.T) {
+	userService, mockedUserRepo, _, mockedEventHub := createUserServiceWithHub()
+
+	// arrange
+	req := &proto.CreateUserRequest{Nickname: "test", Password: "test-pass", Country: "rs"}
+
+	createdAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockedUserRepo.
+		On("Add", mock.Anything, mock.AnythingOfType("User")).
+		Return(func(_ context.Context, user domain.User) domain.User {
+			user.CreatedAt = createdAt
+			return user
+		}, nil)
+
+	eventUserMatcher := mock.MatchedBy(func(user *domain.User) bool {
+		return user.Nickname == req.Nickname && user.Password == "" &&
+			user.Country == "RS" && user.CreatedAt.Equal(createdAt)
+	})
+	mockedEventHub.
+		On("Publish", domain.UserCreated, mock.AnythingOfType("UUID"), eventUserMatcher).
+		Return()
+
+	// act
+	id, err := userService.Add(context.Background(), req)
+
+	// assert
+	assert.Nil(t, err)
+	mockedEventHub.AssertCalled(t, "Publish", domain.UserCreated, id, eventUserMatcher)
+}
+
+func TestUpdate_RepoUpdatePass_ShouldPublishStoredUser(t *testing.T) {
+	userService, mockedUserRepo, mockedNotifService, mockedEventHub := createUserServiceWithHub()
+
+	// arrange
+	req := &proto.UpdateUserRequest{Id: uuid.NewString(), Firstname: "changed"}
+	stored := domain.User{
+		Id:        uuid.MustParse(req.Id),
+		Firstname: "changed",
+		Nickname:  "test",
+		Password:  "hash",
+		Country:   "RS",
+		CreatedAt: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	mockedUserRepo.
+		On("Update", mock.Anything, mock.AnythingOfType("User")).
+		Return(stored, nil)
+	mockedNotifService.On("NotifyAboutUserChange", mock.Anything, mock.Anything)
+
+	eventUserMatcher := mock.MatchedBy(func(user *domain.User) bool {
+		return user.Nickname == stored.Nickname && user.Password == "" &&
+			user.CreatedAt.Equal(stored.CreatedAt)
+	})
+	mockedEventHub.
+		On("Publish", domain.UserUpdated, stored.Id, eventUserMatcher).
+		Return()
+
+	// act
+	err := userService.Update(context.Background(), req)
+
+	// assert
+	assert.Nil(t, err)
+	mockedEventHub.AssertCalled(t, "Publish", domain.UserUpdated, stored.Id, eventUserMatcher)
+}
+
+func TestAdd_RepoAddErr_ShouldNotPublishEvent(t *testing.T) {
+	userService, mockedUserRepo, _, mockedEventHub := createUserServiceWithHub()
+
+	// arrange
+	mockedUserRepo.
+		On("Add", mock.Anything, mock.AnythingOfType("User")).
+		Return(domain.User{}, errors.New("test error"))
+
+	// act
+	_, err := userService.Add(context.Background(), &proto.CreateUserRequest{})
+
+	// assert
+	assert.NotNil(t, err)
+	mockedEventHub.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDelete_RepoDeletePass_ShouldPublishEvent(t *testing.T) {
+	userService, _, _, mockedEventHub := createUserServiceWithHub()
+
+	// arrange
+	id := uuid.New()
+	var noUser *domain.User
+
+	mockedEventHub.
+		On("Publish", domain.UserDeleted, id, noUser).
+		Return()
+
+	// act
+	err := userService.Delete(context.Background(), id.String())
+
+	// assert
+	assert.Nil(t, err)
+	mockedEventHub.AssertCalled(t, "Publish", domain.UserDeleted, id, noUser)
+}
+
+func TestGet_RepoGetPass_ShouldReturnUser(t *testing.T) {
+	userService, mockedUserRepo, _ := createUserService()
+
+	// arrange
+	user := domain.User{Id: uuid.New(), Nickname: "ben"}
+
+	mockedUserRepo.
+		On("Get", mock.Anything, user.Id).
+		Return(user, nil)
+
+	// act
+	result, err := userService.Get(context.Background(), user.Id.String())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, user, result)
+}
+
+func TestWatch_WithResumeParams_ShouldSubscribeFromResumePoint(t *testing.T) {
+	userService, _, _, mockedEventHub := createUserServiceWithHub()
+
+	// arrange
+	since := time.Now().Add(-time.Hour)
+	req := &proto.WatchUsersRequest{Since: timestamppb.New(since)}
+	expectedFrom := events.ResumePoint{Since: since.UTC()}
+
+	mockedEventHub.
+		On("Subscribe", expectedFrom, mock.Anything).
+		Return(nil, nil)
+
+	// act
+	_, err := userService.Watch(req)
+
+	// assert
+	assert.Nil(t, err)
+	mockedEventHub.AssertCalled(t, "Subscribe", expectedFrom, mock.Anything)
+}
+
+func TestEventFilter_ByCountry_ShouldMatchOnlyCountry(t *testing.T) {
+	filter := eventFilter(&proto.UserPageRequest_UserFilterOptions{Country: "rs"})
+
+	assert.True(t, filter(domain.UserEvent{User: &domain.User{Country: "RS"}}))
+	assert.False(t, filter(domain.UserEvent{User: &domain.User{Country: "DE"}}))
+	// deleted users carry no data and are always delivered
+	assert.True(t, filter(domain.UserEvent{Type: domain.UserDeleted}))
+}
+
+func TestEventFilter_ByCreatedRange_ShouldMatchOnlyRange(t *testing.T) {
+	now := time.Now()
+	filter := eventFilter(&proto.UserPageRequest_UserFilterOptions{
+		CreatedFrom: timestamppb.New(now.Add(-time.Hour)),
+		CreatedTo:   timestamppb.New(now.Add(time.Hour)),
+	})
+
+	assert.True(t, filter(domain.UserEvent{User: &domain.User{CreatedAt: now}}))
+	assert.False(t, filter(domain.UserEvent{User: &domain.User{CreatedAt: now.Add(-2 * time.Hour)}}))
+}
+
+func TestAdd_ShouldPassContextToRepo(t *testing.T) {
+	userService, mockedUserRepo, _ := createUserService()
+
+	// arrange
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	requestCtx := mock.MatchedBy(func(c context.Context) bool {
+		return c.Value(ctxKey{}) == "request"
+	})
+
+	mockedUserRepo.
+		On("Add", requestCtx, mock.AnythingOfType("User")).
+		Return(storedUser, nil)
+
+	// act
+	_, err := userService.Add(ctx, &proto.CreateUserRequest{})
+
+	// assert
+	assert.Nil(t, err)
+	mockedUserRepo.AssertCalled(t, "Add", requestCtx, mock.AnythingOfType("User"))
+}
+
+func TestUpdate_ShouldTraceRepoCallUnderServiceSpan(t *testing.T) {
+	userService, mockedUserRepo, mockedNotifService := createUserService()
+
+	// arrange
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	req := &proto.UpdateUserRequest{Id: uuid.NewString()}
+	var repoSpan trace.SpanContext
+	mockedUserRepo.
+		On("Update", mock.Anything, mock.AnythingOfType("User")).
+		Run(func(args mock.Arguments) {
+			repoSpan = trace.SpanContextFromContext(args.Get(0).(context.Context))
+		}).
+		Return(domain.User{}, errors.New("test err"))
+	mockedNotifService.On("NotifyAboutUserChange", mock.Anything, mock.Anything)
+
+	// act
+	err := userService.Update(context.Background(), req)
+
+	// assert
+	assert.NotNil(t, err)
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "UserService.Update", spans[0].Name())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), repoSpan.SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
 }
 
 func compareHashAndPass(password string) bool {
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"usermanager/app/domain"
+	repo "usermanager/app/infrastructure/repositories"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 1000
+)
+
+type WebhookService interface {
+	Create(ctx context.Context, req *proto.CreateWebhookRequest) (domain.Webhook, error)
+	List(ctx context.Context) ([]domain.Webhook, error)
+	Update(ctx context.Context, req *proto.UpdateWebhookRequest) (domain.Webhook, error)
+	Delete(ctx context.Context, id string) error
+	Deliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) ([]domain.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	repo repo.WebhookRepo
+}
+
+// Create service that manages webhook subscriptions.
+func NewWebhookService(r repo.WebhookRepo) *webhookService {
+	return &webhookService{repo: r}
+}
+
+// Create enabled webhook. Secret is generated if the request has none.
+// Returns created webhook or error if ocurred.
+func (w *webhookService) Create(ctx context.Context, req *proto.CreateWebhookRequest) (domain.Webhook, error) {
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return domain.Webhook{}, status.Errorf(codes.Internal, "cannot generate secret: %v", err)
+		}
+	}
+
+	webhook := domain.Webhook{
+		Id:         uuid.New(),
+		Url:        req.Url,
+		EventTypes: domainEventTypes(req.EventTypes),
+		Secret:     secret,
+		Enabled:    true,
+	}
+	if err := w.repo.Add(ctx, webhook); err != nil {
+		return domain.Webhook{}, err
+	}
+	return w.repo.Get(ctx, webhook.Id)
+}
+
+// List all webhooks. Returns webhooks or error if ocurred.
+func (w *webhookService) List(ctx context.Context) ([]domain.Webhook, error) {
+	return w.repo.List(ctx)
+}
+
+// Replace url and event types of the webhook, change secret and
+// enabled state if they are set. Returns updated webhook or error
+// if ocurred.
+func (w *webhookService) Update(ctx context.Context, req *proto.UpdateWebhookRequest) (domain.Webhook, error) {
+	webhook, err := w.repo.Get(ctx, uuid.MustParse(req.Id))
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+
+	webhook.Url = req.Url
+	webhook.EventTypes = domainEventTypes(req.EventTypes)
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	if req.Enabled != nil {
+		// enabled webhook gets a fresh start
+		if *req.Enabled && !webhook.Enabled {
+			webhook.ConsecutiveFailures = 0
+		}
+		webhook.Enabled = *req.Enabled
+	}
+
+	if err := w.repo.Update(ctx, webhook); err != nil {
+		return domain.Webhook{}, err
+	}
+	return w.repo.Get(ctx, webhook.Id)
+}
+
+// Delete webhook with provided id. Returns error if ocurred.
+func (w *webhookService) Delete(ctx context.Context, id string) error {
+	return w.repo.Delete(ctx, uuid.MustParse(id))
+}
+
+// Newest delivery attempts of the webhook. Returns NotFound
+// error if there is no such webhook.
+func (w *webhookService) Deliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) ([]domain.WebhookDelivery, error) {
+	webhookId := uuid.MustParse(req.WebhookId)
+	if _, err := w.repo.Get(ctx, webhookId); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultDeliveriesLimit
+	}
+	if limit > maxDeliveriesLimit {
+		limit = maxDeliveriesLimit
+	}
+	return w.repo.ListDeliveries(ctx, webhookId, limit)
+}
+
+// Random 32 byte secret, hex encoded.
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+func domainEventTypes(types []proto.UserEvent_Type) []domain.UserEventType {
+	if len(types) == 0 {
+		return nil
+	}
+	res := make([]domain.UserEventType, 0, len(types))
+	for _, t := range types {
+		switch t {
+		case proto.UserEvent_CREATED:
+			res = append(res, domain.UserCreated)
+		case proto.UserEvent_UPDATED:
+			res = append(res, domain.UserUpdated)
+		case proto.UserEvent_DELETED:
+			res = append(res, domain.UserDeleted)
+		}
+	}
+	return res
+}
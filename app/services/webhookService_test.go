@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"usermanager/app/domain"
+	repoMock "usermanager/app/infrastructure/repositories/mocks"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCreateWebhook_WithoutSecret_ShouldGenerateSecret(t *testing.T) {
+	mockedRepo := &repoMock.WebhookRepoMock{}
+	webhookService := NewWebhookService(mockedRepo)
+
+	// arrange
+	var added domain.Webhook
+	mockedRepo.
+		On("Add", mock.Anything, mock.AnythingOfType("domain.Webhook")).
+		Run(func(args mock.Arguments) { added = args.Get(1).(domain.Webhook) }).
+		Return(nil)
+	mockedRepo.
+		On("Get", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+		Return(domain.Webhook{}, nil)
+
+	// act
+	_, err := webhookService.Create(context.Background(), &proto.CreateWebhookRequest{
+		Url:        "https://partner.example.com/hooks",
+		EventTypes: []proto.UserEvent_Type{proto.UserEvent_DELETED},
+	})
+
+	// assert
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(added.Secret, "whsec_"))
+	assert.Equal(t, []domain.UserEventType{domain.UserDeleted}, added.EventTypes)
+	assert.True(t, added.Enabled)
+}
+
+func TestUpdateWebhook_Enable_ShouldResetFailuresAndKeepSecret(t *testing.T) {
+	mockedRepo := &repoMock.WebhookRepoMock{}
+	webhookService := NewWebhookService(mockedRepo)
+
+	// arrange
+	id := uuid.New()
+	stored := domain.Webhook{Id: id, Url: "https://old.example.com", Secret: "whsec_old", ConsecutiveFailures: 10}
+	enabled := true
+	var updated domain.Webhook
+	mockedRepo.On("Get", mock.Anything, id).Return(stored, nil)
+	mockedRepo.
+		On("Update", mock.Anything, mock.AnythingOfType("domain.Webhook")).
+		Run(func(args mock.Arguments) { updated = args.Get(1).(domain.Webhook) }).
+		Return(nil)
+
+	// act
+	_, err := webhookService.Update(context.Background(), &proto.UpdateWebhookRequest{
+		Id:      id.String(),
+		Url:     "https://new.example.com",
+		Enabled: &enabled,
+	})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "https://new.example.com", updated.Url)
+	assert.Equal(t, "whsec_old", updated.Secret)
+	assert.True(t, updated.Enabled)
+	assert.Equal(t, 0, updated.ConsecutiveFailures)
+}
+
+func TestWebhookDeliveries_UnknownWebhook_ShouldReturnNotFound(t *testing.T) {
+	mockedRepo := &repoMock.WebhookRepoMock{}
+	webhookService := NewWebhookService(mockedRepo)
+
+	// arrange
+	id := uuid.New()
+	mockedRepo.
+		On("Get", mock.Anything, id).
+		Return(domain.Webhook{}, status.Error(codes.NotFound, "no webhook in database"))
+
+	// act
+	_, err := webhookService.Deliveries(context.Background(), &proto.ListWebhookDeliveriesRequest{WebhookId: id.String()})
+
+	// assert
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockedRepo.AssertNotCalled(t, "ListDeliveries", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWebhookDeliveries_LimitTooHigh_ShouldCapLimit(t *testing.T) {
+	mockedRepo := &repoMock.WebhookRepoMock{}
+	webhookService := NewWebhookService(mockedRepo)
+
+	// arrange
+	id := uuid.New()
+	mockedRepo.On("Get", mock.Anything, id).Return(domain.Webhook{Id: id}, nil)
+	mockedRepo.On("ListDeliveries", mock.Anything, id, maxDeliveriesLimit).Return([]domain.WebhookDelivery{}, nil)
+
+	// act
+	_, err := webhookService.Deliveries(context.Background(),
+		&proto.ListWebhookDeliveriesRequest{WebhookId: id.String(), Limit: 5000})
+
+	// assert
+	assert.Nil(t, err)
+	mockedRepo.AssertExpectations(t)
+}
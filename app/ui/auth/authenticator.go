@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	AuthorizationHeader = "authorization"
+	APIKeyHeader        = "x-api-key"
+	RoleClaim           = "role"
+	bearerPrefix        = "bearer "
+)
+
+type Config struct {
+	// HS256 or RS256, used with JwtKeyFile
+	JwtAlgorithm string
+	// file with HS256 secret or RS256 public key
+	JwtKeyFile string
+	// file with JSON Web Key Set, used instead of JwtKeyFile
+	JwksFile string
+	// expected 'iss' and 'aud' claims, not checked if empty
+	JwtIssuer   string
+	JwtAudience string
+	// api key settings by api key
+	ApiKeys map[string]APIKey
+	// accept verified TLS client certificates (mTLS)
+	ClientCerts bool
+	// client certificate subjects of trusted proxies, like the gateway.
+	// They are never a principal, callers of a proxy need credentials.
+	ProxySubjects []string
+}
+
+// Caller that authenticates with a static api key.
+type APIKey struct {
+	Name string
+	Role string
+}
+
+type Authenticator interface {
+	// Verify credentials from the request metadata and return principal.
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+type authenticator struct {
+	jwtKeys       verificationKeys
+	parser        *jwt.Parser
+	apiKeys       map[string]APIKey
+	clientCerts   bool
+	proxySubjects []string
+}
+
+// Create authenticator that accepts JWTs signed with configured keys
+// and static api keys. Returns error if keys cannot be loaded.
+func NewAuthenticator(c Config) (*authenticator, error) {
+	a := &authenticator{apiKeys: c.ApiKeys, clientCerts: c.ClientCerts, proxySubjects: c.ProxySubjects}
+
+	var err error
+	switch {
+	case c.JwksFile != "":
+		a.jwtKeys, err = loadJwksFile(c.JwksFile)
+	case c.JwtKeyFile != "":
+		a.jwtKeys, err = loadKeyFile(c.JwtAlgorithm, c.JwtKeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.jwtKeys) == 0 && len(a.apiKeys) == 0 && !a.clientCerts {
+		return nil, errors.New("no jwt keys, api keys or client certificates configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256}),
+		jwt.WithExpirationRequired(),
+	}
+	if c.JwtIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(c.JwtIssuer))
+	}
+	if c.JwtAudience != "" {
+		opts = append(opts, jwt.WithAudience(c.JwtAudience))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+func (a *authenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(APIKeyHeader); len(values) > 0 {
+		return a.authenticateAPIKey(values[0])
+	}
+
+	if values := md.Get(AuthorizationHeader); len(values) > 0 {
+		header := values[0]
+		if !strings.HasPrefix(strings.ToLower(header), bearerPrefix) {
+			return nil, status.Error(codes.Unauthenticated, "authorization header should be a bearer token")
+		}
+		return a.authenticateJWT(strings.TrimSpace(header[len(bearerPrefix):]))
+	}
+
+	if a.clientCerts && !IsProxy(ctx, a.proxySubjects) {
+		if p, ok := ClientCertPrincipal(ctx); ok {
+			return p, nil
+		}
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "missing credentials")
+}
+
+func (a *authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	// compare every key in constant time, so time doesn't reveal the key
+	var apiKey *APIKey
+	for k, v := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			v := v
+			apiKey = &v
+		}
+	}
+
+	if apiKey == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return &Principal{
+		Subject: apiKey.Name,
+		Method:  MethodAPIKey,
+		Role:    apiKey.Role,
+		Claims:  map[string]interface{}{},
+	}, nil
+}
+
+func (a *authenticator) authenticateJWT(tokenString string) (*Principal, error) {
+	if len(a.jwtKeys) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "jwt authentication is not configured")
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(tokenString, claims, a.keyFunc); err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid token: subject is required")
+	}
+
+	role, _ := claims[RoleClaim].(string)
+
+	return &Principal{
+		Subject: subject,
+		Method:  MethodJWT,
+		Role:    role,
+		Claims:  claims,
+	}, nil
+}
+
+// Select verification key by 'kid' header and make sure the key type
+// matches the token algorithm, so RSA public key can't be used as HMAC secret.
+func (a *authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.jwtKeys[kid]
+	if !ok && kid == "" && len(a.jwtKeys) == 1 {
+		for _, k := range a.jwtKeys {
+			key, ok = k, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	switch key.(type) {
+	case []byte:
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Method.Alg())
+		}
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Method.Alg())
+		}
+	}
+	return key, nil
+}
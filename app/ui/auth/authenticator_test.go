@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var hmacSecret = []byte("test-secret")
+
+// write content to the file in test temp dir and return its path
+func writeFile(t *testing.T, name string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("cannot write %v: %v", name, err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("cannot sign token: %v", err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func bearerCtx(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(AuthorizationHeader, "Bearer "+token))
+}
+
+func TestAuthenticate_ValidHS256Token_ShouldReturnPrincipal(t *testing.T) {
+	a, err := NewAuthenticator(Config{
+		JwtAlgorithm: AlgorithmHS256,
+		JwtKeyFile:   writeFile(t, "secret", hmacSecret),
+	})
+	assert.Nil(t, err)
+
+	token := signToken(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims())
+	principal, err := a.Authenticate(bearerCtx(token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", principal.Subject)
+	assert.Equal(t, MethodJWT, principal.Method)
+}
+
+func TestAuthenticate_ExpiredToken_ShouldReturnErr(t *testing.T) {
+	a, _ := NewAuthenticator(Config{
+		JwtAlgorithm: AlgorithmHS256,
+		JwtKeyFile:   writeFile(t, "secret", hmacSecret),
+	})
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	token := signToken(t, jwt.SigningMethodHS256, hmacSecret, "", claims)
+	principal, err := a.Authenticate(bearerCtx(token))
+
+	assert.Nil(t, principal)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate_WrongIssuer_ShouldReturnErr(t *testing.T) {
+	a, _ := NewAuthenticator(Config{
+		JwtAlgorithm: AlgorithmHS256,
+		JwtKeyFile:   writeFile(t, "secret", hmacSecret),
+		JwtIssuer:    "usermanager",
+	})
+
+	claims := validClaims()
+	claims["iss"] = "other"
+	token := signToken(t, jwt.SigningMethodHS256, hmacSecret, "", claims)
+	_, err := a.Authenticate(bearerCtx(token))
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate_ValidRS256Token_ShouldReturnPrincipal(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	a, err := NewAuthenticator(Config{
+		JwtAlgorithm: AlgorithmRS256,
+		JwtKeyFile:   writeFile(t, "key.pem", pemKey),
+	})
+	assert.Nil(t, err)
+
+	token := signToken(t, jwt.SigningMethodRS256, key, "", validClaims())
+	principal, err := a.Authenticate(bearerCtx(token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", principal.Subject)
+}
+
+func TestAuthenticate_HS256TokenSignedWithRSAPublicKey_ShouldReturnErr(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	a, _ := NewAuthenticator(Config{
+		JwtAlgorithm: AlgorithmRS256,
+		JwtKeyFile:   writeFile(t, "key.pem", pemKey),
+	})
+
+	// classic algorithm confusion attack
+	token := signToken(t, jwt.SigningMethodHS256, pemKey, "", validClaims())
+	_, err := a.Authenticate(bearerCtx(token))
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate_JwksKeys_ShouldSelectKeyById(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kid": "rsa-1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+			{
+				"kid": "hmac-1",
+				"kty": "oct",
+				"k":   base64.RawURLEncoding.EncodeToString(hmacSecret),
+			},
+		},
+	}
+	b, _ := json.Marshal(set)
+
+	a, err := NewAuthenticator(Config{JwksFile: writeFile(t, "jwks.json", b)})
+	assert.Nil(t, err)
+
+	rsaToken := signToken(t, jwt.SigningMethodRS256, key, "rsa-1", validClaims())
+	_, err = a.Authenticate(bearerCtx(rsaToken))
+	assert.Nil(t, err)
+
+	hmacToken := signToken(t, jwt.SigningMethodHS256, hmacSecret, "hmac-1", validClaims())
+	_, err = a.Authenticate(bearerCtx(hmacToken))
+	assert.Nil(t, err)
+
+	unknownToken := signToken(t, jwt.SigningMethodHS256, hmacSecret, "unknown", validClaims())
+	_, err = a.Authenticate(bearerCtx(unknownToken))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate_ValidApiKey_ShouldReturnPrincipal(t *testing.T) {
+	a, _ := NewAuthenticator(Config{ApiKeys: map[string]APIKey{"secret-key": {Name: "ops-script", Role: RoleSupport}}})
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(APIKeyHeader, "secret-key"))
+
+	principal, err := a.Authenticate(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ops-script", principal.Subject)
+	assert.Equal(t, MethodAPIKey, principal.Method)
+}
+
+func TestAuthenticate_InvalidApiKey_ShouldReturnErr(t *testing.T) {
+	a, _ := NewAuthenticator(Config{ApiKeys: map[string]APIKey{"secret-key": {Name: "ops-script", Role: RoleSupport}}})
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(APIKeyHeader, "wrong-key"))
+
+	principal, err := a.Authenticate(ctx)
+
+	assert.Nil(t, principal)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate_NoCredentials_ShouldReturnErr(t *testing.T) {
+	a, _ := NewAuthenticator(Config{ApiKeys: map[string]APIKey{"secret-key": {Name: "ops-script", Role: RoleSupport}}})
+
+	principal, err := a.Authenticate(context.Background())
+
+	assert.Nil(t, principal)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestNewAuthenticator_NothingConfigured_ShouldReturnErr(t *testing.T) {
+	a, err := NewAuthenticator(Config{})
+
+	assert.Nil(t, a)
+	assert.NotNil(t, err)
+}
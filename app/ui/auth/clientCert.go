@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Principal from the verified TLS client certificate. Subject is the
+// certificate common name (or the first DNS name if it is empty) and
+// the role is the first organizational unit.
+func ClientCertPrincipal(ctx context.Context) (*Principal, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	subject := CertSubject(cert)
+	if subject == "" {
+		return nil, false
+	}
+
+	role := ""
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		role = cert.Subject.OrganizationalUnit[0]
+	}
+
+	return &Principal{
+		Subject: subject,
+		Method:  MethodMTLS,
+		Role:    role,
+		Claims: map[string]interface{}{
+			"serial": cert.SerialNumber.String(),
+			"issuer": cert.Issuer.String(),
+		},
+	}, true
+}
+
+// Whether the peer is one of the trusted proxies, like the gateway, by
+// the subject of its verified client certificate. A proxy certificate
+// identifies the connection, not the caller the proxy forwards.
+func IsProxy(ctx context.Context, proxySubjects []string) bool {
+	if len(proxySubjects) == 0 {
+		return false
+	}
+	p, ok := ClientCertPrincipal(ctx)
+	if !ok {
+		return false
+	}
+	for _, subject := range proxySubjects {
+		if p.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// Common name of the certificate, or the first DNS name if it is empty.
+func CertSubject(cert *x509.Certificate) string {
+	if cert.Subject.CommonName == "" && len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
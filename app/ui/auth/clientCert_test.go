@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerCtx(chains [][]*x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{},
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: chains},
+		},
+	})
+}
+
+func TestClientCertPrincipal_VerifiedCert_ShouldReturnPrincipal(t *testing.T) {
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "billing-service", OrganizationalUnit: []string{RoleSupport}},
+	}
+
+	p, ok := ClientCertPrincipal(peerCtx([][]*x509.Certificate{{cert}}))
+
+	assert.True(t, ok)
+	assert.Equal(t, "billing-service", p.Subject)
+	assert.Equal(t, RoleSupport, p.Role)
+	assert.Equal(t, MethodMTLS, p.Method)
+}
+
+func TestClientCertPrincipal_NoVerifiedCert_ShouldReturnFalse(t *testing.T) {
+	_, ok := ClientCertPrincipal(peerCtx(nil))
+	assert.False(t, ok)
+
+	_, ok = ClientCertPrincipal(context.Background())
+	assert.False(t, ok)
+}
+
+func TestAuthenticate_ClientCertsEnabled_ShouldUseCertPrincipal(t *testing.T) {
+	a, err := NewAuthenticator(Config{ClientCerts: true})
+	assert.Nil(t, err)
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ops"}}
+
+	p, err := a.Authenticate(peerCtx([][]*x509.Certificate{{cert}}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ops", p.Subject)
+}
+
+func TestAuthenticate_ProxyCertWithoutCredentials_ShouldReturnUnauthenticated(t *testing.T) {
+	a, err := NewAuthenticator(Config{ClientCerts: true, ProxySubjects: []string{"gateway"}})
+	assert.Nil(t, err)
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "gateway", OrganizationalUnit: []string{RoleAdmin}}}
+
+	p, err := a.Authenticate(peerCtx([][]*x509.Certificate{{cert}}))
+
+	assert.Nil(t, p)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
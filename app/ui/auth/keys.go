@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+// Keys used to verify token signatures. Key without id is used for
+// tokens that don't have 'kid' header.
+type verificationKeys map[string]interface{}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// Load single key from file. For HS256 file contains shared secret,
+// for RS256 PEM encoded public key or certificate.
+func loadKeyFile(algorithm string, path string) (verificationKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwt key file: %v", err)
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := []byte(strings.TrimSpace(string(b)))
+		if len(secret) == 0 {
+			return nil, errors.New("jwt secret is empty")
+		}
+		return verificationKeys{"": secret}, nil
+	case AlgorithmRS256:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(b)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse jwt public key: %v", err)
+		}
+		return verificationKeys{"": key}, nil
+	}
+	return nil, fmt.Errorf("unsupported jwt algorithm %v", algorithm)
+}
+
+// Load keys from JWKS file. RSA keys are used for RS256 tokens
+// and symmetric (oct) keys for HS256 tokens.
+func loadJwksFile(path string) (verificationKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwks file: %v", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("cannot parse jwks file: %v", err)
+	}
+
+	keys := verificationKeys{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwks file has no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad modulus: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad exponent: %v", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("bad secret: %v", err)
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %v", k.Kty)
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"fmt"
+	proto "usermanager/app/ui/protos/user"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Permission string
+
+const (
+	PermissionCreate Permission = "users.create"
+	PermissionRead   Permission = "users.read"
+	PermissionUpdate Permission = "users.update"
+	PermissionDelete Permission = "users.delete"
+	PermissionWatch  Permission = "users.watch"
+	// webhooks receive every user change, so only admins manage them
+	PermissionWebhooks Permission = "webhooks.manage"
+	// replaying dead letters republishes notifications to subscribers
+	PermissionDeadLetters Permission = "notifications.replay"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleUser    = "user"
+)
+
+// Scope of the granted permission.
+type Scope int
+
+const (
+	ScopeNone Scope = iota
+	// only records of the caller, where principal subject is the user id
+	ScopeOwn
+	ScopeAll
+)
+
+const errorDomain = "usermanager"
+
+// Permissions granted to each role.
+var rolePermissions = map[string]map[Permission]Scope{
+	RoleAdmin: {
+		PermissionCreate:      ScopeAll,
+		PermissionRead:        ScopeAll,
+		PermissionUpdate:      ScopeAll,
+		PermissionDelete:      ScopeAll,
+		PermissionWatch:       ScopeAll,
+		PermissionWebhooks:    ScopeAll,
+		PermissionDeadLetters: ScopeAll,
+	},
+	RoleSupport: {
+		PermissionRead:   ScopeAll,
+		PermissionUpdate: ScopeAll,
+		PermissionWatch:  ScopeAll,
+	},
+	RoleUser: {
+		PermissionRead:   ScopeOwn,
+		PermissionUpdate: ScopeOwn,
+	},
+}
+
+// Authorization rule for single RPC.
+type Rule struct {
+	Permission Permission
+	// Returns id of the user the request is about. Used for own scope.
+	Owner func(req interface{}) string
+	// Restricts response to the caller's own record. Used for own scope.
+	OwnResponse func(resp interface{}, subject string) interface{}
+}
+
+// Rules by full gRPC method name. Methods without rule are denied.
+var Policy = map[string]Rule{
+	userMethod("CreateUser"): {
+		Permission: PermissionCreate,
+	},
+	userMethod("UpdateUser"): {
+		Permission: PermissionUpdate,
+		Owner: func(req interface{}) string {
+			return req.(*proto.UpdateUserRequest).GetId()
+		},
+	},
+	userMethod("DeleteUser"): {
+		Permission: PermissionDelete,
+		Owner: func(req interface{}) string {
+			return req.(*proto.DeleteUserRequest).GetId()
+		},
+	},
+	userMethod("GetUser"): {
+		Permission: PermissionRead,
+		Owner: func(req interface{}) string {
+			return req.(*proto.GetUserRequest).GetId()
+		},
+	},
+	userMethod("GetUserPage"): {
+		Permission:  PermissionRead,
+		OwnResponse: ownUsers,
+	},
+	userMethod("WatchUsers"): {
+		Permission: PermissionWatch,
+	},
+	userMethod("CreateWebhook"): {
+		Permission: PermissionWebhooks,
+	},
+	userMethod("ListWebhooks"): {
+		Permission: PermissionWebhooks,
+	},
+	userMethod("UpdateWebhook"): {
+		Permission: PermissionWebhooks,
+	},
+	userMethod("DeleteWebhook"): {
+		Permission: PermissionWebhooks,
+	},
+	userMethod("ListWebhookDeliveries"): {
+		Permission: PermissionWebhooks,
+	},
+	userMethod("ListDeadLetters"): {
+		Permission: PermissionDeadLetters,
+	},
+	userMethod("ReplayDeadLetter"): {
+		Permission: PermissionDeadLetters,
+	},
+	userMethod("ReplayDeadLetters"): {
+		Permission: PermissionDeadLetters,
+	},
+}
+
+type Authorizer interface {
+	// Check whether principal may call the method with the request.
+	// Request is nil for streams. Returns the granted scope.
+	Authorize(p *Principal, method string, req interface{}) (Scope, error)
+	// Restrict response to what the granted scope allows.
+	FilterResponse(p *Principal, method string, scope Scope, resp interface{}) interface{}
+}
+
+type authorizer struct {
+	policy map[string]Rule
+	roles  map[string]map[Permission]Scope
+}
+
+// Create authorizer with the default policy and role permissions.
+func NewAuthorizer() *authorizer {
+	return &authorizer{
+		policy: Policy,
+		roles:  rolePermissions,
+	}
+}
+
+func (a *authorizer) Authorize(p *Principal, method string, req interface{}) (Scope, error) {
+	rule, ok := a.policy[method]
+	if !ok {
+		return ScopeNone, status.Errorf(codes.PermissionDenied, "no authorization rule for %v", method)
+	}
+
+	role := ""
+	if p != nil {
+		role = p.Role
+	}
+
+	switch a.roles[role][rule.Permission] {
+	case ScopeAll:
+		return ScopeAll, nil
+	case ScopeOwn:
+		if rule.Owner != nil && req != nil && rule.Owner(req) == p.Subject {
+			return ScopeOwn, nil
+		}
+		if rule.OwnResponse != nil {
+			return ScopeOwn, nil
+		}
+	}
+
+	return ScopeNone, permissionDenied(role, rule.Permission)
+}
+
+func (a *authorizer) FilterResponse(p *Principal, method string, scope Scope, resp interface{}) interface{} {
+	rule := a.policy[method]
+	if scope != ScopeOwn || rule.OwnResponse == nil || resp == nil {
+		return resp
+	}
+	return rule.OwnResponse(resp, p.Subject)
+}
+
+// Permission denied error with the required permission in the details.
+func permissionDenied(role string, permission Permission) error {
+	st := status.New(codes.PermissionDenied,
+		fmt.Sprintf("role %q doesn't have %v permission", role, permission))
+
+	details, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: "MISSING_PERMISSION",
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"permission": string(permission),
+			"role":       role,
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return details.Err()
+}
+
+// Only the caller's own user from the user page.
+func ownUsers(resp interface{}, subject string) interface{} {
+	page := resp.(*proto.UserPageResponse)
+
+	users := make([]*proto.UserPageResponse_User, 0, 1)
+	for _, u := range page.Users {
+		if u.Id == subject {
+			users = append(users, u)
+		}
+	}
+	return &proto.UserPageResponse{Users: users}
+}
+
+func userMethod(name string) string {
+	return fmt.Sprintf("/%v/%v", proto.UserService_ServiceDesc.ServiceName, name)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"testing"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	createMethod  = "/proto.UserService/CreateUser"
+	updateMethod  = "/proto.UserService/UpdateUser"
+	deleteMethod  = "/proto.UserService/DeleteUser"
+	getMethod     = "/proto.UserService/GetUser"
+	getPageMethod = "/proto.UserService/GetUserPage"
+	watchMethod   = "/proto.UserService/WatchUsers"
+
+	createWebhookMethod    = "/proto.UserService/CreateWebhook"
+	replayDeadLetterMethod = "/proto.UserService/ReplayDeadLetter"
+)
+
+func TestAuthorize_Admin_ShouldBeAllowedEverything(t *testing.T) {
+	a := NewAuthorizer()
+	admin := &Principal{Subject: "admin-1", Role: RoleAdmin}
+
+	for _, method := range []string{createMethod, updateMethod, deleteMethod, getPageMethod, watchMethod,
+		createWebhookMethod, replayDeadLetterMethod} {
+		scope, err := a.Authorize(admin, method, nil)
+
+		assert.Nil(t, err, method)
+		assert.Equal(t, ScopeAll, scope, method)
+	}
+}
+
+func TestAuthorize_SupportDelete_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+	support := &Principal{Subject: "support-1", Role: RoleSupport}
+
+	_, err := a.Authorize(support, updateMethod, &proto.UpdateUserRequest{Id: uuid.NewString()})
+	assert.Nil(t, err)
+
+	_, err = a.Authorize(support, deleteMethod, &proto.DeleteUserRequest{Id: uuid.NewString()})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	details := status.Convert(err).Details()
+	assert.Len(t, details, 1)
+	info := details[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, string(PermissionDelete), info.Metadata["permission"])
+	assert.Equal(t, RoleSupport, info.Metadata["role"])
+}
+
+func TestAuthorize_SupportCreatesWebhook_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+	support := &Principal{Subject: "support-1", Role: RoleSupport}
+
+	_, err := a.Authorize(support, createWebhookMethod, &proto.CreateWebhookRequest{})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthorize_UserUpdatesOwnRecord_ShouldBeAllowed(t *testing.T) {
+	a := NewAuthorizer()
+	id := uuid.NewString()
+	user := &Principal{Subject: id, Role: RoleUser}
+
+	scope, err := a.Authorize(user, updateMethod, &proto.UpdateUserRequest{Id: id})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ScopeOwn, scope)
+}
+
+func TestAuthorize_UserGetsOtherRecord_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+	user := &Principal{Subject: uuid.NewString(), Role: RoleUser}
+
+	_, err := a.Authorize(user, getMethod, &proto.GetUserRequest{Id: uuid.NewString()})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthorize_UserUpdatesOtherRecord_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+	user := &Principal{Subject: uuid.NewString(), Role: RoleUser}
+
+	_, err := a.Authorize(user, updateMethod, &proto.UpdateUserRequest{Id: uuid.NewString()})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthorize_UserWatch_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+	user := &Principal{Subject: uuid.NewString(), Role: RoleUser}
+
+	_, err := a.Authorize(user, watchMethod, nil)
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthorize_WithoutRoleOrUnknownMethod_ShouldBeDenied(t *testing.T) {
+	a := NewAuthorizer()
+
+	_, err := a.Authorize(nil, getPageMethod, &proto.UserPageRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = a.Authorize(&Principal{Role: RoleAdmin}, "/proto.UserService/Unknown", nil)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestFilterResponse_UserReadsPage_ShouldSeeOnlyOwnRecord(t *testing.T) {
+	a := NewAuthorizer()
+	id := uuid.NewString()
+	user := &Principal{Subject: id, Role: RoleUser}
+	page := &proto.UserPageResponse{Users: []*proto.UserPageResponse_User{
+		{Id: uuid.NewString()},
+		{Id: id},
+	}}
+
+	scope, err := a.Authorize(user, getPageMethod, &proto.UserPageRequest{})
+	assert.Nil(t, err)
+
+	res := a.FilterResponse(user, getPageMethod, scope, page).(*proto.UserPageResponse)
+
+	assert.Len(t, res.Users, 1)
+	assert.Equal(t, id, res.Users[0].Id)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api-key"
+	MethodMTLS   = "mtls"
+)
+
+// Verified identity of the caller.
+type Principal struct {
+	// Subject is the token subject or the name of the api key
+	Subject string
+	// Method used to authenticate the caller (jwt, api-key, mtls)
+	Method string
+	// Role used for authorization (admin, support, user)
+	Role string
+	// Claims from the token, empty for api keys
+	Claims map[string]interface{}
+}
+
+type principalKey struct{}
+
+// Returns new context with the principal attached.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Returns principal from the context if the caller is authenticated.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const OpenAPIPath = "/openapi.json"
+
+// Request headers passed to the gRPC server as metadata with the same
+// name. Authorization is always passed by the gateway.
+var forwardedHeaders = []string{
+	"x-api-key",
+	"x-request-id",
+	"idempotency-key",
+}
+
+// Request headers never passed to the gRPC server, neither by name nor
+// with the Grpc-Metadata- prefix of the default rule. The server may read
+// the caller identity from them for local development only.
+var droppedHeaders = []string{
+	"x-role",
+	"x-user-id",
+}
+
+// Response metadata returned to the HTTP caller as headers.
+var returnedHeaders = []string{
+	"x-request-id",
+	"idempotent-replayed",
+}
+
+type Config struct {
+	// address of the gRPC server the gateway forwards requests to
+	GrpcEndpoint string
+	// TLS settings for the connection to the gRPC server,
+	// nil means plain text connection
+	TLS *tls.Config
+}
+
+// Create HTTP handler that translates JSON requests to UserService
+// calls on the gRPC server, so every request goes through the same
+// interceptors. Errors are mapped from gRPC status codes to HTTP status
+// codes. OpenAPI document is served on OpenAPIPath.
+func NewGatewayHandler(ctx context.Context, c Config) (http.Handler, error) {
+	creds := insecure.NewCredentials()
+	if c.TLS != nil {
+		creds = credentials.NewTLS(c.TLS)
+	}
+
+	gatewayMux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher(forwardedHeaders, dropHeaders(droppedHeaders, runtime.DefaultHeaderMatcher))),
+		runtime.WithOutgoingHeaderMatcher(headerMatcher(returnedHeaders, metadataHeader)),
+	)
+	err := proto.RegisterUserServiceHandlerFromEndpoint(ctx, gatewayMux, c.GrpcEndpoint,
+		[]grpc.DialOption{grpc.WithTransportCredentials(creds)})
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", withClientAddress(gatewayMux))
+	mux.HandleFunc(OpenAPIPath, serveOpenAPI)
+	return mux, nil
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(proto.OpenAPI)
+}
+
+// Drops X-Forwarded-For of the caller, so the gateway forwards only the
+// remote address of the request in 'x-forwarded-for' metadata. The gRPC
+// server trusts it from the gateway certificate or TRUSTED_PROXIES
+// networks and limits rate per client ip.
+func withClientAddress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("X-Forwarded-For")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Passes listed headers unchanged, others are handled by the fallback.
+func headerMatcher(headers []string, fallback runtime.HeaderMatcherFunc) runtime.HeaderMatcherFunc {
+	return func(key string) (string, bool) {
+		for _, h := range headers {
+			if strings.EqualFold(key, h) {
+				return textproto.CanonicalMIMEHeaderKey(h), true
+			}
+		}
+		return fallback(key)
+	}
+}
+
+// Skips the headers, with or without the Grpc-Metadata- prefix, and
+// matches the rest with fallback.
+func dropHeaders(headers []string, fallback runtime.HeaderMatcherFunc) runtime.HeaderMatcherFunc {
+	return func(key string) (string, bool) {
+		name := strings.TrimPrefix(textproto.CanonicalMIMEHeaderKey(key), runtime.MetadataHeaderPrefix)
+		for _, h := range headers {
+			if strings.EqualFold(name, h) {
+				return "", false
+			}
+		}
+		return fallback(key)
+	}
+}
+
+// Default gateway rule for response metadata, returned with prefix.
+func metadataHeader(key string) (string, bool) {
+	return runtime.MetadataHeaderPrefix + key, true
+}
@@ -0,0 +1,254 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"usermanager/app/ui/auth"
+	i "usermanager/app/ui/interceptors"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeUserServer struct {
+	proto.UnimplementedUserServiceServer
+	md metadata.MD
+}
+
+func (s *fakeUserServer) CreateUser(ctx context.Context, req *proto.CreateUserRequest) (*proto.CreateUserResponse, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", "req-1"))
+	return &proto.CreateUserResponse{Id: req.Nickname + "-id"}, nil
+}
+
+func (s *fakeUserServer) DeleteUser(ctx context.Context, req *proto.DeleteUserRequest) (*proto.DeleteUserResponse, error) {
+	return nil, status.Error(codes.NotFound, "no user in database")
+}
+
+// start grpc server with the fake user service and the gateway in front of it
+func createGateway(t *testing.T) (*httptest.Server, *fakeUserServer) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	g := grpc.NewServer()
+	fake := &fakeUserServer{}
+	proto.RegisterUserServiceServer(g, fake)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	handler, err := NewGatewayHandler(context.Background(), Config{GrpcEndpoint: lis.Addr().String()})
+	if err != nil {
+		t.Fatalf("cannot create gateway: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, fake
+}
+
+// certificate signed by parent, or self signed CA if parent is nil
+func createCert(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// start grpc server that requires client certificates and authenticates
+// callers, with the gateway presenting its own certificate
+func createMTLSGateway(t *testing.T) *httptest.Server {
+	ca := createCert(t, "ca", nil)
+	serverCert := createCert(t, "localhost", &ca)
+	gatewayCert := createCert(t, "gateway", &ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	authenticator, err := auth.NewAuthenticator(auth.Config{ClientCerts: true, ProxySubjects: []string{"gateway"}})
+	assert.Nil(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	g := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})),
+		grpc.UnaryInterceptor(i.AuthUnaryInterceptor(authenticator)),
+	)
+	proto.RegisterUserServiceServer(g, &fakeUserServer{})
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	handler, err := NewGatewayHandler(context.Background(), Config{
+		GrpcEndpoint: lis.Addr().String(),
+		TLS: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{gatewayCert},
+			ServerName:   "localhost",
+		},
+	})
+	if err != nil {
+		t.Fatalf("cannot create gateway: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGateway_CreateUser_ShouldForwardRequest(t *testing.T) {
+	server, fake := createGateway(t)
+
+	// arrange
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/users",
+		strings.NewReader(`{"nickname":"john","email":"john@example.com"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("Idempotency-Key", "key-1")
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"john-id"}`, string(body))
+	assert.Equal(t, "req-1", resp.Header.Get("X-Request-Id"))
+	assert.Equal(t, []string{"Bearer token"}, fake.md.Get("authorization"))
+	assert.Equal(t, []string{"key"}, fake.md.Get("x-api-key"))
+	assert.Equal(t, []string{"key-1"}, fake.md.Get("idempotency-key"))
+}
+
+func TestGateway_ClientForwardedFor_ShouldBeReplacedWithRemoteAddress(t *testing.T) {
+	server, fake := createGateway(t)
+
+	// arrange
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/users", strings.NewReader(`{"nickname":"john"}`))
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"127.0.0.1"}, fake.md.Get("x-forwarded-for"))
+}
+
+func TestGateway_IdentityHeaders_ShouldNotBeForwarded(t *testing.T) {
+	server, fake := createGateway(t)
+
+	// arrange
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/users", strings.NewReader(`{"nickname":"john"}`))
+	req.Header.Set("X-Role", "admin")
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set("Grpc-Metadata-X-Role", "admin")
+	req.Header.Set("Grpc-Metadata-X-User-Id", "1")
+	req.Header.Set("Grpc-Metadata-X-Custom", "value")
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, fake.md.Get("x-role"))
+	assert.Empty(t, fake.md.Get("x-user-id"))
+	assert.Equal(t, []string{"value"}, fake.md.Get("x-custom"))
+}
+
+func TestGateway_MTLSWithoutCredentials_ShouldReturnUnauthenticated(t *testing.T) {
+	server := createMTLSGateway(t)
+
+	// act, the gateway certificate is not the caller's credential
+	resp, err := http.Post(server.URL+"/v1/users", "application/json", strings.NewReader(`{"nickname":"john"}`))
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, float64(codes.Unauthenticated), body["code"])
+}
+
+func TestGateway_GrpcErr_ShouldMapStatusCode(t *testing.T) {
+	server, _ := createGateway(t)
+
+	// arrange
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/v1/users/1", nil)
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, float64(codes.NotFound), body["code"])
+	assert.Equal(t, "no user in database", body["message"])
+}
+
+func TestGateway_OpenAPI_ShouldServeDocument(t *testing.T) {
+	server, _ := createGateway(t)
+
+	// act
+	resp, err := http.Get(server.URL + OpenAPIPath)
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	var doc map[string]interface{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "2.0", doc["swagger"])
+	assert.Contains(t, doc["paths"], "/v1/users/{id}")
+}
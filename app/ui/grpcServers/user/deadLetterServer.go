@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+	v "usermanager/app/ui/validations"
+
+	"github.com/rs/zerolog/log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *userServer) ListDeadLetters(ctx context.Context, req *proto.ListDeadLettersRequest) (*proto.ListDeadLettersResponse, error) {
+	// validate request
+	if err := v.ValidateListDeadLettersReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for list dead letters request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// get dead letters
+	letters, total, err := s.deadLetterService.List(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("list dead letters failed")
+		return nil, err
+	}
+
+	response := proto.ListDeadLettersResponse{
+		DeadLetters: make([]*proto.DeadLetter, 0, len(letters)),
+		Total:       total,
+	}
+	for _, l := range letters {
+		response.DeadLetters = append(response.DeadLetters, protoDeadLetter(l))
+	}
+	return &response, nil
+}
+
+func (s *userServer) ReplayDeadLetter(ctx context.Context, req *proto.ReplayDeadLetterRequest) (*proto.ReplayDeadLetterResponse, error) {
+	// validate request
+	if err := v.ValidateReplayDeadLetterReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for replay dead letter request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// replay dead letter
+	if err := s.deadLetterService.Replay(ctx, req.Id); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("replay dead letter with id %v failed", req.Id)
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("dead letter with id %v successfully replayed", req.Id)
+	return &proto.ReplayDeadLetterResponse{Id: req.Id}, nil
+}
+
+func (s *userServer) ReplayDeadLetters(ctx context.Context, req *proto.ReplayDeadLettersRequest) (*proto.ReplayDeadLettersResponse, error) {
+	// validate request
+	if err := v.ValidateReplayDeadLettersReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for replay dead letters request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// replay dead letters matching the filter
+	replayed, failed, err := s.deadLetterService.ReplayAll(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("replay dead letters failed")
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("%v dead letters replayed, %v failed", replayed, failed)
+	return &proto.ReplayDeadLettersResponse{
+		Replayed: int32(replayed),
+		Failed:   int32(failed),
+	}, nil
+}
+
+func protoDeadLetter(l domain.DeadLetter) *proto.DeadLetter {
+	return &proto.DeadLetter{
+		Id:          l.Id.String(),
+		Transport:   l.Transport,
+		UserId:      l.UserId.String(),
+		Error:       l.Error,
+		Attempts:    int32(l.Attempts),
+		Created:     timestamppb.New(l.CreatedAt),
+		LastAttempt: timestamppb.New(l.UpdatedAt),
+	}
+}
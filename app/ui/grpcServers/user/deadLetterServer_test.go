@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"usermanager/app/domain"
+	"usermanager/app/services/mocks"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createDeadLetterServer() (*userServer, *mocks.DeadLetterServiceMock) {
+	mockDeadLetterService := &mocks.DeadLetterServiceMock{}
+	grpcServer := NewUserGrpcServer(grpc.NewServer(), &mocks.UserServiceMock{}, &mocks.WebhookServiceMock{},
+		mockDeadLetterService, false)
+	return grpcServer, mockDeadLetterService
+}
+
+func TestListDeadLetters_ShouldReturnLettersAndTotal(t *testing.T) {
+	grpcServer, mockedDeadLetterService := createDeadLetterServer()
+
+	// arrange
+	ctx := context.Background()
+	req := &proto.ListDeadLettersRequest{Limit: 1}
+	letter := domain.DeadLetter{Id: uuid.New(), Transport: "rabbitmq", UserId: uuid.New(), Attempts: 2}
+	mockedDeadLetterService.On("List", ctx, req).Return([]domain.DeadLetter{letter}, int64(5), nil)
+
+	// act
+	result, err := grpcServer.ListDeadLetters(ctx, req)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), result.Total)
+	assert.Len(t, result.DeadLetters, 1)
+	assert.Equal(t, letter.Id.String(), result.DeadLetters[0].Id)
+	assert.Equal(t, int32(2), result.DeadLetters[0].Attempts)
+}
+
+func TestReplayDeadLetter_ReplayFailed_ShouldReturnErr(t *testing.T) {
+	grpcServer, mockedDeadLetterService := createDeadLetterServer()
+
+	// arrange
+	ctx := context.Background()
+	req := &proto.ReplayDeadLetterRequest{Id: uuid.NewString()}
+	mockedDeadLetterService.On("Replay", ctx, req.Id).Return(status.Error(codes.Unavailable, "replay failed"))
+
+	// act
+	result, err := grpcServer.ReplayDeadLetter(ctx, req)
+
+	// assert
+	assert.Nil(t, result)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
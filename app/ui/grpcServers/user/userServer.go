@@ -5,6 +5,7 @@ import (
 
 	"usermanager/app/domain"
 	"usermanager/app/services"
+	"usermanager/app/ui/auth"
 	proto "usermanager/app/ui/protos/user"
 	v "usermanager/app/ui/validations"
 
@@ -18,12 +19,20 @@ import (
 
 type userServer struct {
 	proto.UserServiceServer
-	userService services.UserService
+	userService       services.UserService
+	webhookService    services.WebhookService
+	deadLetterService services.DeadLetterService
+	// accept http webhook urls and internal hosts, development only
+	allowInsecureWebhooks bool
 }
 
-func NewUserGrpcServer(g *grpc.Server, u services.UserService) *userServer {
+func NewUserGrpcServer(g *grpc.Server, u services.UserService, w services.WebhookService,
+	d services.DeadLetterService, allowInsecureWebhooks bool) *userServer {
 	userGrpcServer := userServer{
-		userService: u,
+		userService:           u,
+		webhookService:        w,
+		deadLetterService:     d,
+		allowInsecureWebhooks: allowInsecureWebhooks,
 	}
 	proto.RegisterUserServiceServer(g, &userGrpcServer)
 	return &userGrpcServer
@@ -32,88 +41,183 @@ func NewUserGrpcServer(g *grpc.Server, u services.UserService) *userServer {
 func (s *userServer) CreateUser(ctx context.Context, req *proto.CreateUserRequest) (*proto.CreateUserResponse, error) {
 	// validate request
 	if err := v.ValidateCreateUserReq(req); err != nil {
-		log.Error().Err(err).Msg("validation failed for create user request")
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for create user request")
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	// add user
-	id, err := s.userService.Add(req)
+	id, err := s.userService.Add(ctx, req)
 	if err != nil {
-		log.Error().Err(err).Msg("create user failed")
+		log.Ctx(ctx).Error().Err(err).Msg("create user failed")
 		return nil, err
 	}
 
-	log.Info().Msgf("user %v sucessfully added", req.Nickname)
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("user %v sucessfully added", id)
 	return &proto.CreateUserResponse{Id: id.String()}, nil
 }
 
 func (s *userServer) UpdateUser(ctx context.Context, req *proto.UpdateUserRequest) (*proto.UpdateUserResponse, error) {
 	// validate request
 	if err := v.ValidateUpdateUserReq(req); err != nil {
-		log.Error().Err(err).Msg("validation failed for update user request")
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for update user request")
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	// update user
-	if err := s.userService.Update(req); err != nil {
-		log.Error().Err(err).Msgf("update user with id %v failed", req.Id)
+	if err := s.userService.Update(ctx, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("update user with id %v failed", req.Id)
 		return nil, err
 	}
 
-	log.Info().Msgf("user with id %v successfully updated", req.Id)
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("user with id %v successfully updated", req.Id)
 	return &proto.UpdateUserResponse{Id: req.Id}, nil
 }
 
 func (s *userServer) DeleteUser(ctx context.Context, req *proto.DeleteUserRequest) (*proto.DeleteUserResponse, error) {
 	// validate request
 	if err := v.ValidateDeleteUserReq(req); err != nil {
-		log.Error().Err(err).Msg("validation failed for delete user request")
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for delete user request")
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	// delete user
-	if err := s.userService.Delete(req.Id); err != nil {
-		log.Error().Err(err).Msgf("delete user with id %v failed", req.Id)
+	if err := s.userService.Delete(ctx, req.Id); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("delete user with id %v failed", req.Id)
 		return nil, err
 	}
 
-	log.Info().Msgf("user with id %v successfully deleted", req.Id)
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("user with id %v successfully deleted", req.Id)
 	return &proto.DeleteUserResponse{Id: req.Id}, nil
 }
 
+func (s *userServer) GetUser(ctx context.Context, req *proto.GetUserRequest) (*proto.UserPageResponse_User, error) {
+	// validate request
+	if err := v.ValidateGetUserReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for get user request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// get user
+	user, err := s.userService.Get(ctx, req.Id)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("get user with id %v failed", req.Id)
+		return nil, err
+	}
+
+	return protoUser(user), nil
+}
+
 func (s *userServer) GetUserPage(ctx context.Context, req *proto.UserPageRequest) (*proto.UserPageResponse, error) {
 	// validate request
 	if err := v.ValidateUserPageReq(req); err != nil {
-		log.Error().Err(err).Msg("validation failed for user page request")
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for user page request")
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	// get user page
-	users, err := s.userService.GetPage(req)
+	users, err := s.userService.GetPage(ctx, req)
 	if err != nil {
-		log.Error().Err(err).Msg("get user page failed")
+		log.Ctx(ctx).Error().Err(err).Msg("get user page failed")
 		return nil, err
 	}
 
 	return userPageResponse(users), nil
 }
 
+func (s *userServer) WatchUsers(req *proto.WatchUsersRequest, stream proto.UserService_WatchUsersServer) error {
+	ctx := stream.Context()
+
+	// validate request
+	if err := v.ValidateWatchUsersReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for watch users request")
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// subscribe to user changes
+	sub, err := s.userService.Watch(req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("watch users failed")
+		return err
+	}
+	defer sub.Close()
+
+	log.Ctx(ctx).Info().Msg("user changes subscriber connected")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().Msg("user changes subscriber disconnected")
+			return ctx.Err()
+		case event, ok := <-sub.Events():
+			if !ok {
+				log.Ctx(ctx).Error().Err(sub.Err()).Msg("user changes subscription closed")
+				return sub.Err()
+			}
+			if err := stream.Send(userEvent(event)); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("cannot send user event")
+				return err
+			}
+		}
+	}
+}
+
+// Subject of the authenticated caller, used for the audit logs.
+func caller(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.Subject
+	}
+	return "anonymous"
+}
+
 func userPageResponse(users []domain.User) *proto.UserPageResponse {
 	response := proto.UserPageResponse{
 		Users: make([]*proto.UserPageResponse_User, 0, len(users)),
 	}
 
 	for _, u := range users {
-		response.Users = append(response.Users, &proto.UserPageResponse_User{
-			Id:        u.Id.String(),
-			Firstname: u.Firstname,
-			Lastname:  u.Lastname,
-			Nickname:  u.Nickname,
-			Email:     u.Email,
-			Country:   u.Country,
-			Created:   timestamppb.New(u.CreatedAt),
-		})
+		response.Users = append(response.Users, protoUser(u))
 	}
 
 	return &response
 }
+
+func userEvent(e domain.UserEvent) *proto.UserEvent {
+	event := &proto.UserEvent{
+		Sequence: e.Sequence,
+		Type:     eventType(e.Type),
+		Id:       e.UserId.String(),
+		Time:     timestamppb.New(e.Time),
+		Epoch:    e.Epoch,
+	}
+	if e.User != nil {
+		event.User = protoUser(*e.User)
+	}
+	return event
+}
+
+func eventType(t domain.UserEventType) proto.UserEvent_Type {
+	switch t {
+	case domain.UserCreated:
+		return proto.UserEvent_CREATED
+	case domain.UserUpdated:
+		return proto.UserEvent_UPDATED
+	case domain.UserDeleted:
+		return proto.UserEvent_DELETED
+	}
+	return proto.UserEvent_UNKNOWN
+}
+
+func protoUser(u domain.User) *proto.UserPageResponse_User {
+	user := &proto.UserPageResponse_User{
+		Id:        u.Id.String(),
+		Firstname: u.Firstname,
+		Lastname:  u.Lastname,
+		Nickname:  u.Nickname,
+		Email:     u.Email,
+		Country:   u.Country,
+	}
+	if !u.CreatedAt.IsZero() {
+		user.Created = timestamppb.New(u.CreatedAt)
+	}
+	return user
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 	"usermanager/app/domain"
+	"usermanager/app/infrastructure/events"
 	"usermanager/app/services/mocks"
 	proto "usermanager/app/ui/protos/user"
 
@@ -13,6 +14,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	_ "github.com/uptrace/bun/driver/pgdriver"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var createUserReq = &proto.CreateUserRequest{
@@ -43,9 +46,30 @@ var getPageReq = &proto.UserPageRequest{
 	Limit:  1,
 }
 
+var watchUsersReq = &proto.WatchUsersRequest{
+	AfterSequence: 1,
+}
+
+// fake server stream that records sent events
+type watchUsersStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events []*proto.UserEvent
+}
+
+func (s *watchUsersStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchUsersStream) Send(e *proto.UserEvent) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
 func createServer() (*userServer, *mocks.UserServiceMock) {
 	mockUserService := &mocks.UserServiceMock{}
-	grpcServer := NewUserGrpcServer(grpc.NewServer(), mockUserService)
+	grpcServer := NewUserGrpcServer(grpc.NewServer(), mockUserService, &mocks.WebhookServiceMock{},
+		&mocks.DeadLetterServiceMock{}, false)
 	return grpcServer, mockUserService
 }
 
@@ -56,7 +80,7 @@ func TestCreateUser_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T) {
 	ctx := context.Background()
 
 	mockedUserService.
-		On("Add", createUserReq).
+		On("Add", ctx, createUserReq).
 		Return(uuid.Nil, expectedErr).
 		Once()
 
@@ -73,7 +97,7 @@ func TestCreateUser_UserServiceReturnsValidRes_ResponseShouldValid(t *testing.T)
 
 	expectedId := uuid.New()
 	mockedUserService.
-		On("Add", createUserReq).
+		On("Add", ctx, createUserReq).
 		Return(expectedId, nil).
 		Once()
 
@@ -91,7 +115,7 @@ func TestUpdateUser_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T) {
 	ctx := context.Background()
 
 	mockedUserService.
-		On("Update", updateUserReq).
+		On("Update", ctx, updateUserReq).
 		Return(expectedErr).
 		Once()
 
@@ -107,7 +131,7 @@ func TestUpdate_UserServiceReturnsValidRes_ResponseShouldValid(t *testing.T) {
 	ctx := context.Background()
 
 	mockedUserService.
-		On("Update", updateUserReq).
+		On("Update", ctx, updateUserReq).
 		Return(nil).
 		Once()
 
@@ -125,7 +149,7 @@ func TestDeleteUser_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T) {
 	ctx := context.Background()
 
 	mockedUserService.
-		On("Delete", deleteUserReq.Id).
+		On("Delete", ctx, deleteUserReq.Id).
 		Return(expectedErr).
 		Once()
 
@@ -141,7 +165,7 @@ func TestDelete_UserServiceReturnsValidRes_ResponseShouldValid(t *testing.T) {
 	ctx := context.Background()
 
 	mockedUserService.
-		On("Delete", deleteUserReq.Id).
+		On("Delete", ctx, deleteUserReq.Id).
 		Return(nil).
 		Once()
 
@@ -152,6 +176,33 @@ func TestDelete_UserServiceReturnsValidRes_ResponseShouldValid(t *testing.T) {
 	assert.Equal(t, result.Id, deleteUserReq.Id)
 }
 
+func TestGetUser_UserServiceReturnsUser_ResponseShouldBeUser(t *testing.T) {
+	grpcServer, mockedUserService := createServer()
+	ctx := context.Background()
+	user := domain.User{Id: uuid.New(), Nickname: "ben", Password: "hash"}
+
+	mockedUserService.
+		On("Get", ctx, user.Id.String()).
+		Return(user, nil).
+		Once()
+
+	result, err := grpcServer.GetUser(ctx, &proto.GetUserRequest{Id: user.Id.String()})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id.String(), result.Id)
+	assert.Equal(t, user.Nickname, result.Nickname)
+	assert.Empty(t, result.Password)
+}
+
+func TestGetUser_InvalidId_ResponseShouldBeInvalidArgument(t *testing.T) {
+	grpcServer, _ := createServer()
+
+	result, err := grpcServer.GetUser(context.Background(), &proto.GetUserRequest{Id: "1"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestGetUserPageUser_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T) {
 	grpcServer, mockedUserService := createServer()
 
@@ -159,7 +210,7 @@ func TestGetUserPageUser_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T)
 	ctx := context.Background()
 
 	mockedUserService.
-		On("GetPage", getPageReq).
+		On("GetPage", ctx, getPageReq).
 		Return([]domain.User{}, expectedErr).
 		Once()
 
@@ -195,7 +246,7 @@ func TestGetUserPageUser_UserServiceReturnsValidRes_ResponseShouldValid(t *testi
 	}
 
 	mockedUserService.
-		On("GetPage", getPageReq).
+		On("GetPage", ctx, getPageReq).
 		Return(expectedUserList, nil).
 		Once()
 
@@ -215,3 +266,69 @@ func TestGetUserPageUser_UserServiceReturnsValidRes_ResponseShouldValid(t *testi
 		assert.Equal(t, result.Users[0].Created.AsTime(), expectedUserList[0].CreatedAt.UTC())
 	}
 }
+
+func TestWatchUsers_UserServiceReturnErr_ResponseShouldBeErr(t *testing.T) {
+	grpcServer, mockedUserService := createServer()
+
+	expectedErr := status.Error(codes.OutOfRange, "error ocurred")
+	stream := &watchUsersStream{ctx: context.Background()}
+
+	mockedUserService.
+		On("Watch", watchUsersReq).
+		Return(nil, expectedErr).
+		Once()
+
+	err := grpcServer.WatchUsers(watchUsersReq, stream)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), expectedErr.Error())
+	assert.Empty(t, stream.events)
+}
+
+func TestWatchUsers_SubscriberTooSlow_ShouldSendBufferedEventsAndReturnErr(t *testing.T) {
+	grpcServer, mockedUserService := createServer()
+
+	// hub with buffer of one event, so the second event disconnects subscriber
+	hub := events.NewUserEventHub(10, 1)
+	hub.Publish(domain.UserCreated, uuid.New(), &domain.User{Nickname: "test"})
+	sub, _ := hub.Subscribe(events.ResumePoint{AfterSequence: 1}, nil)
+	updatedId := uuid.New()
+	hub.Publish(domain.UserUpdated, updatedId, &domain.User{Id: updatedId})
+	hub.Publish(domain.UserDeleted, uuid.New(), nil)
+
+	stream := &watchUsersStream{ctx: context.Background()}
+
+	mockedUserService.
+		On("Watch", watchUsersReq).
+		Return(sub, nil).
+		Once()
+
+	err := grpcServer.WatchUsers(watchUsersReq, stream)
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Len(t, stream.events, 1)
+	assert.Equal(t, uint64(2), stream.events[0].Sequence)
+	assert.Equal(t, proto.UserEvent_UPDATED, stream.events[0].Type)
+	assert.Equal(t, updatedId.String(), stream.events[0].Id)
+	assert.Equal(t, updatedId.String(), stream.events[0].User.Id)
+}
+
+func TestWatchUsers_ClientCanceled_ShouldReturn(t *testing.T) {
+	grpcServer, mockedUserService := createServer()
+
+	hub := events.NewUserEventHub(10, 10)
+	sub, _ := hub.Subscribe(events.ResumePoint{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &watchUsersStream{ctx: ctx}
+
+	mockedUserService.
+		On("Watch", watchUsersReq).
+		Return(sub, nil).
+		Once()
+
+	err := grpcServer.WatchUsers(watchUsersReq, stream)
+
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+	v "usermanager/app/ui/validations"
+
+	"github.com/rs/zerolog/log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *userServer) CreateWebhook(ctx context.Context, req *proto.CreateWebhookRequest) (*proto.Webhook, error) {
+	// validate request
+	if err := v.ValidateCreateWebhookReq(req, s.allowInsecureWebhooks); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for create webhook request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// create webhook
+	webhook, err := s.webhookService.Create(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("create webhook failed")
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("webhook %v sucessfully created", webhook.Id)
+
+	// secret is returned only once
+	response := protoWebhook(webhook)
+	response.Secret = webhook.Secret
+	return response, nil
+}
+
+func (s *userServer) ListWebhooks(ctx context.Context, req *proto.ListWebhooksRequest) (*proto.ListWebhooksResponse, error) {
+	webhooks, err := s.webhookService.List(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("list webhooks failed")
+		return nil, err
+	}
+
+	response := proto.ListWebhooksResponse{
+		Webhooks: make([]*proto.Webhook, 0, len(webhooks)),
+	}
+	for _, w := range webhooks {
+		response.Webhooks = append(response.Webhooks, protoWebhook(w))
+	}
+	return &response, nil
+}
+
+func (s *userServer) UpdateWebhook(ctx context.Context, req *proto.UpdateWebhookRequest) (*proto.Webhook, error) {
+	// validate request
+	if err := v.ValidateUpdateWebhookReq(req, s.allowInsecureWebhooks); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for update webhook request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// update webhook
+	webhook, err := s.webhookService.Update(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("update webhook with id %v failed", req.Id)
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("webhook with id %v successfully updated", req.Id)
+	return protoWebhook(webhook), nil
+}
+
+func (s *userServer) DeleteWebhook(ctx context.Context, req *proto.DeleteWebhookRequest) (*proto.DeleteWebhookResponse, error) {
+	// validate request
+	if err := v.ValidateDeleteWebhookReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for delete webhook request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// delete webhook
+	if err := s.webhookService.Delete(ctx, req.Id); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("delete webhook with id %v failed", req.Id)
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("principal", caller(ctx)).Msgf("webhook with id %v successfully deleted", req.Id)
+	return &proto.DeleteWebhookResponse{Id: req.Id}, nil
+}
+
+func (s *userServer) ListWebhookDeliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) (*proto.ListWebhookDeliveriesResponse, error) {
+	// validate request
+	if err := v.ValidateListWebhookDeliveriesReq(req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("validation failed for list webhook deliveries request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// get deliveries
+	deliveries, err := s.webhookService.Deliveries(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("list deliveries of webhook %v failed", req.WebhookId)
+		return nil, err
+	}
+
+	response := proto.ListWebhookDeliveriesResponse{
+		Deliveries: make([]*proto.WebhookDelivery, 0, len(deliveries)),
+	}
+	for _, d := range deliveries {
+		response.Deliveries = append(response.Deliveries, protoDelivery(d))
+	}
+	return &response, nil
+}
+
+// Webhook without the secret.
+func protoWebhook(w domain.Webhook) *proto.Webhook {
+	webhook := &proto.Webhook{
+		Id:                  w.Id.String(),
+		Url:                 w.Url,
+		EventTypes:          make([]proto.UserEvent_Type, 0, len(w.EventTypes)),
+		Enabled:             w.Enabled,
+		ConsecutiveFailures: int32(w.ConsecutiveFailures),
+	}
+	for _, t := range w.EventTypes {
+		webhook.EventTypes = append(webhook.EventTypes, eventType(t))
+	}
+	if !w.CreatedAt.IsZero() {
+		webhook.Created = timestamppb.New(w.CreatedAt)
+	}
+	if !w.UpdatedAt.IsZero() {
+		webhook.Updated = timestamppb.New(w.UpdatedAt)
+	}
+	return webhook
+}
+
+func protoDelivery(d domain.WebhookDelivery) *proto.WebhookDelivery {
+	return &proto.WebhookDelivery{
+		Id:            d.Id.String(),
+		WebhookId:     d.WebhookId.String(),
+		EventSequence: d.EventSequence,
+		EventType:     eventType(d.EventType),
+		UserId:        d.UserId.String(),
+		Attempt:       int32(d.Attempt),
+		StatusCode:    int32(d.StatusCode),
+		Error:         d.Error,
+		Success:       d.Success,
+		DurationMs:    d.Duration.Milliseconds(),
+		Time:          timestamppb.New(d.CreatedAt),
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"usermanager/app/domain"
+	"usermanager/app/services/mocks"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func createWebhookServer() (*userServer, *mocks.WebhookServiceMock) {
+	mockWebhookService := &mocks.WebhookServiceMock{}
+	grpcServer := NewUserGrpcServer(grpc.NewServer(), &mocks.UserServiceMock{}, mockWebhookService,
+		&mocks.DeadLetterServiceMock{}, false)
+	return grpcServer, mockWebhookService
+}
+
+func TestCreateWebhook_Created_ShouldReturnSecret(t *testing.T) {
+	grpcServer, mockedWebhookService := createWebhookServer()
+
+	// arrange
+	ctx := context.Background()
+	req := &proto.CreateWebhookRequest{Url: "https://partner.example.com/hooks"}
+	webhook := domain.Webhook{Id: uuid.New(), Url: req.Url, Secret: "whsec_test", Enabled: true}
+	mockedWebhookService.On("Create", ctx, req).Return(webhook, nil)
+
+	// act
+	result, err := grpcServer.CreateWebhook(ctx, req)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, webhook.Id.String(), result.Id)
+	assert.Equal(t, "whsec_test", result.Secret)
+}
+
+func TestListWebhooks_ShouldNotReturnSecret(t *testing.T) {
+	grpcServer, mockedWebhookService := createWebhookServer()
+
+	// arrange
+	ctx := context.Background()
+	webhook := domain.Webhook{
+		Id:         uuid.New(),
+		Url:        "https://partner.example.com/hooks",
+		EventTypes: []domain.UserEventType{domain.UserDeleted},
+		Secret:     "whsec_test",
+	}
+	mockedWebhookService.On("List", ctx).Return([]domain.Webhook{webhook}, nil)
+
+	// act
+	result, err := grpcServer.ListWebhooks(ctx, &proto.ListWebhooksRequest{})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, result.Webhooks, 1)
+	assert.Empty(t, result.Webhooks[0].Secret)
+	assert.Equal(t, []proto.UserEvent_Type{proto.UserEvent_DELETED}, result.Webhooks[0].EventTypes)
+}
+
+func TestUpdateWebhook_InvalidId_ShouldReturnInvalidArgument(t *testing.T) {
+	grpcServer, mockedWebhookService := createWebhookServer()
+
+	result, err := grpcServer.UpdateWebhook(context.Background(),
+		&proto.UpdateWebhookRequest{Id: "invalid", Url: "https://partner.example.com/hooks"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockedWebhookService.AssertNotCalled(t, "Update")
+}
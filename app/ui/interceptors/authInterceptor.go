@@ -0,0 +1,113 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"usermanager/app/ui/auth"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// Methods that can be called without credentials.
+var publicMethodPrefixes = []string{
+	"/grpc.health.v1.Health/",
+}
+
+// Unary interceptor that authenticates the caller and puts the verified
+// principal into the request context. Health service stays public.
+func AuthUnaryInterceptor(a auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if isPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream interceptor that authenticates the caller and puts the verified
+// principal into the stream context. Health service stays public.
+func AuthStreamInterceptor(a auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if isPublicMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// Unary interceptor that puts the principal from the verified TLS client
+// certificate into the request context. Used with mTLS when
+// authentication is disabled, so callers without certificate pass.
+// Certificates of the proxies are not a principal.
+func ClientCertUnaryInterceptor(proxySubjects []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if auth.IsProxy(ctx, proxySubjects) {
+			return handler(ctx, req)
+		}
+		if p, ok := auth.ClientCertPrincipal(ctx); ok {
+			ctx = auth.NewContext(ctx, p)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream interceptor that puts the principal from the verified TLS client
+// certificate into the stream context.
+func ClientCertStreamInterceptor(proxySubjects []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if auth.IsProxy(ss.Context(), proxySubjects) {
+			return handler(srv, ss)
+		}
+		if p, ok := auth.ClientCertPrincipal(ss.Context()); ok {
+			ss = &serverStream{ServerStream: ss, ctx: auth.NewContext(ss.Context(), p)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authenticate(ctx context.Context, a auth.Authenticator, method string) (context.Context, error) {
+	principal, err := a.Authenticate(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("authentication failed for %v", method)
+		return nil, err
+	}
+	return auth.NewContext(ctx, principal), nil
+}
+
+func isPublicMethod(method string) bool {
+	for _, prefix := range publicMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Server stream with a replaced context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,92 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"usermanager/app/ui/auth"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func createAuthenticator(t *testing.T) auth.Authenticator {
+	a, err := auth.NewAuthenticator(auth.Config{ApiKeys: map[string]auth.APIKey{"secret-key": {Name: "ops-script", Role: auth.RoleSupport}}})
+	if err != nil {
+		t.Fatalf("cannot create authenticator: %v", err)
+	}
+	return a
+}
+
+// handler that returns the principal from the context
+func principalHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	p, _ := auth.FromContext(ctx)
+	return p, nil
+}
+
+func TestAuthUnary_ValidCredentials_ShouldPutPrincipalInContext(t *testing.T) {
+	interceptor := AuthUnaryInterceptor(createAuthenticator(t))
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(auth.APIKeyHeader, "secret-key"))
+
+	res, err := interceptor(ctx, nil, createUserInfo, principalHandler)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ops-script", res.(*auth.Principal).Subject)
+}
+
+func TestAuthUnary_NoCredentials_ShouldReturnErr(t *testing.T) {
+	interceptor := AuthUnaryInterceptor(createAuthenticator(t))
+
+	res, err := interceptor(context.Background(), nil, createUserInfo, principalHandler)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthUnary_HealthCheck_ShouldNotRequireCredentials(t *testing.T) {
+	interceptor := AuthUnaryInterceptor(createAuthenticator(t))
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	_, err := interceptor(context.Background(), nil, info, principalHandler)
+
+	assert.Nil(t, err)
+}
+
+// fake server stream with a custom context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthStream_ValidCredentials_ShouldPutPrincipalInContext(t *testing.T) {
+	interceptor := AuthStreamInterceptor(createAuthenticator(t))
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(auth.APIKeyHeader, "secret-key"))
+	info := &grpc.StreamServerInfo{FullMethod: "/proto.UserService/WatchUsers"}
+
+	var principal *auth.Principal
+	err := interceptor(nil, &contextStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		principal, _ = auth.FromContext(ss.Context())
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ops-script", principal.Subject)
+}
+
+func TestAuthStream_NoCredentials_ShouldReturnErr(t *testing.T) {
+	interceptor := AuthStreamInterceptor(createAuthenticator(t))
+	info := &grpc.StreamServerInfo{FullMethod: "/proto.UserService/WatchUsers"}
+
+	err := interceptor(nil, &contextStream{ctx: context.Background()}, info,
+		func(srv interface{}, ss grpc.ServerStream) error { return nil })
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,92 @@
+package interceptor
+
+import (
+	"context"
+	"usermanager/app/ui/auth"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	RoleHeader   = "x-role"
+	UserIdHeader = "x-user-id"
+)
+
+// Unary interceptor that checks the caller's role against the policy.
+// The role is taken from the authenticated principal. If metadataRoles
+// is true (development only) and there is no principal, role and user
+// id are taken from 'x-role' and 'x-user-id' metadata.
+func AuthzUnaryInterceptor(a auth.Authorizer, metadataRoles bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if isPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		p := principal(ctx, metadataRoles)
+		scope, err := a.Authorize(p, info.FullMethod, req)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("authorization failed for %v", info.FullMethod)
+			return nil, err
+		}
+
+		resp, err := handler(withPrincipal(ctx, p), req)
+		if err != nil {
+			return resp, err
+		}
+		return a.FilterResponse(p, info.FullMethod, scope, resp), nil
+	}
+}
+
+// Stream interceptor that checks the caller's role against the policy.
+// Streams are allowed only with the full scope of the permission.
+func AuthzStreamInterceptor(a auth.Authorizer, metadataRoles bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if isPublicMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		p := principal(ss.Context(), metadataRoles)
+		if _, err := a.Authorize(p, info.FullMethod, nil); err != nil {
+			log.Ctx(ss.Context()).Error().Err(err).Msgf("authorization failed for %v", info.FullMethod)
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withPrincipal(ss.Context(), p)})
+	}
+}
+
+// Authenticated principal or principal from the metadata.
+func principal(ctx context.Context, metadataRoles bool) *auth.Principal {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p
+	}
+	if !metadataRoles {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return &auth.Principal{
+		Subject: first(md.Get(UserIdHeader)),
+		Role:    first(md.Get(RoleHeader)),
+	}
+}
+
+func withPrincipal(ctx context.Context, p *auth.Principal) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return auth.NewContext(ctx, p)
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
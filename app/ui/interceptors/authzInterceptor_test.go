@@ -0,0 +1,68 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"usermanager/app/ui/auth"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var deleteUserInfo = &grpc.UnaryServerInfo{FullMethod: "/proto.UserService/DeleteUser"}
+
+func TestAuthzUnary_AuthenticatedAdmin_ShouldCallHandler(t *testing.T) {
+	interceptor := AuthzUnaryInterceptor(auth.NewAuthorizer(), false)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "admin", Role: auth.RoleAdmin})
+
+	res, err := interceptor(ctx, nil, deleteUserInfo, principalHandler)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", res.(*auth.Principal).Subject)
+}
+
+func TestAuthzUnary_RoleFromMetadataNotTrusted_ShouldReturnErr(t *testing.T) {
+	interceptor := AuthzUnaryInterceptor(auth.NewAuthorizer(), false)
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(RoleHeader, auth.RoleAdmin))
+
+	res, err := interceptor(ctx, nil, deleteUserInfo, principalHandler)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthzUnary_RoleFromMetadataTrusted_ShouldCallHandler(t *testing.T) {
+	interceptor := AuthzUnaryInterceptor(auth.NewAuthorizer(), true)
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(RoleHeader, auth.RoleAdmin, UserIdHeader, "admin"))
+
+	res, err := interceptor(ctx, nil, deleteUserInfo, principalHandler)
+
+	assert.Nil(t, err)
+	assert.Equal(t, auth.RoleAdmin, res.(*auth.Principal).Role)
+}
+
+func TestAuthzUnary_SupportDeletesUser_ShouldReturnErr(t *testing.T) {
+	interceptor := AuthzUnaryInterceptor(auth.NewAuthorizer(), false)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "support", Role: auth.RoleSupport})
+
+	res, err := interceptor(ctx, nil, deleteUserInfo, principalHandler)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthzStream_UserWatches_ShouldReturnErr(t *testing.T) {
+	interceptor := AuthzStreamInterceptor(auth.NewAuthorizer(), false)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "user", Role: auth.RoleUser})
+	info := &grpc.StreamServerInfo{FullMethod: "/proto.UserService/WatchUsers"}
+
+	err := interceptor(nil, &contextStream{ctx: ctx}, info,
+		func(srv interface{}, ss grpc.ServerStream) error { return nil })
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,42 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"usermanager/app/ui/auth"
+
+	"google.golang.org/grpc/peer"
+)
+
+// Identity of the caller that rate limits and idempotency keys are
+// scoped to. Principal subject if the caller is authenticated,
+// otherwise client ip.
+func callerKey(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return "principal:" + p.Subject
+	}
+	if ip := clientIp(ctx); ip != "" {
+		return "ip:" + ip
+	}
+	return "ip:unknown"
+}
+
+// Client address forwarded by a trusted proxy, otherwise peer ip.
+func clientIp(ctx context.Context) string {
+	if ip, ok := ctx.Value(clientIpKey{}).(string); ok {
+		return ip
+	}
+	return peerIp(ctx)
+}
+
+func peerIp(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return host
+}
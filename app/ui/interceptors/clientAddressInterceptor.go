@@ -0,0 +1,80 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"strings"
+	"usermanager/app/ui/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata with the client address, set by the gateway.
+const ForwardedForHeader = "x-forwarded-for"
+
+// Proxies whose forwarded client address is trusted.
+type TrustedProxies struct {
+	// peer networks, like loopback for the gateway without mTLS
+	Networks []*net.IPNet
+	// client certificate subjects, like the gateway certificate
+	Subjects []string
+}
+
+type clientIpKey struct{}
+
+// Unary interceptor that takes the client address from the
+// 'x-forwarded-for' metadata if the peer is a trusted proxy. Rate limits
+// and idempotency keys of anonymous callers use it instead of the peer
+// address. Forwarded address from other peers is ignored.
+func ClientAddressUnaryInterceptor(t TrustedProxies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		return handler(t.clientAddress(ctx), req)
+	}
+}
+
+// Stream interceptor that takes the client address from the
+// 'x-forwarded-for' metadata if the peer is a trusted proxy.
+func ClientAddressStreamInterceptor(t TrustedProxies) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: t.clientAddress(ss.Context())})
+	}
+}
+
+func (t TrustedProxies) clientAddress(ctx context.Context) context.Context {
+	if !t.trusts(ctx) {
+		return ctx
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ForwardedForHeader)
+	if len(values) == 0 {
+		return ctx
+	}
+	// the last address is the one the proxy added
+	addrs := strings.Split(values[len(values)-1], ",")
+	forwarded := strings.TrimSpace(addrs[len(addrs)-1])
+	if net.ParseIP(forwarded) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clientIpKey{}, forwarded)
+}
+
+func (t TrustedProxies) trusts(ctx context.Context) bool {
+	if auth.IsProxy(ctx, t.Subjects) {
+		return true
+	}
+	ip := net.ParseIP(peerIp(ctx))
+	if ip == nil {
+		return false
+	}
+	for _, network := range t.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var _, loopback, _ = net.ParseCIDR("127.0.0.1/32")
+
+// client ip the handler sees
+func clientIpOf(t TrustedProxies, ctx context.Context) string {
+	var ip string
+	_, _ = ClientAddressUnaryInterceptor(t)(ctx, nil, createUserInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			ip = clientIp(ctx)
+			return nil, nil
+		})
+	return ip
+}
+
+func forwardedFrom(ctx context.Context, ip string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(ForwardedForHeader, ip))
+}
+
+func TestClientAddress_TrustedNetwork_ShouldUseForwardedAddress(t *testing.T) {
+	ctx := forwardedFrom(peerContext("127.0.0.1"), "198.51.100.1, 203.0.113.1")
+
+	ip := clientIpOf(TrustedProxies{Networks: []*net.IPNet{loopback}}, ctx)
+
+	assert.Equal(t, "203.0.113.1", ip)
+}
+
+func TestClientAddress_UntrustedLoopbackPeer_ShouldUsePeerAddress(t *testing.T) {
+	// like a sidecar proxy passing requests through from 127.0.0.6
+	ctx := forwardedFrom(peerContext("127.0.0.6"), "203.0.113.1")
+
+	ip := clientIpOf(TrustedProxies{Networks: []*net.IPNet{loopback}}, ctx)
+
+	assert.Equal(t, "127.0.0.6", ip)
+}
+
+func TestClientAddress_ProxyCertificate_ShouldUseForwardedAddress(t *testing.T) {
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "gateway"}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000},
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+
+	trusted := clientIpOf(TrustedProxies{Subjects: []string{"gateway"}}, forwardedFrom(ctx, "203.0.113.1"))
+	untrusted := clientIpOf(TrustedProxies{Subjects: []string{"other"}}, forwardedFrom(ctx, "203.0.113.1"))
+
+	assert.Equal(t, "203.0.113.1", trusted)
+	assert.Equal(t, "10.0.0.1", untrusted)
+}
+
+func TestClientAddress_NoInterceptor_ShouldIgnoreForwardedAddress(t *testing.T) {
+	ctx := forwardedFrom(peerContext("127.0.0.1"), "203.0.113.1")
+
+	assert.Equal(t, "127.0.0.1", clientIp(ctx))
+}
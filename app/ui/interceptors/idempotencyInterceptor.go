@@ -0,0 +1,129 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/sha256"
+	"usermanager/app/infrastructure/idempotency"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	IdempotencyKeyHeader     = "idempotency-key"
+	IdempotentReplayedHeader = "idempotent-replayed"
+	idempotencyKeyField      = "idempotency_key"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Request messages that can carry an idempotency key.
+type idempotentRequest interface {
+	proto.Message
+	GetIdempotencyKey() string
+}
+
+// Unary interceptor that makes requests with an idempotency key safe to
+// retry. The key is read from the request field or from the
+// 'idempotency-key' metadata. The first successful response is stored
+// and returned again for retries of the same caller with the same key
+// and the same payload.
+// Retry with a different payload is rejected.
+func IdempotencyUnaryInterceptor(store idempotency.IdempotencyStore) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		r, ok := req.(idempotentRequest)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		key, err := idempotencyKey(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		hash, err := requestHash(r)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		// keys are scoped per method and caller, so callers cannot
+		// replay each other's responses
+		storeKey := info.FullMethod + ":" + callerKey(ctx) + ":" + key
+		record, exists := store.Reserve(storeKey, hash)
+		if exists {
+			return replay(ctx, record, hash, key)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			// nothing to replay, let the client retry
+			store.Release(storeKey)
+			return resp, err
+		}
+
+		store.Save(storeKey, resp.(proto.Message))
+		return resp, nil
+	}
+}
+
+// Returns stored response for the retried request.
+func replay(ctx context.Context, record idempotency.Record, hash [32]byte, key string) (interface{}, error) {
+	if record.RequestHash != hash {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"idempotency key %v was already used with a different request", key)
+	}
+	if record.Response == nil {
+		return nil, status.Errorf(codes.Aborted,
+			"request with idempotency key %v is still in progress", key)
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedHeader, "true"))
+	log.Ctx(ctx).Info().Msgf("replaying response for idempotency key %v", key)
+
+	return proto.Clone(record.Response), nil
+}
+
+// Idempotency key from the request field or metadata. If both are
+// provided they must be equal.
+func idempotencyKey(ctx context.Context, req idempotentRequest) (string, error) {
+	key := req.GetIdempotencyKey()
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(IdempotencyKeyHeader); len(values) > 0 {
+			if key != "" && key != values[0] {
+				return "", status.Error(codes.InvalidArgument,
+					"idempotency key in metadata and request are different")
+			}
+			key = values[0]
+		}
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", status.Errorf(codes.InvalidArgument,
+			"idempotency key should have at most %v characters", maxIdempotencyKeyLength)
+	}
+	return key, nil
+}
+
+// Hash of the request payload without the idempotency key.
+func requestHash(req idempotentRequest) ([32]byte, error) {
+	msg := proto.Clone(req)
+	m := msg.ProtoReflect()
+	if fd := m.Descriptor().Fields().ByName(idempotencyKeyField); fd != nil {
+		m.Clear(fd)
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,148 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"usermanager/app/infrastructure/idempotency"
+	"usermanager/app/ui/auth"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var createUserInfo = &grpc.UnaryServerInfo{FullMethod: "/proto.UserService/CreateUser"}
+
+func createUserReq(key string) *proto.CreateUserRequest {
+	return &proto.CreateUserRequest{
+		Firstname:      "test",
+		Lastname:       "test",
+		Nickname:       "test",
+		Password:       "testPass",
+		Email:          "test@test.com",
+		Country:        "RS",
+		IdempotencyKey: key,
+	}
+}
+
+// handler that creates new user id on every call and counts calls
+func createUserHandler(calls *int) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		*calls++
+		return &proto.CreateUserResponse{Id: uuid.NewString()}, nil
+	}
+}
+
+func createInterceptor() grpc.UnaryServerInterceptor {
+	return IdempotencyUnaryInterceptor(idempotency.NewIdempotencyStore(time.Hour))
+}
+
+func TestIdempotency_RetryWithSameKey_ShouldReplayResponse(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := context.Background()
+
+	first, err := interceptor(ctx, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+	assert.Nil(t, err)
+
+	second, err := interceptor(ctx, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.(*proto.CreateUserResponse).Id, second.(*proto.CreateUserResponse).Id)
+}
+
+func TestIdempotency_KeyInMetadata_ShouldReplayResponse(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(IdempotencyKeyHeader, "key"))
+
+	first, _ := interceptor(ctx, createUserReq(""), createUserInfo, createUserHandler(&calls))
+	second, err := interceptor(ctx, createUserReq(""), createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.(*proto.CreateUserResponse).Id, second.(*proto.CreateUserResponse).Id)
+}
+
+func TestIdempotency_SameKeyDifferentPayload_ShouldReturnErr(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := context.Background()
+
+	_, _ = interceptor(ctx, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+
+	req := createUserReq("key")
+	req.Nickname = "other"
+	res, err := interceptor(ctx, req, createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, res)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestIdempotency_SameKeyDifferentPrincipals_ShouldNotReplay(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	alice := auth.NewContext(context.Background(), &auth.Principal{Subject: "alice"})
+	bob := auth.NewContext(context.Background(), &auth.Principal{Subject: "bob"})
+
+	first, err := interceptor(alice, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+	assert.Nil(t, err)
+
+	second, err := interceptor(bob, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.NotEqual(t, first.(*proto.CreateUserResponse).Id, second.(*proto.CreateUserResponse).Id)
+}
+
+func TestIdempotency_WithoutKey_ShouldCallHandler(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := context.Background()
+
+	_, _ = interceptor(ctx, createUserReq(""), createUserInfo, createUserHandler(&calls))
+	_, _ = interceptor(ctx, createUserReq(""), createUserInfo, createUserHandler(&calls))
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_HandlerReturnErr_ShouldAllowRetry(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := context.Background()
+	failingHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return nil, errors.New("error ocurred")
+	}
+
+	_, err := interceptor(ctx, createUserReq("key"), createUserInfo, failingHandler)
+	assert.NotNil(t, err)
+
+	res, err := interceptor(ctx, createUserReq("key"), createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_DifferentKeysInMetadataAndRequest_ShouldReturnErr(t *testing.T) {
+	interceptor := createInterceptor()
+	calls := 0
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(IdempotencyKeyHeader, "key"))
+
+	res, err := interceptor(ctx, createUserReq("other"), createUserInfo, createUserHandler(&calls))
+
+	assert.Nil(t, res)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
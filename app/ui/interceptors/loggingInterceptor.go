@@ -0,0 +1,126 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const RequestIdHeader = "x-request-id"
+
+// Longer request ids from callers are replaced with a new one.
+const maxRequestIdLength = 128
+
+// Unary interceptor that assigns request id, puts request scoped logger
+// into the context and logs the outcome of every RPC.
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		ctx, logger := requestLogger(ctx, info.FullMethod)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIdHeader, requestId(ctx)))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logOutcome(ctx, logger, start, err)
+		return resp, err
+	}
+}
+
+// Stream interceptor that assigns request id, puts request scoped logger
+// into the stream context and logs the outcome of every stream.
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		ctx, logger := requestLogger(ss.Context(), info.FullMethod)
+		_ = ss.SetHeader(metadata.Pairs(RequestIdHeader, requestId(ctx)))
+
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logOutcome(ctx, logger, start, err)
+		return err
+	}
+}
+
+type requestIdKey struct{}
+
+// Request id assigned by the logging interceptor.
+func requestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// Takes request id from the metadata or creates a new one and returns
+// context with the id and the logger that carries it.
+func requestLogger(ctx context.Context, method string) (context.Context, *zerolog.Logger) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIdHeader); len(values) > 0 && validRequestId(values[0]) {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	logContext := log.With().
+		Str("request_id", id).
+		Str("method", method)
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		logContext = logContext.Str("trace_id", span.TraceID().String())
+	}
+	logger := logContext.Logger()
+
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return logger.WithContext(ctx), &logger
+}
+
+func logOutcome(ctx context.Context, logger *zerolog.Logger, start time.Time, err error) {
+	code := status.Code(err)
+
+	event := logger.Info()
+	if serverError(code) {
+		event = logger.Error().Err(err)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		event = event.Str("peer", p.Addr.String())
+	}
+	event.
+		Dur("duration", time.Since(start)).
+		Str("code", code.String()).
+		Msg("request finished")
+}
+
+// Codes caused by the server, not by the caller.
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// Only printable ascii ids are accepted, so callers can't inject
+// anything into the logs.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
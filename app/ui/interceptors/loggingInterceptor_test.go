@@ -0,0 +1,125 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Redirect global logger to the buffer for the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = previous })
+	return &buf
+}
+
+func logLines(buf *bytes.Buffer) []map[string]interface{} {
+	lines := []map[string]interface{}{}
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		line := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(l), &line)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLoggingUnary_RequestIdInMetadata_ShouldBePropagated(t *testing.T) {
+	buf := captureLogs(t)
+	interceptor := LoggingUnaryInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(RequestIdHeader, "req-1"))
+
+	// act
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			log.Ctx(ctx).Info().Msg("in handler")
+			return nil, nil
+		})
+
+	// assert
+	assert.Nil(t, err)
+	lines := logLines(buf)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "req-1", lines[0]["request_id"])
+	assert.Equal(t, "req-1", lines[1]["request_id"])
+	assert.Equal(t, "/test", lines[1]["method"])
+	assert.Equal(t, "OK", lines[1]["code"])
+	assert.Contains(t, lines[1], "duration")
+}
+
+func TestLoggingUnary_WithoutRequestId_ShouldAssignNew(t *testing.T) {
+	buf := captureLogs(t)
+	interceptor := LoggingUnaryInterceptor()
+
+	// act
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.Internal, "db error")
+		})
+
+	// assert
+	assert.NotNil(t, err)
+	line := logLines(buf)[0]
+	assert.NotEmpty(t, line["request_id"])
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "Internal", line["code"])
+}
+
+func TestLoggingUnary_InvalidRequestId_ShouldAssignNew(t *testing.T) {
+	buf := captureLogs(t)
+	interceptor := LoggingUnaryInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(RequestIdHeader, "bad\nid"))
+
+	// act
+	_, _ = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+
+	// assert
+	assert.NotEqual(t, "bad\nid", logLines(buf)[0]["request_id"])
+}
+
+// fake server stream that records sent headers
+type headerStream struct {
+	contextStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestLoggingStream_ShouldSendRequestIdHeader(t *testing.T) {
+	buf := captureLogs(t)
+	interceptor := LoggingStreamInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(RequestIdHeader, "req-2"))
+	stream := &headerStream{contextStream: contextStream{ctx: ctx}}
+
+	// act
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test"},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			log.Ctx(ss.Context()).Info().Msg("in handler")
+			return nil
+		})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"req-2"}, stream.header.Get(RequestIdHeader))
+	assert.Equal(t, "req-2", logLines(buf)[0]["request_id"])
+}
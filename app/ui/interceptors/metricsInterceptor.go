@@ -0,0 +1,39 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+	"usermanager/app/infrastructure/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Unary interceptor that records latency and status code of every RPC.
+func MetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// Stream interceptor that records duration and status code of every stream.
+func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, ss)
+		observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observe(method string, start time.Time, err error) {
+	metrics.RpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	metrics.RpcHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+}
@@ -0,0 +1,42 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"usermanager/app/infrastructure/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetricsUnary_ShouldCountStatusCodes(t *testing.T) {
+	interceptor := MetricsUnaryInterceptor()
+	method := "/proto.UserService/MetricsTest"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RpcHandled.WithLabelValues(method, "OK")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RpcHandled.WithLabelValues(method, "NotFound")))
+}
+
+func TestMetricsStream_ShouldCountStatusCodes(t *testing.T) {
+	interceptor := MetricsStreamInterceptor()
+	method := "/proto.UserService/MetricsStreamTest"
+	info := &grpc.StreamServerInfo{FullMethod: method}
+
+	_ = interceptor(nil, &contextStream{ctx: context.Background()}, info,
+		func(srv interface{}, ss grpc.ServerStream) error {
+			return status.Error(codes.Canceled, "canceled")
+		})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RpcHandled.WithLabelValues(method, "Canceled")))
+}
@@ -0,0 +1,68 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"usermanager/app/infrastructure/ratelimit"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Unary interceptor that limits request rate per authenticated principal,
+// or per peer ip for anonymous callers. Health service is not limited.
+func RateLimitUnaryInterceptor(l ratelimit.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := checkRateLimit(ctx, l, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream interceptor that limits how often streams can be opened
+// per authenticated principal or peer ip.
+func RateLimitStreamInterceptor(l ratelimit.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if err := checkRateLimit(ss.Context(), l, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkRateLimit(ctx context.Context, l ratelimit.RateLimiter, method string) error {
+	if isPublicMethod(method) {
+		return nil
+	}
+
+	key := callerKey(ctx)
+	allowed, retryAfter := l.Allow(key, method)
+	if allowed {
+		return nil
+	}
+
+	log.Ctx(ctx).Warn().Str("client", key).Dur("retry_after", retryAfter).Msg("rate limit exceeded")
+	return rateLimitError(retryAfter)
+}
+
+// ResourceExhausted error with RetryInfo detail, so clients know
+// when they can try again.
+func rateLimitError(retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted,
+		fmt.Sprintf("rate limit exceeded, retry after %v", retryAfter.Round(time.Millisecond)))
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
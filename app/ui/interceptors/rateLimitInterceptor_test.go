@@ -0,0 +1,126 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"usermanager/app/infrastructure/ratelimit"
+	"usermanager/app/ui/auth"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func createRateLimitInterceptor() grpc.UnaryServerInterceptor {
+	return RateLimitUnaryInterceptor(ratelimit.NewRateLimiter(ratelimit.Config{
+		Default: ratelimit.Limit{Rate: 1, Burst: 1},
+	}))
+}
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000},
+	})
+}
+
+func TestRateLimitUnary_LimitExceeded_ShouldReturnRetryInfo(t *testing.T) {
+	interceptor := createRateLimitInterceptor()
+	ctx := peerContext("10.0.0.1")
+
+	// arrange
+	_, err := interceptor(ctx, nil, createUserInfo, principalHandler)
+	assert.Nil(t, err)
+
+	// act
+	res, err := interceptor(ctx, nil, createUserInfo, principalHandler)
+
+	// assert
+	assert.Nil(t, res)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Len(t, st.Details(), 1)
+	retryInfo := st.Details()[0].(*errdetails.RetryInfo)
+	assert.True(t, retryInfo.RetryDelay.AsDuration() > 0)
+}
+
+func TestRateLimitUnary_DifferentPeers_ShouldLimitSeparately(t *testing.T) {
+	interceptor := createRateLimitInterceptor()
+
+	// arrange
+	_, _ = interceptor(peerContext("10.0.0.1"), nil, createUserInfo, principalHandler)
+
+	// act
+	_, err := interceptor(peerContext("10.0.0.2"), nil, createUserInfo, principalHandler)
+
+	// assert
+	assert.Nil(t, err)
+}
+
+func TestRateLimitUnary_SamePrincipalDifferentPeers_ShouldShareLimit(t *testing.T) {
+	interceptor := createRateLimitInterceptor()
+	p := &auth.Principal{Subject: "ops-script"}
+
+	// arrange
+	_, _ = interceptor(auth.NewContext(peerContext("10.0.0.1"), p), nil, createUserInfo, principalHandler)
+
+	// act
+	_, err := interceptor(auth.NewContext(peerContext("10.0.0.2"), p), nil, createUserInfo, principalHandler)
+
+	// assert
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestRateLimitUnary_ClientsBehindGateway_ShouldLimitSeparately(t *testing.T) {
+	limit := createRateLimitInterceptor()
+	address := ClientAddressUnaryInterceptor(TrustedProxies{Networks: []*net.IPNet{loopback}})
+	call := func(ip string) error {
+		ctx := metadata.NewIncomingContext(peerContext("127.0.0.1"), metadata.Pairs(ForwardedForHeader, ip))
+		_, err := address(ctx, nil, createUserInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return limit(ctx, req, createUserInfo, principalHandler)
+		})
+		return err
+	}
+
+	// arrange
+	_ = call("203.0.113.1")
+
+	// act
+	err := call("203.0.113.2")
+
+	// assert
+	assert.Nil(t, err)
+}
+
+func TestRateLimitUnary_HealthCheck_ShouldNotBeLimited(t *testing.T) {
+	interceptor := createRateLimitInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	ctx := peerContext("10.0.0.1")
+
+	for i := 0; i < 3; i++ {
+		_, err := interceptor(ctx, nil, info, principalHandler)
+		assert.Nil(t, err)
+	}
+}
+
+func TestRateLimitStream_LimitExceeded_ShouldReturnErr(t *testing.T) {
+	interceptor := RateLimitStreamInterceptor(ratelimit.NewRateLimiter(ratelimit.Config{
+		Default: ratelimit.Limit{Rate: 1, Burst: 1},
+	}))
+	info := &grpc.StreamServerInfo{FullMethod: "/proto.UserService/WatchUsers"}
+	stream := &contextStream{ctx: peerContext("10.0.0.1")}
+	handler := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+
+	// arrange
+	assert.Nil(t, interceptor(nil, stream, info, handler))
+
+	// act
+	err := interceptor(nil, stream, info, handler)
+
+	// assert
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
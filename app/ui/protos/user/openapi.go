@@ -0,0 +1,8 @@
+package proto
+
+import _ "embed"
+
+// OpenAPI v2 document of the HTTP/JSON gateway, generated from user.proto.
+//
+//go:embed user.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,188 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ClientAuthRequire  = "require"
+	ClientAuthOptional = "optional"
+)
+
+// How often certificate files are checked for changes.
+const defaultReloadInterval = 10 * time.Second
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// bundle with CAs used to verify client certificates, enables mTLS
+	ClientCAFile string
+	// require (default) or optional client certificate
+	ClientAuth string
+	// how often files are checked for changes
+	ReloadInterval time.Duration
+	// application protocols, h2 if empty
+	NextProtos []string
+}
+
+// Create server TLS config. Certificate, key and client CA files are
+// reloaded when they change, so certificates can be rotated without
+// restarting the server.
+func NewServerTLSConfig(c Config) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls cert and key files are required")
+	}
+
+	clientAuth := tls.NoClientCert
+	if c.ClientCAFile != "" {
+		switch c.ClientAuth {
+		case "", ClientAuthRequire:
+			clientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthOptional:
+			clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unsupported client auth %v", c.ClientAuth)
+		}
+	}
+
+	r := &reloader{config: c, clientAuth: clientAuth}
+	if c.ReloadInterval <= 0 {
+		r.config.ReloadInterval = defaultReloadInterval
+	}
+	if len(c.NextProtos) == 0 {
+		r.config.NextProtos = []string{"h2"}
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}, nil
+}
+
+// Keeps the current certificate and client CA pool and reloads
+// them from the files when their modification time changes.
+type reloader struct {
+	config     Config
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	tlsConfig *tls.Config
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func (r *reloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.reloadIfChanged()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tlsConfig, nil
+}
+
+// Reload files if any of them changed since the last load. Files are
+// checked at most once per reload interval. If the new files are
+// invalid, the previous certificate stays in use.
+func (r *reloader) reloadIfChanged() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < r.config.ReloadInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	changed := false
+	for path, modTime := range r.modTimes {
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(modTime) {
+			changed = true
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.load(); err != nil {
+		log.Error().Err(err).Msg("cannot reload tls certificates, keeping the previous ones")
+		return
+	}
+	log.Info().Msg("tls certificates reloaded")
+}
+
+func (r *reloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("cannot read %v: %v", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load tls certificate: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+		NextProtos:   r.config.NextProtos,
+	}
+
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("cannot read client ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client ca file has no certificates")
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tlsConfig = tlsConfig
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// Create client TLS config that trusts certificates from the CA file.
+// Server certificate itself can be used as CA file to pin it.
+func NewClientTLSConfig(caFile string, serverName string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ca file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("ca file has no certificates")
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ServerName: serverName,
+	}, nil
+}
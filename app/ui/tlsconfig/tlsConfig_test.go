@@ -0,0 +1,269 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// create certificate signed by parent, or self signed CA if parent is nil
+func createCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("cannot write %v: %v", path, err)
+	}
+}
+
+// start TLS listener that completes handshakes and returns its address
+func serve(t *testing.T, config *tls.Config) string {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+func dial(addr string, ca *testCert, client *testCert) (*tls.Conn, error) {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	if client != nil {
+		config.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{client.cert.Raw},
+			PrivateKey:  client.key,
+		}}
+	}
+
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	// client certificate is verified after the client handshake is done,
+	// so wait for the server to accept or close the connection
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func createFiles(t *testing.T, ca *testCert, server *testCert) Config {
+	dir := t.TempDir()
+	c := Config{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, c.CertFile, server.certPEM)
+	writeFile(t, c.KeyFile, server.keyPEM)
+	writeFile(t, c.ClientCAFile, ca.certPEM)
+	return c
+}
+
+func TestServerTLSConfig_ClientWithValidCert_ShouldConnect(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	config, err := NewServerTLSConfig(createFiles(t, ca, createCert(t, "server", ca)))
+	assert.Nil(t, err)
+	addr := serve(t, config)
+
+	conn, err := dial(addr, ca, createCert(t, "client", ca))
+
+	assert.Nil(t, err)
+	if conn != nil {
+		assert.Equal(t, "server", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+		conn.Close()
+	}
+}
+
+func TestServerTLSConfig_ClientWithoutCert_ShouldBeRejected(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	config, _ := NewServerTLSConfig(createFiles(t, ca, createCert(t, "server", ca)))
+	addr := serve(t, config)
+
+	_, err := dial(addr, ca, nil)
+
+	assert.NotNil(t, err)
+}
+
+func TestServerTLSConfig_OptionalClientAuth_ShouldAcceptClientWithoutCert(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	c := createFiles(t, ca, createCert(t, "server", ca))
+	c.ClientAuth = ClientAuthOptional
+	config, _ := NewServerTLSConfig(c)
+	addr := serve(t, config)
+
+	conn, err := dial(addr, ca, nil)
+
+	assert.Nil(t, err)
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func TestServerTLSConfig_CertificateChanged_ShouldBeReloaded(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	c := createFiles(t, ca, createCert(t, "server", ca))
+	c.ClientCAFile = ""
+	c.ReloadInterval = time.Millisecond
+	config, _ := NewServerTLSConfig(c)
+	addr := serve(t, config)
+
+	// rotate the certificate, make sure modification time changes
+	rotated := createCert(t, "rotated", ca)
+	writeFile(t, c.CertFile, rotated.certPEM)
+	writeFile(t, c.KeyFile, rotated.keyPEM)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(c.CertFile, future, future)
+	time.Sleep(time.Millisecond * 5)
+
+	conn, err := dial(addr, ca, nil)
+
+	assert.Nil(t, err)
+	if conn != nil {
+		assert.Equal(t, "rotated", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+		conn.Close()
+	}
+}
+
+func TestServerTLSConfig_InvalidNewCertificate_ShouldKeepPrevious(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	c := createFiles(t, ca, createCert(t, "server", ca))
+	c.ClientCAFile = ""
+	c.ReloadInterval = time.Millisecond
+	config, _ := NewServerTLSConfig(c)
+	addr := serve(t, config)
+
+	writeFile(t, c.CertFile, []byte("not a certificate"))
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(c.CertFile, future, future)
+	time.Sleep(time.Millisecond * 5)
+
+	conn, err := dial(addr, ca, nil)
+
+	assert.Nil(t, err)
+	if conn != nil {
+		assert.Equal(t, "server", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+		conn.Close()
+	}
+}
+
+func TestNewServerTLSConfig_MissingFiles_ShouldReturnErr(t *testing.T) {
+	config, err := NewServerTLSConfig(Config{CertFile: "missing.crt", KeyFile: "missing.key"})
+
+	assert.Nil(t, config)
+	assert.NotNil(t, err)
+}
+
+func TestClientTLSConfig_ServerSignedByCA_ShouldConnect(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	files := createFiles(t, ca, createCert(t, "server", ca))
+	files.ClientCAFile = ""
+	serverConfig, err := NewServerTLSConfig(files)
+	assert.Nil(t, err)
+	addr := serve(t, serverConfig)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, caFile, ca.certPEM)
+	clientConfig, err := NewClientTLSConfig(caFile, "localhost")
+	assert.Nil(t, err)
+
+	conn, err := tls.Dial("tcp", addr, clientConfig)
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestClientTLSConfig_UnknownServer_ShouldBeRejected(t *testing.T) {
+	ca := createCert(t, "ca", nil)
+	files := createFiles(t, ca, createCert(t, "server", ca))
+	files.ClientCAFile = ""
+	serverConfig, err := NewServerTLSConfig(files)
+	assert.Nil(t, err)
+	addr := serve(t, serverConfig)
+
+	otherCA := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, otherCA, createCert(t, "other", nil).certPEM)
+	clientConfig, err := NewClientTLSConfig(otherCA, "localhost")
+	assert.Nil(t, err)
+
+	_, err = tls.Dial("tcp", addr, clientConfig)
+	assert.NotNil(t, err)
+}
+
+func TestClientTLSConfig_EmptyCAFile_ShouldReturnErr(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, caFile, []byte("not a certificate"))
+
+	_, err := NewClientTLSConfig(caFile, "localhost")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,39 @@
+package validation
+
+import (
+	"errors"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+)
+
+// ListDeadLettersRequest proto message validation
+func ValidateListDeadLettersReq(p *proto.ListDeadLettersRequest) error {
+	if p.Offset < 0 || p.Limit < 0 {
+		return errors.New("offset and limit should not be negative")
+	}
+	return deadLetterFilterValidation(p.Filter)
+}
+
+// ReplayDeadLetterRequest proto message validation
+func ValidateReplayDeadLetterReq(p *proto.ReplayDeadLetterRequest) error {
+	return validateId(p.Id)
+}
+
+// ReplayDeadLettersRequest proto message validation
+func ValidateReplayDeadLettersReq(p *proto.ReplayDeadLettersRequest) error {
+	if p.Limit < 0 {
+		return errors.New("limit should not be negative")
+	}
+	return deadLetterFilterValidation(p.Filter)
+}
+
+func deadLetterFilterValidation(f *proto.DeadLetterFilter) error {
+	if f == nil || f.UserId == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(f.UserId); err != nil {
+		return errors.New("user id wrong format")
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package validation
+
+import (
+	"testing"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListDeadLettersReq_InvalidUserId_ShouldReturnErr(t *testing.T) {
+	err := ValidateListDeadLettersReq(&proto.ListDeadLettersRequest{
+		Filter: &proto.DeadLetterFilter{UserId: "invalid"},
+	})
+
+	assert.Equal(t, "user id wrong format", err.Error())
+}
+
+func TestReplayDeadLettersReq_NegativeLimit_ShouldReturnErr(t *testing.T) {
+	err := ValidateReplayDeadLettersReq(&proto.ReplayDeadLettersRequest{Limit: -1})
+
+	assert.Equal(t, "limit should not be negative", err.Error())
+}
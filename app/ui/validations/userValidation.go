@@ -60,21 +60,20 @@ func ValidateUpdateUserReq(p *proto.UpdateUserRequest) error {
 
 // UserPageRequest proto message validation
 func ValidateUserPageReq(p *proto.UserPageRequest) error {
-	if p.Filter != nil {
-		if p.Filter.Country != "" {
-			if len(p.Filter.Country) > 2 {
-				return errors.New("country should have 2 letters")
-			}
-		}
+	return filterValidation(p.Filter)
+}
 
-		if p.Filter.CreatedFrom != nil && p.Filter.CreatedTo != nil {
-			if p.Filter.CreatedTo.AsTime().Before(p.Filter.CreatedFrom.AsTime()) {
-				return errors.New("'Created to' time is before 'created from'")
-			}
-		}
+// WatchUsersRequest proto message validation
+func ValidateWatchUsersReq(p *proto.WatchUsersRequest) error {
+	if p.AfterSequence != 0 && p.Since != nil {
+		return errors.New("only one of after sequence and since can be set")
 	}
+	return filterValidation(p.Filter)
+}
 
-	return nil
+// GetUserRequest proto message validation
+func ValidateGetUserReq(p *proto.GetUserRequest) error {
+	return validateId(p.Id)
 }
 
 // DeleteUserRequest proto message validation
@@ -82,6 +81,27 @@ func ValidateDeleteUserReq(p *proto.DeleteUserRequest) error {
 	return validateId(p.Id)
 }
 
+// user filter options validation
+func filterValidation(f *proto.UserPageRequest_UserFilterOptions) error {
+	if f == nil {
+		return nil
+	}
+
+	if f.Country != "" {
+		if len(f.Country) > 2 {
+			return errors.New("country should have 2 letters")
+		}
+	}
+
+	if f.CreatedFrom != nil && f.CreatedTo != nil {
+		if f.CreatedTo.AsTime().Before(f.CreatedFrom.AsTime()) {
+			return errors.New("'Created to' time is before 'created from'")
+		}
+	}
+
+	return nil
+}
+
 func emailValidation(email string) error {
 	if email == "" {
 		return errors.New("email is required")
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestCreateUserReq_WithValidReq_ShouldPass(t *testing.T) {
@@ -337,3 +338,39 @@ func TestUpdateUserReq_CountryWrongFormat_ShouldReturnErr(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, err.Error(), expectedErr)
 }
+
+func TestWatchUsersReq_WithValidReq_ShouldPass(t *testing.T) {
+	req := &proto.WatchUsersRequest{
+		Filter:        &proto.UserPageRequest_UserFilterOptions{Country: "RS"},
+		AfterSequence: 10,
+	}
+
+	err := ValidateWatchUsersReq(req)
+
+	assert.Nil(t, err)
+}
+
+func TestWatchUsersReq_BothResumeParams_ShouldReturnErr(t *testing.T) {
+	req := &proto.WatchUsersRequest{
+		AfterSequence: 10,
+		Since:         timestamppb.Now(),
+	}
+	expectedErr := "only one of after sequence and since can be set"
+
+	err := ValidateWatchUsersReq(req)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), expectedErr)
+}
+
+func TestWatchUsersReq_CountryWrongFormat_ShouldReturnErr(t *testing.T) {
+	req := &proto.WatchUsersRequest{
+		Filter: &proto.UserPageRequest_UserFilterOptions{Country: "SRB"},
+	}
+	expectedErr := "country should have 2 letters"
+
+	err := ValidateWatchUsersReq(req)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), expectedErr)
+}
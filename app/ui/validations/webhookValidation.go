@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+)
+
+const minSecretLength = 16
+
+// CreateWebhookRequest proto message validation. allowInsecure
+// accepts http urls and internal hosts, for development only.
+func ValidateCreateWebhookReq(p *proto.CreateWebhookRequest, allowInsecure bool) error {
+	if err := webhookUrlValidation(p.Url, allowInsecure); err != nil {
+		return err
+	}
+	if err := eventTypesValidation(p.EventTypes); err != nil {
+		return err
+	}
+	return secretValidation(p.Secret)
+}
+
+// UpdateWebhookRequest proto message validation. allowInsecure
+// accepts http urls and internal hosts, for development only.
+func ValidateUpdateWebhookReq(p *proto.UpdateWebhookRequest, allowInsecure bool) error {
+	if err := validateId(p.Id); err != nil {
+		return err
+	}
+	if err := webhookUrlValidation(p.Url, allowInsecure); err != nil {
+		return err
+	}
+	if err := eventTypesValidation(p.EventTypes); err != nil {
+		return err
+	}
+	return secretValidation(p.Secret)
+}
+
+// DeleteWebhookRequest proto message validation
+func ValidateDeleteWebhookReq(p *proto.DeleteWebhookRequest) error {
+	return validateId(p.Id)
+}
+
+// ListWebhookDeliveriesRequest proto message validation
+func ValidateListWebhookDeliveriesReq(p *proto.ListWebhookDeliveriesRequest) error {
+	if err := validateId(p.WebhookId); err != nil {
+		return err
+	}
+	if p.Limit < 0 {
+		return errors.New("limit should not be negative")
+	}
+	return nil
+}
+
+// webhook url should be absolute https url of a public host. Hosts
+// given by name are checked again when the dispatcher connects.
+func webhookUrlValidation(u string, allowInsecure bool) error {
+	if u == "" {
+		return errors.New("url is required")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return errors.New("url should be absolute http or https url")
+	}
+	if allowInsecure {
+		return nil
+	}
+
+	if parsed.Scheme != "https" {
+		return errors.New("url should be https url")
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil && !domain.WebhookAddressAllowed(ip) {
+		return errors.New("url should not point to a loopback, private or link-local address")
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.New("url should not point to a loopback, private or link-local address")
+	}
+	return nil
+}
+
+func eventTypesValidation(types []proto.UserEvent_Type) error {
+	for _, t := range types {
+		if t != proto.UserEvent_CREATED && t != proto.UserEvent_UPDATED && t != proto.UserEvent_DELETED {
+			return errors.New("event type should be CREATED, UPDATED or DELETED")
+		}
+	}
+	return nil
+}
+
+// empty secret is generated or kept
+func secretValidation(secret string) error {
+	if secret != "" && len(secret) < minSecretLength {
+		return errors.New("secret should have at least 16 characters")
+	}
+	return nil
+}
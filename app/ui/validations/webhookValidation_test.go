@@ -0,0 +1,69 @@
+package validation
+
+import (
+	"testing"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWebhookReq_WithValidReq_ShouldPass(t *testing.T) {
+	err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{
+		Url:        "https://partner.example.com/hooks",
+		EventTypes: []proto.UserEvent_Type{proto.UserEvent_CREATED},
+	}, false)
+
+	assert.Nil(t, err)
+}
+
+func TestCreateWebhookReq_RelativeUrl_ShouldReturnErr(t *testing.T) {
+	err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{Url: "/hooks"}, false)
+
+	assert.Equal(t, "url should be absolute http or https url", err.Error())
+}
+
+func TestCreateWebhookReq_UnknownEventType_ShouldReturnErr(t *testing.T) {
+	err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{
+		Url:        "https://partner.example.com/hooks",
+		EventTypes: []proto.UserEvent_Type{proto.UserEvent_UNKNOWN},
+	}, false)
+
+	assert.Equal(t, "event type should be CREATED, UPDATED or DELETED", err.Error())
+}
+
+func TestUpdateWebhookReq_ShortSecret_ShouldReturnErr(t *testing.T) {
+	err := ValidateUpdateWebhookReq(&proto.UpdateWebhookRequest{
+		Id:     uuid.NewString(),
+		Url:    "https://partner.example.com/hooks",
+		Secret: "short",
+	}, false)
+
+	assert.Equal(t, "secret should have at least 16 characters", err.Error())
+}
+
+func TestCreateWebhookReq_HttpUrl_ShouldReturnErr(t *testing.T) {
+	err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{Url: "http://partner.example.com/hooks"}, false)
+
+	assert.Equal(t, "url should be https url", err.Error())
+}
+
+func TestCreateWebhookReq_InternalHost_ShouldReturnErr(t *testing.T) {
+	for _, u := range []string{
+		"https://127.0.0.1/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5:8443/hooks",
+		"https://[::1]/hooks",
+		"https://localhost/hooks",
+	} {
+		err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{Url: u}, false)
+
+		assert.Equal(t, "url should not point to a loopback, private or link-local address", err.Error(), u)
+	}
+}
+
+func TestCreateWebhookReq_InsecureAllowed_ShouldAcceptLocalHttpUrl(t *testing.T) {
+	err := ValidateCreateWebhookReq(&proto.CreateWebhookRequest{Url: "http://localhost:8080/hooks"}, true)
+
+	assert.Nil(t, err)
+}
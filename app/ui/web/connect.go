@@ -0,0 +1,561 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	connectUnaryJson   = "application/json"
+	connectUnaryProto  = "application/proto"
+	connectStreamJson  = "application/connect+json"
+	connectStreamProto = "application/connect+proto"
+	grpcContentType    = "application/grpc+proto"
+)
+
+// Max size of the request message, same as the gRPC server default.
+const maxMessageSize = 4 << 20
+
+const (
+	flagCompressed = 0x01
+	flagEndStream  = 0x02
+)
+
+// Connect error codes by gRPC code.
+var connectCodes = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// HTTP status of failed unary Connect call by gRPC code.
+var connectHttpStatus = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// Translates Connect protocol calls to gRPC calls on the gRPC server.
+// Unary and server streaming methods are supported, with JSON or
+// binary protobuf messages and without compression.
+type connectHandler struct {
+	grpc http.Handler
+}
+
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type endStreamMessage struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+func (h *connectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	method, ok := findMethod(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if method.IsStreamingClient() {
+		writeUnaryError(w, status.New(codes.Unimplemented, "client streaming is not supported"))
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	streaming := method.IsStreamingServer()
+	useJson, ok := codecFor(contentType, streaming)
+	if !ok {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	if streaming {
+		encoding = r.Header.Get("Connect-Content-Encoding")
+	}
+	if encoding != "" && encoding != "identity" {
+		h.fail(w, streaming, contentType, status.Newf(codes.Unimplemented,
+			"compression %v is not supported", encoding))
+		return
+	}
+
+	msg, err := readRequest(r.Body, streaming)
+	if err == nil {
+		msg, err = toBinary(msg, method.Input(), useJson)
+	}
+	if err != nil {
+		h.fail(w, streaming, contentType, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	grpcReq, err := grpcRequest(r, msg)
+	if err != nil {
+		h.fail(w, streaming, contentType, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	if streaming {
+		h.serveStream(w, grpcReq, method, contentType, useJson)
+	} else {
+		h.serveUnary(w, grpcReq, method, contentType, useJson)
+	}
+}
+
+func (h *connectHandler) serveUnary(w http.ResponseWriter, r *http.Request,
+	method protoreflect.MethodDescriptor, contentType string, useJson bool) {
+
+	var messages [][]byte
+	resp := newGrpcResponse(func(msg []byte) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	h.grpc.ServeHTTP(resp, r)
+
+	copyHeaders(w.Header(), resp.metadata())
+	for k, vv := range resp.trailers() {
+		w.Header()["Trailer-"+k] = vv
+	}
+
+	st := resp.status()
+	if st.Code() == codes.OK && len(messages) != 1 {
+		st = status.Newf(codes.Internal, "unary response has %v messages", len(messages))
+	}
+	if st.Code() != codes.OK {
+		writeUnaryError(w, st)
+		return
+	}
+
+	body, err := fromBinary(messages[0], method.Output(), useJson)
+	if err != nil {
+		writeUnaryError(w, status.New(codes.Internal, err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func (h *connectHandler) serveStream(w http.ResponseWriter, r *http.Request,
+	method protoreflect.MethodDescriptor, contentType string, useJson bool) {
+
+	var resp *grpcResponse
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		copyHeaders(w.Header(), resp.metadata())
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	resp = newGrpcResponse(func(msg []byte) error {
+		data, err := fromBinary(msg, method.Output(), useJson)
+		if err != nil {
+			return err
+		}
+		start()
+		if err := writeEnvelope(w, 0, data); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	})
+	h.grpc.ServeHTTP(resp, r)
+
+	start()
+	end := endStreamMessage{Metadata: resp.trailers()}
+	if st := resp.status(); st.Code() != codes.OK {
+		end.Error = toConnectError(st)
+	}
+	data, _ := json.Marshal(end)
+	_ = writeEnvelope(w, flagEndStream, data)
+}
+
+// Report error that occurred before the gRPC call.
+func (h *connectHandler) fail(w http.ResponseWriter, streaming bool, contentType string, st *status.Status) {
+	if !streaming {
+		writeUnaryError(w, st)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	data, _ := json.Marshal(endStreamMessage{Error: toConnectError(st)})
+	_ = writeEnvelope(w, flagEndStream, data)
+}
+
+// Find method by the request path /package.Service/Method.
+func findMethod(path string) (protoreflect.MethodDescriptor, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(parts[0]))
+	if err != nil {
+		return nil, false
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, false
+	}
+	method := service.Methods().ByName(protoreflect.Name(parts[1]))
+	return method, method != nil
+}
+
+// Returns whether messages are JSON for the Connect content type.
+func codecFor(contentType string, streaming bool) (bool, bool) {
+	if streaming {
+		switch contentType {
+		case connectStreamJson:
+			return true, true
+		case connectStreamProto:
+			return false, true
+		}
+		return false, false
+	}
+
+	switch contentType {
+	case connectUnaryJson:
+		return true, true
+	case connectUnaryProto:
+		return false, true
+	}
+	return false, false
+}
+
+// Read the request message. Streaming requests have the message in
+// an envelope, unary requests are the message itself.
+func readRequest(body io.Reader, streaming bool) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxMessageSize+5+1))
+	if err != nil {
+		return nil, err
+	}
+	if !streaming {
+		if len(data) > maxMessageSize {
+			return nil, errors.New("request message is too large")
+		}
+		return data, nil
+	}
+
+	if len(data) < 5 {
+		return nil, errors.New("request envelope is incomplete")
+	}
+	if data[0]&flagCompressed != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(data[1:5])
+	if size > maxMessageSize {
+		return nil, errors.New("request message is too large")
+	}
+	if uint32(len(data)-5) != size {
+		return nil, errors.New("request must contain exactly one message")
+	}
+	return data[5:], nil
+}
+
+func toBinary(data []byte, desc protoreflect.MessageDescriptor, useJson bool) ([]byte, error) {
+	if !useJson {
+		return data, nil
+	}
+	msg, err := newMessage(desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("cannot parse json message: %v", err)
+	}
+	return proto.Marshal(msg)
+}
+
+func fromBinary(data []byte, desc protoreflect.MessageDescriptor, useJson bool) ([]byte, error) {
+	if !useJson {
+		return data, nil
+	}
+	msg, err := newMessage(desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(msg)
+}
+
+func newMessage(desc protoreflect.MessageDescriptor) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName())
+	if err != nil {
+		return nil, err
+	}
+	return mt.New().Interface(), nil
+}
+
+// Create gRPC request with the same metadata and the message in
+// a gRPC frame.
+func grpcRequest(r *http.Request, msg []byte) (*http.Request, error) {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodPost
+	req.Proto = "HTTP/2"
+	req.ProtoMajor = 2
+	req.ProtoMinor = 0
+	req.ContentLength = -1
+
+	var body bytes.Buffer
+	if err := writeEnvelope(&body, 0, msg); err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(&body)
+
+	req.Header = http.Header{}
+	for k, vv := range r.Header {
+		switch strings.ToLower(k) {
+		case "content-type", "content-length", "content-encoding", "accept-encoding",
+			"connection", "te", "connect-protocol-version", "connect-timeout-ms",
+			"connect-content-encoding", "connect-accept-encoding":
+			continue
+		}
+		req.Header[k] = vv
+	}
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("Te", "trailers")
+
+	if timeout := r.Header.Get("Connect-Timeout-Ms"); timeout != "" {
+		ms, err := strconv.ParseUint(timeout, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %v", timeout)
+		}
+		req.Header.Set("Grpc-Timeout", fmt.Sprintf("%vm", ms))
+	}
+	return req, nil
+}
+
+func writeEnvelope(w io.Writer, flags byte, data []byte) error {
+	prefix := make([]byte, 5)
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func writeUnaryError(w http.ResponseWriter, st *status.Status) {
+	data, _ := json.Marshal(toConnectError(st))
+	w.Header().Set("Content-Type", connectUnaryJson)
+	w.WriteHeader(connectHttpStatus[st.Code()])
+	_, _ = w.Write(data)
+}
+
+func toConnectError(st *status.Status) *connectError {
+	e := &connectError{
+		Code:    connectCodes[st.Code()],
+		Message: st.Message(),
+	}
+	if e.Code == "" {
+		e.Code = connectCodes[codes.Unknown]
+	}
+	for _, d := range st.Proto().GetDetails() {
+		typeName := d.GetTypeUrl()
+		if i := strings.LastIndex(typeName, "/"); i >= 0 {
+			typeName = typeName[i+1:]
+		}
+		e.Details = append(e.Details, connectDetail{
+			Type:  typeName,
+			Value: base64.RawStdEncoding.EncodeToString(d.GetValue()),
+		})
+	}
+	return e
+}
+
+func copyHeaders(dst http.Header, src http.Header) {
+	for k, vv := range src {
+		dst[k] = append(dst[k], vv...)
+	}
+}
+
+// Response writer given to the gRPC server. Splits the gRPC response
+// into metadata, messages and trailers.
+type grpcResponse struct {
+	header     http.Header
+	sentHeader http.Header
+	buf        bytes.Buffer
+	onMessage  func([]byte) error
+	err        error
+}
+
+func newGrpcResponse(onMessage func([]byte) error) *grpcResponse {
+	return &grpcResponse{header: http.Header{}, onMessage: onMessage}
+}
+
+func (r *grpcResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *grpcResponse) WriteHeader(int) {
+	r.commit()
+}
+
+func (r *grpcResponse) Flush() {
+	r.commit()
+}
+
+func (r *grpcResponse) Write(p []byte) (int, error) {
+	r.commit()
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.buf.Write(p)
+
+	for r.buf.Len() >= 5 {
+		prefix := r.buf.Bytes()[:5]
+		size := int(binary.BigEndian.Uint32(prefix[1:]))
+		if r.buf.Len() < 5+size {
+			break
+		}
+		if prefix[0]&flagCompressed != 0 {
+			r.err = errors.New("compressed response is not supported")
+			return 0, r.err
+		}
+		r.buf.Next(5)
+		msg := append([]byte(nil), r.buf.Next(size)...)
+		if err := r.onMessage(msg); err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Headers are sent on the first write, later changes are trailers.
+func (r *grpcResponse) commit() {
+	if r.sentHeader == nil {
+		r.sentHeader = r.header.Clone()
+	}
+}
+
+// Custom metadata sent as headers.
+func (r *grpcResponse) metadata() http.Header {
+	md := http.Header{}
+	for k, vv := range r.sentHeader {
+		if reservedHeader(k) {
+			continue
+		}
+		md[k] = vv
+	}
+	return md
+}
+
+// Custom metadata sent as trailers.
+func (r *grpcResponse) trailers() map[string][]string {
+	md := map[string][]string{}
+	for k, vv := range r.header {
+		if strings.HasPrefix(k, http2.TrailerPrefix) {
+			md[strings.TrimPrefix(k, http2.TrailerPrefix)] = vv
+		}
+	}
+	return md
+}
+
+// Status of the call from grpc-status, grpc-message and
+// grpc-status-details-bin trailers.
+func (r *grpcResponse) status() *status.Status {
+	if r.err != nil {
+		return status.New(codes.Internal, r.err.Error())
+	}
+
+	if details := r.header.Get("Grpc-Status-Details-Bin"); details != "" {
+		data, err := decodeBinHeader(details)
+		st := &spb.Status{}
+		if err == nil && proto.Unmarshal(data, st) == nil {
+			return status.FromProto(st)
+		}
+	}
+
+	code, err := strconv.Atoi(r.header.Get("Grpc-Status"))
+	if err != nil {
+		return status.New(codes.Internal, "missing grpc status")
+	}
+	message, err := url.PathUnescape(r.header.Get("Grpc-Message"))
+	if err != nil {
+		message = r.header.Get("Grpc-Message")
+	}
+	return status.New(codes.Code(code), message)
+}
+
+func reservedHeader(k string) bool {
+	switch strings.ToLower(k) {
+	case "content-type", "trailer", "date", "te":
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(k), "grpc-") || strings.HasPrefix(k, http2.TrailerPrefix)
+}
+
+func decodeBinHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// Headers browser clients may send.
+var allowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Grpc-Timeout",
+	"Idempotency-Key",
+	"X-Api-Key",
+	"X-Grpc-Web",
+	"X-Request-Id",
+	"X-User-Agent",
+}
+
+// Headers browser clients may read from the response.
+var exposedHeaders = []string{
+	"Grpc-Status",
+	"Grpc-Message",
+	"Grpc-Status-Details-Bin",
+	"Idempotent-Replayed",
+	"X-Request-Id",
+}
+
+// Headers dropped from requests before they reach the gRPC server. The
+// server trusts X-Forwarded-For only from the gateway or a trusted proxy,
+// but requests of this listener are handled in-process. The role and user
+// id are read from metadata for local development only and are never
+// taken from browsers, also not with the Grpc-Metadata- prefix.
+var droppedHeaders = []string{
+	"X-Forwarded-For",
+	"X-Role",
+	"X-User-Id",
+	"Grpc-Metadata-X-Role",
+	"Grpc-Metadata-X-User-Id",
+}
+
+type Config struct {
+	// origins allowed to call the server from a browser, * allows any
+	AllowedOrigins []string
+}
+
+// Create HTTP handler that serves services registered on the gRPC server
+// over grpc-web, Connect and gRPC protocols. Works over HTTP/1.1 and
+// HTTP/2, with or without TLS. Requests are handled by the gRPC server,
+// so they go through the same interceptors.
+func NewHandler(g *grpc.Server, c Config) http.Handler {
+	grpcWeb := grpcweb.WrapServer(g)
+	connect := &connectHandler{grpc: g}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range droppedHeaders {
+			r.Header.Del(h)
+		}
+		switch {
+		case grpcWeb.IsGrpcWebRequest(r):
+			grpcWeb.HandleGrpcWebRequest(w, r)
+		case r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc"):
+			g.ServeHTTP(w, r)
+		default:
+			connect.ServeHTTP(w, r)
+		}
+	})
+
+	// h2c lets gRPC clients use HTTP/2 without TLS
+	return h2c.NewHandler(cors(c.AllowedOrigins, handler), &http2.Server{})
+}
+
+// Adds CORS headers for allowed origins and answers preflight requests.
+func cors(allowedOrigins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !originAllowed(allowedOrigins, origin) {
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+			h.Set("Access-Control-Max-Age", "7200")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func originAllowed(allowedOrigins []string, origin string) bool {
+	for _, o := range allowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
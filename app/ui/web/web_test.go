@@ -0,0 +1,272 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	health "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+)
+
+type fakeUserServer struct {
+	proto.UnimplementedUserServiceServer
+	md metadata.MD
+}
+
+func (s *fakeUserServer) CreateUser(ctx context.Context, req *proto.CreateUserRequest) (*proto.CreateUserResponse, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", "req-1"))
+	return &proto.CreateUserResponse{Id: req.Nickname + "-id"}, nil
+}
+
+func (s *fakeUserServer) DeleteUser(ctx context.Context, req *proto.DeleteUserRequest) (*proto.DeleteUserResponse, error) {
+	return nil, status.Error(codes.NotFound, "no user in database")
+}
+
+func (s *fakeUserServer) WatchUsers(req *proto.WatchUsersRequest, stream proto.UserService_WatchUsersServer) error {
+	for i := uint64(1); i <= 2; i++ {
+		if err := stream.Send(&proto.UserEvent{Sequence: req.AfterSequence + i}); err != nil {
+			return err
+		}
+	}
+	return status.Error(codes.ResourceExhausted, "subscriber is too slow")
+}
+
+type fakeHealthServer struct {
+	health.UnimplementedHealthServer
+}
+
+func (s *fakeHealthServer) Check(ctx context.Context, req *health.HealthCheckRequest) (*health.HealthCheckResponse, error) {
+	return &health.HealthCheckResponse{Status: health.HealthCheckResponse_SERVING}, nil
+}
+
+func createWebServer(t *testing.T) (*httptest.Server, *fakeUserServer) {
+	g := grpc.NewServer()
+	fake := &fakeUserServer{}
+	proto.RegisterUserServiceServer(g, fake)
+	health.RegisterHealthServer(g, &fakeHealthServer{})
+
+	server := httptest.NewServer(NewHandler(g, Config{AllowedOrigins: []string{"http://app.example.com"}}))
+	t.Cleanup(server.Close)
+	return server, fake
+}
+
+func post(t *testing.T, url string, contentType string, body []byte, headers map[string]string) *http.Response {
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func envelope(flags byte, data []byte) []byte {
+	var buf bytes.Buffer
+	_ = writeEnvelope(&buf, flags, data)
+	return buf.Bytes()
+}
+
+// split body into envelopes, returns flags and data of each of them
+func readEnvelopes(t *testing.T, body io.Reader) ([]byte, [][]byte) {
+	data, _ := io.ReadAll(body)
+	flags, messages := []byte{}, [][]byte{}
+	for len(data) >= 5 {
+		size := binary.BigEndian.Uint32(data[1:5])
+		flags = append(flags, data[0])
+		messages = append(messages, data[5:5+size])
+		data = data[5+size:]
+	}
+	if len(data) != 0 {
+		t.Fatalf("incomplete envelope")
+	}
+	return flags, messages
+}
+
+func TestConnect_UnaryJson_ShouldCallGrpcService(t *testing.T) {
+	server, fake := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "application/json",
+		[]byte(`{"nickname":"john"}`), map[string]string{"X-Api-Key": "key"})
+
+	// assert
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"john-id"}`, string(body))
+	assert.Equal(t, "req-1", resp.Header.Get("X-Request-Id"))
+	assert.Equal(t, []string{"key"}, fake.md.Get("x-api-key"))
+}
+
+func TestConnect_ForwardedFor_ShouldBeDropped(t *testing.T) {
+	server, fake := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "application/json",
+		[]byte(`{"nickname":"john"}`), map[string]string{"X-Forwarded-For": "203.0.113.1"})
+
+	// assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, fake.md.Get("x-forwarded-for"))
+}
+
+func TestConnect_IdentityHeaders_ShouldBeDropped(t *testing.T) {
+	server, fake := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "application/json",
+		[]byte(`{"nickname":"john"}`), map[string]string{"X-Role": "admin", "X-User-Id": "1"})
+
+	// assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, fake.md.Get("x-role"))
+	assert.Empty(t, fake.md.Get("x-user-id"))
+}
+
+func TestConnect_UnaryProto_ShouldCallGrpcService(t *testing.T) {
+	server, _ := createWebServer(t)
+	req, _ := pb.Marshal(&proto.CreateUserRequest{Nickname: "john"})
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "application/proto", req, nil)
+
+	// assert
+	body, _ := io.ReadAll(resp.Body)
+	res := &proto.CreateUserResponse{}
+	assert.Nil(t, pb.Unmarshal(body, res))
+	assert.Equal(t, "john-id", res.Id)
+}
+
+func TestConnect_UnaryErr_ShouldReturnConnectErr(t *testing.T) {
+	server, _ := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/DeleteUser", "application/json", []byte(`{"id":"1"}`), nil)
+
+	// assert
+	var body map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "not_found", body["code"])
+	assert.Equal(t, "no user in database", body["message"])
+}
+
+func TestConnect_InvalidJson_ShouldReturnInvalidArgument(t *testing.T) {
+	server, _ := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "application/json", []byte(`{"nickname":`), nil)
+
+	// assert
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestConnect_ServerStream_ShouldStreamMessagesAndEndStream(t *testing.T) {
+	server, _ := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/WatchUsers", "application/connect+json",
+		envelope(0, []byte(`{"afterSequence":"10"}`)), nil)
+
+	// assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	flags, messages := readEnvelopes(t, resp.Body)
+	assert.Equal(t, []byte{0, 0, flagEndStream}, flags)
+	assert.JSONEq(t, `{"sequence":"11"}`, string(messages[0]))
+	assert.JSONEq(t, `{"sequence":"12"}`, string(messages[1]))
+
+	var end endStreamMessage
+	assert.Nil(t, json.Unmarshal(messages[2], &end))
+	assert.Equal(t, "resource_exhausted", end.Error.Code)
+}
+
+func TestConnect_UnknownMethod_ShouldReturnNotFound(t *testing.T) {
+	server, _ := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/Unknown", "application/json", []byte(`{}`), nil)
+
+	// assert
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestConnect_UnsupportedContentType_ShouldReturnErr(t *testing.T) {
+	server, _ := createWebServer(t)
+
+	// act
+	resp := post(t, server.URL+"/proto.UserService/CreateUser", "text/plain", []byte(`{}`), nil)
+
+	// assert
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestGrpcWeb_HealthCheck_ShouldCallGrpcService(t *testing.T) {
+	server, _ := createWebServer(t)
+	req, _ := pb.Marshal(&health.HealthCheckRequest{})
+
+	// act
+	resp := post(t, server.URL+"/grpc.health.v1.Health/Check", "application/grpc-web+proto",
+		envelope(0, req), map[string]string{"X-Grpc-Web": "1"})
+
+	// assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	flags, messages := readEnvelopes(t, resp.Body)
+	assert.Equal(t, []byte{0, 0x80}, flags)
+
+	res := &health.HealthCheckResponse{}
+	assert.Nil(t, pb.Unmarshal(messages[0], res))
+	assert.Equal(t, health.HealthCheckResponse_SERVING, res.Status)
+	assert.Contains(t, strings.ToLower(string(messages[1])), "grpc-status: 0")
+}
+
+func TestCors_AllowedOriginPreflight_ShouldAllow(t *testing.T) {
+	server, _ := createWebServer(t)
+	req, _ := http.NewRequest(http.MethodOptions, server.URL+"/proto.UserService/CreateUser", nil)
+	req.Header.Set("Origin", "http://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "http://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+}
+
+func TestCors_UnknownOriginPreflight_ShouldBeRejected(t *testing.T) {
+	server, _ := createWebServer(t)
+	req, _ := http.NewRequest(http.MethodOptions, server.URL+"/proto.UserService/CreateUser", nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	// act
+	resp, err := http.DefaultClient.Do(req)
+
+	// assert
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+
+	"usermanager/app/ui/auth"
+	proto "usermanager/app/ui/protos/user"
+	"usermanager/app/ui/tlsconfig"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// Connected clients and output of a command.
+type cli struct {
+	settings settings
+	conn     *grpc.ClientConn
+	users    proto.UserServiceClient
+	health   healthpb.HealthClient
+	out      output
+	stdin    io.Reader
+	stderr   io.Writer
+}
+
+// Connect to the server. Connection is established lazily,
+// on the first call.
+func newCli(s settings, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*cli, error) {
+	creds := insecure.NewCredentials()
+	if s.TLS || s.CAFile != "" || s.CertFile != "" {
+		tlsConfig, err := clientTLSConfig(s)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(s.Addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %v: %v", s.Addr, err)
+	}
+	return newCliWithConn(s, conn, stdin, stdout, stderr), nil
+}
+
+func newCliWithConn(s settings, conn *grpc.ClientConn, stdin io.Reader, stdout io.Writer, stderr io.Writer) *cli {
+	return &cli{
+		settings: s,
+		conn:     conn,
+		users:    proto.NewUserServiceClient(conn),
+		health:   healthpb.NewHealthClient(conn),
+		out:      output{w: stdout, format: s.Output},
+		stdin:    stdin,
+		stderr:   stderr,
+	}
+}
+
+func (c *cli) close() {
+	c.conn.Close()
+}
+
+// Context of a single call with the deadline and credentials.
+func (c *cli) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.settings.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.AuthorizationHeader, "Bearer "+c.settings.Token)
+	}
+	if c.settings.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, c.settings.APIKey)
+	}
+	if c.settings.Timeout > 0 {
+		return context.WithTimeout(ctx, c.settings.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// TLS config that trusts the CA file, or system roots without it,
+// and presents the client certificate if there is one.
+func clientTLSConfig(s settings) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: s.ServerName}
+	if s.CAFile != "" {
+		var err error
+		tlsConfig, err = tlsconfig.NewClientTLSConfig(s.CAFile, s.ServerName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
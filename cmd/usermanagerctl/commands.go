@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	proto "usermanager/app/ui/protos/user"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Users requested by one page when listing all of them.
+const defaultPageSize = 100
+
+func newFlagSet(c *cli, name string, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: usermanagerctl %v %v\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// User fields shared by create and update.
+type userFlags struct {
+	firstname, lastname, nickname, password, email, country string
+	passwordStdin                                           bool
+	idempotencyKey                                          string
+}
+
+func (f *userFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.firstname, "firstname", "", "first name")
+	fs.StringVar(&f.lastname, "lastname", "", "last name")
+	fs.StringVar(&f.nickname, "nickname", "", "unique nickname")
+	fs.StringVar(&f.password, "password", "", "password, prefer --password-stdin")
+	fs.BoolVar(&f.passwordStdin, "password-stdin", false, "read password from stdin")
+	fs.StringVar(&f.email, "email", "", "unique email")
+	fs.StringVar(&f.country, "country", "", "two letter country code")
+	fs.StringVar(&f.idempotencyKey, "idempotency-key", "", "makes the call safe to retry")
+}
+
+// Password from the flag or the first line of stdin.
+func (f *userFlags) readPassword(c *cli) error {
+	if !f.passwordStdin {
+		return nil
+	}
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("cannot read password from stdin: %v", err)
+	}
+	f.password = strings.TrimRight(line, "\r\n")
+	return nil
+}
+
+func createCmd(ctx context.Context, c *cli, args []string) error {
+	var f userFlags
+	fs := newFlagSet(c, "create", "--firstname --lastname --nickname --email --country --password-stdin")
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := f.readPassword(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	res, err := c.users.CreateUser(ctx, &proto.CreateUserRequest{
+		Firstname:      f.firstname,
+		Lastname:       f.lastname,
+		Nickname:       f.nickname,
+		Password:       f.password,
+		Email:          f.email,
+		Country:        f.country,
+		IdempotencyKey: f.idempotencyKey,
+	})
+	if err != nil {
+		return err
+	}
+	return c.out.results([]result{{Id: res.Id, Status: "created"}})
+}
+
+func updateCmd(ctx context.Context, c *cli, args []string) error {
+	var f userFlags
+	var id string
+	fs := newFlagSet(c, "update", "--id --firstname --lastname --nickname --email --country --password-stdin")
+	fs.StringVar(&id, "id", "", "user id")
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := f.readPassword(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	res, err := c.users.UpdateUser(ctx, &proto.UpdateUserRequest{
+		Id:             id,
+		Firstname:      f.firstname,
+		Lastname:       f.lastname,
+		Nickname:       f.nickname,
+		Password:       f.password,
+		Email:          f.email,
+		Country:        f.country,
+		IdempotencyKey: f.idempotencyKey,
+	})
+	if err != nil {
+		return err
+	}
+	return c.out.results([]result{{Id: res.Id, Status: "updated"}})
+}
+
+// Delete every user from the args. All of them are tried,
+// the error is returned if any failed.
+func deleteCmd(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet(c, "delete", "<id>...")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("at least one user id is required")
+	}
+
+	results := make([]result, 0, fs.NArg())
+	failed := 0
+	for _, id := range fs.Args() {
+		callCtx, cancel := c.callContext(ctx)
+		_, err := c.users.DeleteUser(callCtx, &proto.DeleteUserRequest{Id: id})
+		cancel()
+
+		if err != nil {
+			failed++
+			results = append(results, result{Id: id, Status: "failed", Error: errorMessage(err)})
+			continue
+		}
+		results = append(results, result{Id: id, Status: "deleted"})
+	}
+
+	if err := c.out.results(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v users not deleted", failed, len(results))
+	}
+	return nil
+}
+
+// Filter flags shared by list and export.
+type filterFlags struct {
+	country, createdFrom, createdTo string
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.country, "country", "", "two letter country code")
+	fs.StringVar(&f.createdFrom, "created-from", "", "created after, RFC3339 time")
+	fs.StringVar(&f.createdTo, "created-to", "", "created before, RFC3339 time")
+}
+
+func (f *filterFlags) filter() (*proto.UserPageRequest_UserFilterOptions, error) {
+	filter := &proto.UserPageRequest_UserFilterOptions{Country: f.country}
+	for _, t := range []struct {
+		name  string
+		value string
+		field **timestamppb.Timestamp
+	}{
+		{"created-from", f.createdFrom, &filter.CreatedFrom},
+		{"created-to", f.createdTo, &filter.CreatedTo},
+	} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, fmt.Errorf("%v should be RFC3339 time like 2023-01-02T15:04:05Z", t.name)
+		}
+		*t.field = timestamppb.New(parsed)
+	}
+	return filter, nil
+}
+
+func listCmd(ctx context.Context, c *cli, args []string) error {
+	var f filterFlags
+	var offset, limit int
+	var all bool
+	fs := newFlagSet(c, "list", "[--country] [--created-from] [--created-to] [--offset] [--limit | --all]")
+	f.register(fs)
+	fs.IntVar(&offset, "offset", 0, "users to skip")
+	fs.IntVar(&limit, "limit", 20, "users to return")
+	fs.BoolVar(&all, "all", false, "page through all users")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := f.filter()
+	if err != nil {
+		return err
+	}
+
+	var users []user
+	if all {
+		users, err = c.allUsers(ctx, filter, offset, defaultPageSize)
+	} else {
+		users, err = c.page(ctx, filter, offset, limit)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.users(users)
+}
+
+func exportCmd(ctx context.Context, c *cli, args []string) error {
+	var f filterFlags
+	var file string
+	var pageSize int
+	fs := newFlagSet(c, "export", "--file users.csv|json|yaml [--country] [--created-from] [--created-to]")
+	f.register(fs)
+	fs.StringVar(&file, "file", "", "file to write, format from the extension")
+	fs.IntVar(&pageSize, "page-size", defaultPageSize, "users requested at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if file == "" {
+		return errors.New("--file is required")
+	}
+	if _, err := fileFormat(file); err != nil {
+		return err
+	}
+	filter, err := f.filter()
+	if err != nil {
+		return err
+	}
+
+	users, err := c.allUsers(ctx, filter, 0, pageSize)
+	if err != nil {
+		return err
+	}
+	if err := writeUsers(file, users); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "exported %v users to %v\n", len(users), file)
+	return nil
+}
+
+// Create users from the file. Every row gets idempotency key from its
+// content, so the import can be run again after a failure without
+// creating duplicates.
+func importCmd(ctx context.Context, c *cli, args []string) error {
+	var file string
+	var stopOnError bool
+	fs := newFlagSet(c, "import", "--file users.csv|json|yaml [--stop-on-error]")
+	fs.StringVar(&file, "file", "", "file to read, format from the extension")
+	fs.BoolVar(&stopOnError, "stop-on-error", false, "stop at the first failed user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if file == "" {
+		return errors.New("--file is required")
+	}
+
+	users, err := readUsers(file)
+	if err != nil {
+		return err
+	}
+
+	results := make([]result, 0, len(users))
+	failed := 0
+	for _, u := range users {
+		req := &proto.CreateUserRequest{
+			Firstname: u.Firstname,
+			Lastname:  u.Lastname,
+			Nickname:  u.Nickname,
+			Password:  u.Password,
+			Email:     u.Email,
+			Country:   u.Country,
+		}
+		req.IdempotencyKey = importKey(req)
+
+		callCtx, cancel := c.callContext(ctx)
+		res, err := c.users.CreateUser(callCtx, req)
+		cancel()
+
+		if err != nil {
+			failed++
+			results = append(results, result{Id: u.Nickname, Status: "failed", Error: errorMessage(err)})
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		results = append(results, result{Id: res.Id, Status: "created"})
+	}
+
+	if err := c.out.results(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v users not imported", failed, len(users))
+	}
+	return nil
+}
+
+// Idempotency key from the request content.
+func importKey(req *proto.CreateUserRequest) string {
+	h := sha256.New()
+	for _, field := range []string{req.Firstname, req.Lastname, req.Nickname, req.Password, req.Email, req.Country} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return "import-" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func healthCmd(ctx context.Context, c *cli, args []string) error {
+	var service string
+	fs := newFlagSet(c, "health", "[--service name]")
+	fs.StringVar(&service, "service", "", "service to check, whole server if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	res, err := c.health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+
+	status := res.Status.String()
+	if err := c.out.print(map[string]string{"service": service, "status": status},
+		[]string{"SERVICE", "STATUS"}, [][]string{{service, status}}); err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("server is %v", status)
+	}
+	return nil
+}
+
+func (c *cli) page(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions,
+	offset int, limit int) ([]user, error) {
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	res, err := c.users.GetUserPage(ctx, &proto.UserPageRequest{
+		Offset: int32(offset),
+		Limit:  int32(limit),
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]user, len(res.Users))
+	for i, u := range res.Users {
+		users[i] = userFromProto(u)
+	}
+	return users, nil
+}
+
+// Request pages until a page is not full.
+func (c *cli) allUsers(ctx context.Context, filter *proto.UserPageRequest_UserFilterOptions,
+	offset int, pageSize int) ([]user, error) {
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	users := []user{}
+	for {
+		page, err := c.page(ctx, filter, offset, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		if len(page) < pageSize {
+			return users, nil
+		}
+		offset += len(page)
+	}
+}
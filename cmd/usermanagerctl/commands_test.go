@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fake user service that keeps users in memory
+type fakeUserServer struct {
+	proto.UnimplementedUserServiceServer
+	users      []*proto.UserPageResponse_User
+	creates    []*proto.CreateUserRequest
+	deleted    []string
+	lastMD     metadata.MD
+	pageLimits []int32
+}
+
+func (s *fakeUserServer) CreateUser(ctx context.Context, req *proto.CreateUserRequest) (*proto.CreateUserResponse, error) {
+	s.lastMD, _ = metadata.FromIncomingContext(ctx)
+	for _, u := range s.users {
+		if u.Nickname == req.Nickname {
+			return nil, status.Error(codes.InvalidArgument, "nickname already exist")
+		}
+	}
+	s.creates = append(s.creates, req)
+	id := "id-" + req.Nickname
+	s.users = append(s.users, &proto.UserPageResponse_User{Id: id, Nickname: req.Nickname,
+		Firstname: req.Firstname, Lastname: req.Lastname, Email: req.Email, Country: req.Country})
+	return &proto.CreateUserResponse{Id: id}, nil
+}
+
+func (s *fakeUserServer) DeleteUser(ctx context.Context, req *proto.DeleteUserRequest) (*proto.DeleteUserResponse, error) {
+	if req.Id == "missing" {
+		return nil, status.Error(codes.NotFound, "no user in database")
+	}
+	s.deleted = append(s.deleted, req.Id)
+	return &proto.DeleteUserResponse{Id: req.Id}, nil
+}
+
+func (s *fakeUserServer) GetUserPage(ctx context.Context, req *proto.UserPageRequest) (*proto.UserPageResponse, error) {
+	s.pageLimits = append(s.pageLimits, req.Limit)
+	users := []*proto.UserPageResponse_User{}
+	for _, u := range s.users {
+		if req.Filter == nil || req.Filter.Country == "" || req.Filter.Country == u.Country {
+			users = append(users, u)
+		}
+	}
+	start := int(req.Offset)
+	if start > len(users) {
+		start = len(users)
+	}
+	end := len(users)
+	if req.Limit > 0 && start+int(req.Limit) < end {
+		end = start + int(req.Limit)
+	}
+	return &proto.UserPageResponse{Users: users[start:end]}, nil
+}
+
+// Start fake server and return cli connected to it.
+func startCli(t *testing.T, s settings) (*cli, *fakeUserServer, *bytes.Buffer) {
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	server := &fakeUserServer{}
+	proto.RegisterUserServiceServer(g, server)
+	healthpb.RegisterHealthServer(g, health.NewServer())
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+	c := newCliWithConn(s, conn, strings.NewReader(""), &out, &bytes.Buffer{})
+	t.Cleanup(c.close)
+	return c, server, &out
+}
+
+func addUsers(server *fakeUserServer, n int, country string) {
+	for i := 0; i < n; i++ {
+		server.users = append(server.users, &proto.UserPageResponse_User{
+			Id: "id", Nickname: "nick", Country: country})
+	}
+}
+
+func TestCreate_ShouldSendCredentialsAndPrintId(t *testing.T) {
+	s := defaultSettings()
+	s.Token = "token"
+	c, server, out := startCli(t, s)
+	c.stdin = strings.NewReader("secret\n")
+
+	// act
+	err := createCmd(context.Background(), c, []string{"--firstname", "Ana", "--lastname", "Petrovic",
+		"--nickname", "ana", "--email", "ana@test.com", "--country", "RS", "--password-stdin"})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", server.creates[0].Password)
+	assert.Equal(t, []string{"Bearer token"}, server.lastMD.Get("authorization"))
+	assert.Contains(t, out.String(), "id-ana")
+	assert.Contains(t, out.String(), "created")
+}
+
+func TestDelete_SomeFailed_ShouldDeleteOthersAndReturnErr(t *testing.T) {
+	c, server, out := startCli(t, defaultSettings())
+
+	err := deleteCmd(context.Background(), c, []string{"first", "missing", "second"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"first", "second"}, server.deleted)
+	assert.Contains(t, out.String(), "NotFound: no user in database")
+}
+
+func TestList_JsonOutput_ShouldPrintUsers(t *testing.T) {
+	s := defaultSettings()
+	s.Output = outputJson
+	c, server, out := startCli(t, s)
+	addUsers(server, 3, "RS")
+	addUsers(server, 2, "DE")
+
+	// act
+	err := listCmd(context.Background(), c, []string{"--country", "RS", "--limit", "2"})
+
+	// assert
+	assert.Nil(t, err)
+	var users []user
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &users))
+	assert.Len(t, users, 2)
+	assert.Equal(t, "RS", users[0].Country)
+}
+
+func TestList_All_ShouldPageThroughUsers(t *testing.T) {
+	c, server, _ := startCli(t, defaultSettings())
+	addUsers(server, 250, "RS")
+
+	users, err := c.allUsers(context.Background(), nil, 0, 100)
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 250)
+	assert.Equal(t, []int32{100, 100, 100}, server.pageLimits)
+}
+
+func TestList_InvalidTime_ShouldReturnErr(t *testing.T) {
+	c, _, _ := startCli(t, defaultSettings())
+
+	err := listCmd(context.Background(), c, []string{"--created-from", "yesterday"})
+
+	assert.Contains(t, err.Error(), "created-from should be RFC3339 time")
+}
+
+func TestImportExport_ShouldRoundTripUsers(t *testing.T) {
+	c, server, _ := startCli(t, defaultSettings())
+	dir := t.TempDir()
+
+	// arrange
+	importFile := filepath.Join(dir, "import.csv")
+	assert.Nil(t, os.WriteFile(importFile, []byte(
+		"nickname,firstname,lastname,email,country,password\n"+
+			"ana,Ana,Petrovic,ana@test.com,RS,pass1\n"+
+			"ivan,Ivan,Ivic,ivan@test.com,HR,pass2\n"), 0600))
+	exportFile := filepath.Join(dir, "export.yaml")
+
+	// act
+	importErr := importCmd(context.Background(), c, []string{"--file", importFile})
+	exportErr := exportCmd(context.Background(), c, []string{"--file", exportFile})
+
+	// assert
+	assert.Nil(t, importErr)
+	assert.Nil(t, exportErr)
+	assert.Len(t, server.creates, 2)
+	assert.NotEmpty(t, server.creates[0].IdempotencyKey)
+
+	exported, err := readUsers(exportFile)
+	assert.Nil(t, err)
+	assert.Len(t, exported, 2)
+	assert.Equal(t, "ivan", exported[1].Nickname)
+	assert.Empty(t, exported[1].Password)
+}
+
+func TestImport_DuplicateUser_ShouldReportFailure(t *testing.T) {
+	c, server, out := startCli(t, defaultSettings())
+	server.users = append(server.users, &proto.UserPageResponse_User{Nickname: "ana"})
+
+	// arrange
+	file := filepath.Join(t.TempDir(), "users.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"nickname": "ana"}, {"nickname": "ivan"}]`), 0600))
+
+	// act
+	err := importCmd(context.Background(), c, []string{"--file", file})
+
+	// assert
+	assert.Equal(t, "1 of 2 users not imported", err.Error())
+	assert.Contains(t, out.String(), "nickname already exist")
+	assert.Len(t, server.creates, 1)
+}
+
+func TestImportKey_SameContent_ShouldBeEqual(t *testing.T) {
+	first := importKey(&proto.CreateUserRequest{Nickname: "ana", Email: "ana@test.com"})
+	second := importKey(&proto.CreateUserRequest{Nickname: "ana", Email: "ana@test.com"})
+	other := importKey(&proto.CreateUserRequest{Nickname: "anaa", Email: "na@test.com"})
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+}
+
+func TestHealth_Serving_ShouldPrintStatus(t *testing.T) {
+	c, _, out := startCli(t, defaultSettings())
+
+	err := healthCmd(context.Background(), c, nil)
+
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "SERVING")
+}
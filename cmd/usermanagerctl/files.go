@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var csvColumns = []string{"id", "firstname", "lastname", "nickname", "password", "email", "country", "created"}
+
+// File format from the extension: csv, json or yaml.
+func fileFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return outputJson, nil
+	case ".yaml", ".yml":
+		return outputYaml, nil
+	}
+	return "", fmt.Errorf("unsupported file %v, use .csv, .json or .yaml", path)
+}
+
+// Read users from csv file with a header row, or from json or yaml list.
+func readUsers(path string) ([]user, error) {
+	format, err := fileFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var users []user
+	switch format {
+	case "csv":
+		users, err = readCsv(f)
+	case outputJson:
+		err = json.NewDecoder(f).Decode(&users)
+	case outputYaml:
+		err = yaml.NewDecoder(f).Decode(&users)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %v: %v", path, err)
+	}
+	return users, nil
+}
+
+// Write users to csv, json or yaml file.
+func writeUsers(path string, users []user) error {
+	format, err := fileFormat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if format == "csv" {
+		err = writeCsv(f, users)
+	} else {
+		err = output{w: f, format: format}.print(users, nil, nil)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Columns are matched by header name, so they can be in any order
+// and the ones that are not needed can be left out.
+func readCsv(r io.Reader) ([]user, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	value := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	users := make([]user, 0, len(records)-1)
+	for line, record := range records[1:] {
+		u := user{
+			Id:        value(record, "id"),
+			Firstname: value(record, "firstname"),
+			Lastname:  value(record, "lastname"),
+			Nickname:  value(record, "nickname"),
+			Password:  value(record, "password"),
+			Email:     value(record, "email"),
+			Country:   value(record, "country"),
+		}
+		if created := value(record, "created"); created != "" {
+			t, err := time.Parse(time.RFC3339, created)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: created should be RFC3339 time", line+2)
+			}
+			u.Created = &t
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func writeCsv(w io.Writer, users []user) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, u := range users {
+		created := ""
+		if u.Created != nil {
+			created = u.Created.Format(time.RFC3339)
+		}
+		record := []string{u.Id, u.Firstname, u.Lastname, u.Nickname, u.Password, u.Email, u.Country, created}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
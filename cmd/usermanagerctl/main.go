@@ -0,0 +1,220 @@
+// Command usermanagerctl is the admin client for the user manager
+// gRPC service.
+//
+//	usermanagerctl [global flags] <command> [flags]
+//
+// Connection and auth settings are read from the config file
+// (~/.usermanagerctl.yaml or --config) and can be overridden by flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = ".usermanagerctl.yaml"
+
+// Connection, auth and output settings.
+type settings struct {
+	// server address, host:port
+	Addr string `yaml:"addr"`
+	// connect with TLS, system roots are trusted if CAFile is empty
+	TLS        bool   `yaml:"tls"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+	// client certificate for mTLS
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// bearer token or api key sent with every call
+	Token  string `yaml:"token"`
+	APIKey string `yaml:"api_key"`
+	// deadline of every call
+	Timeout time.Duration `yaml:"timeout"`
+	// table, json or yaml
+	Output string `yaml:"output"`
+}
+
+func defaultSettings() settings {
+	return settings{
+		Addr:    "localhost:9000",
+		Timeout: 10 * time.Second,
+		Output:  outputTable,
+	}
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"create", "create a user", createCmd},
+	{"update", "update a user", updateCmd},
+	{"delete", "delete users by id", deleteCmd},
+	{"list", "list users with filters and pagination", listCmd},
+	{"export", "write users to a csv, json or yaml file", exportCmd},
+	{"import", "create users from a csv, json or yaml file", importCmd},
+	{"health", "check server health", healthCmd},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Parse global flags, connect to the server and run the command.
+// Returns process exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	s, rest, err := loadSettings(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if len(rest) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+
+	cmd, ok := findCommand(rest[0])
+	if !ok {
+		fmt.Fprintf(stderr, "error: unknown command %q\n", rest[0])
+		printUsage(stderr)
+		return 2
+	}
+
+	c, err := newCli(s, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer c.close()
+
+	if err := cmd.run(context.Background(), c, rest[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "error: %v\n", errorMessage(err))
+		return 1
+	}
+	return 0
+}
+
+// Settings from defaults, config file and global flags, in that order.
+// Returns args left after global flags.
+func loadSettings(args []string, stderr io.Writer) (settings, []string, error) {
+	s := defaultSettings()
+
+	path, explicit := configPath(args)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, &s); err != nil {
+				return s, nil, fmt.Errorf("config file %v: %v", path, err)
+			}
+		case explicit || !errors.Is(err, os.ErrNotExist):
+			return s, nil, fmt.Errorf("config file: %v", err)
+		}
+	}
+
+	fs := flag.NewFlagSet("usermanagerctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printUsage(stderr) }
+	fs.String("config", path, "config file")
+	fs.StringVar(&s.Addr, "addr", s.Addr, "server address")
+	fs.BoolVar(&s.TLS, "tls", s.TLS, "connect with TLS")
+	fs.StringVar(&s.CAFile, "ca-file", s.CAFile, "CA that signed the server certificate")
+	fs.StringVar(&s.ServerName, "server-name", s.ServerName, "server name in the certificate")
+	fs.StringVar(&s.CertFile, "cert-file", s.CertFile, "client certificate for mTLS")
+	fs.StringVar(&s.KeyFile, "key-file", s.KeyFile, "client key for mTLS")
+	fs.StringVar(&s.Token, "token", s.Token, "bearer token")
+	fs.StringVar(&s.APIKey, "api-key", s.APIKey, "api key")
+	fs.DurationVar(&s.Timeout, "timeout", s.Timeout, "deadline of every call")
+	fs.StringVar(&s.Output, "o", s.Output, "output format: table, json or yaml")
+	fs.StringVar(&s.Output, "output", s.Output, "output format: table, json or yaml")
+	if err := fs.Parse(args); err != nil {
+		return s, nil, err
+	}
+
+	switch s.Output {
+	case outputTable, outputJson, outputYaml:
+	default:
+		return s, nil, fmt.Errorf("unsupported output %q, use table, json or yaml", s.Output)
+	}
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return s, nil, errors.New("both cert-file and key-file are required for mTLS")
+	}
+	return s, fs.Args(), nil
+}
+
+// Config file from --config flag, USERMANAGERCTL_CONFIG or the
+// default file in the home directory. Only a file that was asked
+// for explicitly has to exist.
+func configPath(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == "config" && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config="), true
+		}
+	}
+	if path := os.Getenv("USERMANAGERCTL_CONFIG"); path != "" {
+		return path, true
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, defaultConfigFile), false
+	}
+	return "", false
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: usermanagerctl [global flags] <command> [flags]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8v %v\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(w, "\nglobal flags:")
+	fmt.Fprintln(w, "  --config file      config file, default ~/"+defaultConfigFile)
+	fmt.Fprintln(w, "  --addr host:port   server address, default localhost:9000")
+	fmt.Fprintln(w, "  --tls              connect with TLS (--ca-file, --server-name)")
+	fmt.Fprintln(w, "  --cert-file, --key-file  client certificate for mTLS")
+	fmt.Fprintln(w, "  --token, --api-key credentials sent with every call")
+	fmt.Fprintln(w, "  --timeout 10s      deadline of every call")
+	fmt.Fprintln(w, "  -o, --output       table (default), json or yaml")
+	fmt.Fprintln(w, "\nrun 'usermanagerctl <command> --help' for command flags")
+}
+
+// Status message with the code for grpc errors.
+func errorMessage(err error) string {
+	if st, ok := status.FromError(err); ok {
+		return fmt.Sprintf("%v: %v", st.Code(), st.Message())
+	}
+	return err.Error()
+}
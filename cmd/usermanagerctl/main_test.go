@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSettings_ConfigFileAndFlags_ShouldOverrideDefaults(t *testing.T) {
+	// arrange
+	path := filepath.Join(t.TempDir(), "ctl.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("addr: users:9000\ntimeout: 3s\napi_key: file-key\n"), 0600))
+
+	// act
+	s, rest, err := loadSettings([]string{"--config", path, "--api-key", "flag-key", "-o", "yaml",
+		"list", "--limit", "5"}, &bytes.Buffer{})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "users:9000", s.Addr)
+	assert.Equal(t, 3*time.Second, s.Timeout)
+	assert.Equal(t, "flag-key", s.APIKey)
+	assert.Equal(t, outputYaml, s.Output)
+	assert.Equal(t, []string{"list", "--limit", "5"}, rest)
+}
+
+func TestLoadSettings_MissingExplicitConfig_ShouldReturnErr(t *testing.T) {
+	_, _, err := loadSettings([]string{"--config=missing.yaml", "health"}, &bytes.Buffer{})
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadSettings_UnsupportedOutput_ShouldReturnErr(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, _, err := loadSettings([]string{"--output", "xml", "health"}, &bytes.Buffer{})
+
+	assert.Contains(t, err.Error(), "unsupported output")
+}
+
+func TestRun_UnknownCommand_ShouldPrintUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var stderr bytes.Buffer
+
+	code := run([]string{"destroy"}, nil, &bytes.Buffer{}, &stderr)
+
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "unknown command")
+	assert.Contains(t, stderr.String(), "usage: usermanagerctl")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+	outputYaml  = "yaml"
+)
+
+// User as printed, exported and imported. Password is only
+// read from import files, the server never returns it.
+type user struct {
+	Id        string     `json:"id,omitempty" yaml:"id,omitempty"`
+	Firstname string     `json:"firstname" yaml:"firstname"`
+	Lastname  string     `json:"lastname" yaml:"lastname"`
+	Nickname  string     `json:"nickname" yaml:"nickname"`
+	Password  string     `json:"password,omitempty" yaml:"password,omitempty"`
+	Email     string     `json:"email" yaml:"email"`
+	Country   string     `json:"country" yaml:"country"`
+	Created   *time.Time `json:"created,omitempty" yaml:"created,omitempty"`
+}
+
+func userFromProto(u *proto.UserPageResponse_User) user {
+	res := user{
+		Id:        u.Id,
+		Firstname: u.Firstname,
+		Lastname:  u.Lastname,
+		Nickname:  u.Nickname,
+		Email:     u.Email,
+		Country:   u.Country,
+	}
+	if u.Created != nil {
+		created := u.Created.AsTime()
+		res.Created = &created
+	}
+	return res
+}
+
+// Result of create, update and delete.
+type result struct {
+	Id     string `json:"id" yaml:"id"`
+	Status string `json:"status" yaml:"status"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Writes values as table, json or yaml.
+type output struct {
+	w      io.Writer
+	format string
+}
+
+// Print value as json or yaml, or the header and rows as table.
+func (o output) print(v interface{}, header []string, rows [][]string) error {
+	switch o.format {
+	case outputJson:
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYaml:
+		enc := yaml.NewEncoder(o.w)
+		enc.SetIndent(2)
+		return enc.Encode(v)
+	default:
+		tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+func (o output) users(users []user) error {
+	rows := make([][]string, len(users))
+	for i, u := range users {
+		created := ""
+		if u.Created != nil {
+			created = u.Created.Format(time.RFC3339)
+		}
+		rows[i] = []string{u.Id, u.Nickname, u.Firstname, u.Lastname, u.Email, u.Country, created}
+	}
+	return o.print(users, []string{"ID", "NICKNAME", "FIRSTNAME", "LASTNAME", "EMAIL", "COUNTRY", "CREATED"}, rows)
+}
+
+func (o output) results(results []result) error {
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = []string{r.Id, r.Status, r.Error}
+	}
+	return o.print(results, []string{"ID", "STATUS", "ERROR"}, rows)
+}
@@ -0,0 +1,291 @@
+// Package client is a typed Go client of the user manager UserService.
+package client
+
+import (
+	"context"
+	"time"
+
+	"usermanager/app/ui/auth"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = 100 * time.Millisecond
+	defaultMaxBackoff  = 2 * time.Second
+	defaultPageSize    = 100
+)
+
+// Client settings
+type Config struct {
+	// deadline of one attempt, zero leaves it to the ctx of the call
+	Timeout time.Duration
+	// attempts of a call that fails with UNAVAILABLE, including the
+	// first one
+	MaxAttempts int
+	// delay before the first retry, doubled for every next one
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// sent as bearer token with every call, optional
+	Token string
+	// sent as api key with every call, optional
+	APIKey string
+	// users requested by one page of the iterator
+	PageSize int32
+}
+
+// User as returned by the server, without the password.
+type User struct {
+	Id        uuid.UUID
+	Firstname string
+	Lastname  string
+	Nickname  string
+	Email     string
+	Country   string
+	// zero if the server doesn't know it
+	Created time.Time
+}
+
+// Fields of a created or updated user.
+type UserInput struct {
+	Firstname string
+	Lastname  string
+	Nickname  string
+	Password  string
+	Email     string
+	Country   string
+	// makes retries safe, generated by the client if empty
+	IdempotencyKey string
+}
+
+// Filter of the listed users, zero values are not applied.
+type Filter struct {
+	Country     string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+type Client interface {
+	// Create user and return its id.
+	CreateUser(ctx context.Context, u UserInput) (uuid.UUID, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, u UserInput) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetUser(ctx context.Context, id uuid.UUID) (User, error)
+	// Iterate over all users matching the filter, page by page.
+	Users(ctx context.Context, filter Filter) *UserIterator
+}
+
+type client struct {
+	users  proto.UserServiceClient
+	config Config
+	// waits before retry, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Create client on the connection, with defaults for the unset
+// settings. The connection is owned by the caller.
+func NewClient(conn grpc.ClientConnInterface, c Config) *client {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.PageSize <= 0 {
+		c.PageSize = defaultPageSize
+	}
+	return &client{
+		users:  proto.NewUserServiceClient(conn),
+		config: c,
+		sleep:  sleep,
+	}
+}
+
+func (c *client) CreateUser(ctx context.Context, u UserInput) (uuid.UUID, error) {
+	req := &proto.CreateUserRequest{
+		Firstname:      u.Firstname,
+		Lastname:       u.Lastname,
+		Nickname:       u.Nickname,
+		Password:       u.Password,
+		Email:          u.Email,
+		Country:        u.Country,
+		IdempotencyKey: idempotencyKey(u.IdempotencyKey),
+	}
+
+	var resp *proto.CreateUserResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.users.CreateUser(ctx, req)
+		return err
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(resp.Id)
+}
+
+func (c *client) UpdateUser(ctx context.Context, id uuid.UUID, u UserInput) error {
+	req := &proto.UpdateUserRequest{
+		Id:             id.String(),
+		Firstname:      u.Firstname,
+		Lastname:       u.Lastname,
+		Nickname:       u.Nickname,
+		Password:       u.Password,
+		Email:          u.Email,
+		Country:        u.Country,
+		IdempotencyKey: idempotencyKey(u.IdempotencyKey),
+	}
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.users.UpdateUser(ctx, req)
+		return err
+	})
+}
+
+func (c *client) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	req := &proto.DeleteUserRequest{Id: id.String(), IdempotencyKey: uuid.NewString()}
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.users.DeleteUser(ctx, req)
+		return err
+	})
+}
+
+func (c *client) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	var resp *proto.UserPageResponse_User
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.users.GetUser(ctx, &proto.GetUserRequest{Id: id.String()})
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return fromProto(resp)
+}
+
+func (c *client) Users(ctx context.Context, filter Filter) *UserIterator {
+	return &UserIterator{
+		ctx:    ctx,
+		client: c,
+		filter: protoFilter(filter),
+		limit:  c.config.PageSize,
+	}
+}
+
+func (c *client) page(ctx context.Context, req *proto.UserPageRequest) ([]*proto.UserPageResponse_User, error) {
+	var resp *proto.UserPageResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.users.GetUserPage(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
+
+// Run the call with credentials and the per-attempt deadline, retrying
+// with exponential backoff while the server is unavailable. Returns
+// the typed error.
+func (c *client) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx = c.withCredentials(ctx)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.attempt(ctx, fn)
+		if status.Code(err) != codes.Unavailable || attempt >= c.config.MaxAttempts {
+			break
+		}
+		if sleepErr := c.sleep(ctx, c.backoff(attempt)); sleepErr != nil {
+			break
+		}
+	}
+	return mapError(err)
+}
+
+func (c *client) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+func (c *client) withCredentials(ctx context.Context) context.Context {
+	if c.config.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.AuthorizationHeader, "Bearer "+c.config.Token)
+	}
+	if c.config.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, c.config.APIKey)
+	}
+	return ctx
+}
+
+// Delay before the retry after the attempt.
+func (c *client) backoff(attempt int) time.Duration {
+	delay := c.config.Backoff
+	for i := 1; i < attempt && delay < c.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.config.MaxBackoff {
+		delay = c.config.MaxBackoff
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func idempotencyKey(key string) string {
+	if key == "" {
+		return uuid.NewString()
+	}
+	return key
+}
+
+func protoFilter(f Filter) *proto.UserPageRequest_UserFilterOptions {
+	filter := &proto.UserPageRequest_UserFilterOptions{Country: f.Country}
+	if !f.CreatedFrom.IsZero() {
+		filter.CreatedFrom = timestamppb.New(f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		filter.CreatedTo = timestamppb.New(f.CreatedTo)
+	}
+	return filter
+}
+
+func fromProto(u *proto.UserPageResponse_User) (User, error) {
+	id, err := uuid.Parse(u.Id)
+	if err != nil {
+		return User{}, err
+	}
+	user := User{
+		Id:        id,
+		Firstname: u.Firstname,
+		Lastname:  u.Lastname,
+		Nickname:  u.Nickname,
+		Email:     u.Email,
+		Country:   u.Country,
+	}
+	if u.Created != nil {
+		user.Created = u.Created.AsTime()
+	}
+	return user, nil
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"usermanager/app/domain"
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fake user service that keeps users in memory
+type fakeUserServer struct {
+	proto.UnimplementedUserServiceServer
+	users []*proto.UserPageResponse_User
+	// failures with UNAVAILABLE before calls succeed
+	unavailable int
+	createKeys  []string
+	lastMD      metadata.MD
+	pageLimits  []int32
+}
+
+func (s *fakeUserServer) CreateUser(ctx context.Context, req *proto.CreateUserRequest) (*proto.CreateUserResponse, error) {
+	s.lastMD, _ = metadata.FromIncomingContext(ctx)
+	s.createKeys = append(s.createKeys, req.IdempotencyKey)
+	if s.unavailable > 0 {
+		s.unavailable--
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	for _, u := range s.users {
+		if u.Nickname == req.Nickname {
+			st, _ := status.New(codes.InvalidArgument, "nickname already exist").
+				WithDetails(&errdetails.ErrorInfo{Reason: domain.ErrorReasonNicknameTaken, Domain: domain.ErrorDomain})
+			return nil, st.Err()
+		}
+	}
+	id := uuid.NewString()
+	s.users = append(s.users, &proto.UserPageResponse_User{Id: id, Nickname: req.Nickname})
+	return &proto.CreateUserResponse{Id: id}, nil
+}
+
+func (s *fakeUserServer) GetUser(ctx context.Context, req *proto.GetUserRequest) (*proto.UserPageResponse_User, error) {
+	for _, u := range s.users {
+		if u.Id == req.Id {
+			return u, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "no user in database")
+}
+
+func (s *fakeUserServer) GetUserPage(ctx context.Context, req *proto.UserPageRequest) (*proto.UserPageResponse, error) {
+	s.pageLimits = append(s.pageLimits, req.Limit)
+	users := []*proto.UserPageResponse_User{}
+	for _, u := range s.users {
+		if req.Filter == nil || req.Filter.Country == "" || req.Filter.Country == u.Country {
+			users = append(users, u)
+		}
+	}
+	start := int(req.Offset)
+	if start > len(users) {
+		start = len(users)
+	}
+	end := len(users)
+	if start+int(req.Limit) < end {
+		end = start + int(req.Limit)
+	}
+	return &proto.UserPageResponse{Users: users[start:end]}, nil
+}
+
+// Start fake server and return client connected to it.
+func startClient(t *testing.T, c Config) (*client, *fakeUserServer) {
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	server := &fakeUserServer{}
+	proto.RegisterUserServiceServer(g, server)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	client := NewClient(conn, c)
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+	return client, server
+}
+
+func addUsers(server *fakeUserServer, n int, country string) {
+	for i := 0; i < n; i++ {
+		server.users = append(server.users, &proto.UserPageResponse_User{
+			Id: uuid.NewString(), Nickname: "nick", Country: country})
+	}
+}
+
+func TestCreateUser_ShouldSendCredentialsAndReturnId(t *testing.T) {
+	c, server := startClient(t, Config{Token: "token", APIKey: "key"})
+
+	// act
+	id, err := c.CreateUser(context.Background(), UserInput{Nickname: "ben"})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, server.users[0].Id, id.String())
+	assert.Equal(t, []string{"Bearer token"}, server.lastMD.Get("authorization"))
+	assert.Equal(t, []string{"key"}, server.lastMD.Get("x-api-key"))
+}
+
+func TestCreateUser_NicknameTaken_ShouldReturnErrNicknameTaken(t *testing.T) {
+	c, server := startClient(t, Config{})
+	addUsers(server, 1, "DE")
+
+	// act
+	_, err := c.CreateUser(context.Background(), UserInput{Nickname: "nick"})
+
+	// assert
+	assert.True(t, errors.Is(err, ErrNicknameTaken))
+}
+
+func TestMapError_MessageWithoutReason_ShouldReturnErrInvalidArgument(t *testing.T) {
+	// act
+	err := mapError(status.Error(codes.InvalidArgument, "nickname already exist"))
+
+	// assert
+	assert.True(t, errors.Is(err, ErrInvalidArgument))
+	assert.False(t, errors.Is(err, ErrNicknameTaken))
+}
+
+func TestCreateUser_Unavailable_ShouldRetryWithSameIdempotencyKey(t *testing.T) {
+	c, server := startClient(t, Config{MaxAttempts: 3})
+	server.unavailable = 2
+
+	// act
+	_, err := c.CreateUser(context.Background(), UserInput{Nickname: "ben"})
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, server.createKeys, 3)
+	assert.NotEmpty(t, server.createKeys[0])
+	assert.Equal(t, server.createKeys[0], server.createKeys[2])
+}
+
+func TestCreateUser_UnavailableAfterLastAttempt_ShouldReturnErrUnavailable(t *testing.T) {
+	c, server := startClient(t, Config{MaxAttempts: 2})
+	server.unavailable = 5
+
+	// act
+	_, err := c.CreateUser(context.Background(), UserInput{Nickname: "ben"})
+
+	// assert
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.Len(t, server.createKeys, 2)
+}
+
+func TestGetUser_Missing_ShouldReturnErrNotFound(t *testing.T) {
+	c, _ := startClient(t, Config{})
+
+	// act
+	_, err := c.GetUser(context.Background(), uuid.New())
+
+	// assert
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Contains(t, err.Error(), "no user in database")
+}
+
+func TestUsers_ShouldIterateOverAllPages(t *testing.T) {
+	c, server := startClient(t, Config{PageSize: 2})
+	addUsers(server, 5, "DE")
+	addUsers(server, 2, "FR")
+
+	// act
+	it := c.Users(context.Background(), Filter{Country: "DE"})
+	var users []User
+	for it.Next() {
+		users = append(users, it.User())
+	}
+
+	// assert
+	assert.Nil(t, it.Err())
+	assert.Len(t, users, 5)
+	assert.Equal(t, server.users[4].Id, users[4].Id.String())
+	assert.Equal(t, []int32{2, 2, 2}, server.pageLimits)
+}
+
+func TestUsers_FullLastPage_ShouldStopOnEmptyPage(t *testing.T) {
+	c, server := startClient(t, Config{PageSize: 2})
+	addUsers(server, 4, "DE")
+
+	// act
+	it := c.Users(context.Background(), Filter{})
+	n := 0
+	for it.Next() {
+		n++
+	}
+
+	// assert
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 4, n)
+	assert.Len(t, server.pageLimits, 3)
+}
+
+func TestBackoff_ShouldDoubleUpToMax(t *testing.T) {
+	c := NewClient(nil, Config{Backoff: time.Second, MaxBackoff: 3 * time.Second})
+
+	assert.Equal(t, time.Second, c.backoff(1))
+	assert.Equal(t, 2*time.Second, c.backoff(2))
+	assert.Equal(t, 3*time.Second, c.backoff(3))
+}
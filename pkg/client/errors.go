@@ -0,0 +1,70 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"usermanager/app/domain"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors returned by the client. Use errors.Is, the server message is
+// kept in the returned error.
+var (
+	ErrNotFound         = errors.New("user not found")
+	ErrNicknameTaken    = errors.New("nickname is taken")
+	ErrEmailTaken       = errors.New("email is taken")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrUnavailable      = errors.New("server unavailable")
+)
+
+// Map status error of the server to the typed error. Errors without
+// a typed error are returned as they are.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var typed error
+	switch st.Code() {
+	case codes.NotFound:
+		typed = ErrNotFound
+	case codes.InvalidArgument, codes.AlreadyExists:
+		// unique constraints are reported with the reason in the details
+		switch reason(st) {
+		case domain.ErrorReasonNicknameTaken:
+			typed = ErrNicknameTaken
+		case domain.ErrorReasonEmailTaken:
+			typed = ErrEmailTaken
+		default:
+			typed = ErrInvalidArgument
+		}
+	case codes.Unauthenticated:
+		typed = ErrUnauthenticated
+	case codes.PermissionDenied:
+		typed = ErrPermissionDenied
+	case codes.Unavailable:
+		typed = ErrUnavailable
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", typed, st.Message())
+}
+
+// Reason of the server ErrorInfo detail, empty if there is none.
+func reason(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == domain.ErrorDomain {
+			return info.Reason
+		}
+	}
+	return ""
+}
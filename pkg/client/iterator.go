@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+
+	proto "usermanager/app/ui/protos/user"
+)
+
+// Iterates over users page by page, the next page is requested when
+// the current one is consumed:
+//
+//	it := c.Users(ctx, client.Filter{Country: "DE"})
+//	for it.Next() {
+//		user := it.User()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type UserIterator struct {
+	ctx    context.Context
+	client *client
+	filter *proto.UserPageRequest_UserFilterOptions
+	limit  int32
+	offset int32
+	page   []*proto.UserPageResponse_User
+	// position in the page
+	pos  int
+	user User
+	last bool
+	err  error
+}
+
+// Advance to the next user. Returns false when there are no more
+// users or the request failed.
+func (it *UserIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.pos++
+	if it.page == nil || it.pos >= len(it.page) {
+		if it.last {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+
+	user, err := fromProto(it.page[it.pos])
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.user = user
+	return true
+}
+
+// Current user.
+func (it *UserIterator) User() User {
+	return it.user
+}
+
+// Error that stopped the iteration, nil if all users were read.
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+func (it *UserIterator) fetch() bool {
+	page, err := it.client.page(it.ctx, &proto.UserPageRequest{
+		Offset: it.offset,
+		Limit:  it.limit,
+		Filter: it.filter,
+	})
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page = page
+	it.pos = 0
+	it.offset += int32(len(page))
+	// short page is the last one
+	it.last = int32(len(page)) < it.limit
+	return len(page) > 0
+}
@@ -0,0 +1,67 @@
+package userevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+const (
+	cloudEventsContentType  = "application/cloudevents+json"
+	cloudEventsHeaderPrefix = "cloudEvents:"
+)
+
+// Structured mode body, only the attributes the consumer uses.
+type cloudEvent struct {
+	Id   string          `json:"id"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+type eventData struct {
+	UserId string `json:"user_id"`
+}
+
+// Event of the delivery in any of the formats the user manager
+// publishes: user id as plain text, or a CloudEvent in structured or
+// binary mode.
+func decode(d amqp.Delivery) (Event, error) {
+	switch {
+	case strings.HasPrefix(d.ContentType, cloudEventsContentType):
+		var ce cloudEvent
+		if err := json.Unmarshal(d.Body, &ce); err != nil {
+			return Event{}, fmt.Errorf("cannot decode cloud event: %w", err)
+		}
+		return decodeData(ce.Id, ce.Time, ce.Data)
+
+	case d.Headers[cloudEventsHeaderPrefix+"specversion"] != nil:
+		id, _ := d.Headers[cloudEventsHeaderPrefix+"id"].(string)
+		var t time.Time
+		if s, ok := d.Headers[cloudEventsHeaderPrefix+"time"].(string); ok {
+			t, _ = time.Parse(time.RFC3339Nano, s)
+		}
+		return decodeData(id, t, d.Body)
+	}
+
+	userId, err := uuid.Parse(strings.TrimSpace(string(d.Body)))
+	if err != nil {
+		return Event{}, fmt.Errorf("cannot decode user id: %w", err)
+	}
+	return Event{UserId: userId}, nil
+}
+
+func decodeData(id string, t time.Time, data []byte) (Event, error) {
+	var ed eventData
+	if err := json.Unmarshal(data, &ed); err != nil {
+		return Event{}, fmt.Errorf("cannot decode cloud event data: %w", err)
+	}
+	userId, err := uuid.Parse(ed.UserId)
+	if err != nil {
+		return Event{}, fmt.Errorf("cannot decode user id: %w", err)
+	}
+	return Event{Id: id, Time: t, UserId: userId}, nil
+}
@@ -0,0 +1,60 @@
+package userevents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode_PlainUserId_ShouldReturnEvent(t *testing.T) {
+	id := uuid.New()
+
+	event, err := decode(amqp.Delivery{ContentType: "text/plain", Body: []byte(id.String())})
+
+	assert.Nil(t, err)
+	assert.Equal(t, Event{UserId: id}, event)
+}
+
+func TestDecode_StructuredCloudEvent_ShouldReturnEvent(t *testing.T) {
+	id := uuid.New()
+	body := `{"specversion": "1.0", "id": "event-1", "source": "/usermanager",
+		"type": "com.usermanager.user.updated", "subject": "` + id.String() + `",
+		"time": "2024-05-01T10:00:00Z", "datacontenttype": "application/json",
+		"data": {"user_id": "` + id.String() + `"}}`
+
+	event, err := decode(amqp.Delivery{ContentType: "application/cloudevents+json", Body: []byte(body)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, id, event.UserId)
+	assert.Equal(t, "event-1", event.Id)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), event.Time)
+}
+
+func TestDecode_BinaryCloudEvent_ShouldReturnEvent(t *testing.T) {
+	id := uuid.New()
+	d := amqp.Delivery{
+		ContentType: "application/json",
+		Headers: amqp.Table{
+			"cloudEvents:specversion": "1.0",
+			"cloudEvents:id":          "event-1",
+			"cloudEvents:time":        "2024-05-01T10:00:00Z",
+		},
+		Body: []byte(`{"user_id": "` + id.String() + `"}`),
+	}
+
+	event, err := decode(d)
+
+	assert.Nil(t, err)
+	assert.Equal(t, id, event.UserId)
+	assert.Equal(t, "event-1", event.Id)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), event.Time)
+}
+
+func TestDecode_CloudEventWithoutUserId_ShouldReturnErr(t *testing.T) {
+	_, err := decode(amqp.Delivery{ContentType: "application/cloudevents+json", Body: []byte(`{"data": {}}`)})
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,151 @@
+package userevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Message headers set by the consumer.
+const (
+	// delivery attempt of the retried message
+	AttemptHeader = "x-attempt"
+	// error of the message moved to the dead letter queue
+	ErrorHeader = "x-error"
+)
+
+var tracer = otel.Tracer("usermanager/pkg/userevents")
+
+// Decode the delivery and call the handler. A failed message is
+// retried through the retry queue, and after the last attempt, or if
+// it cannot be decoded, moved to the dead letter queue. The delivery
+// is acked once it is handled or moved, and requeued if moving fails.
+// Transient consumers drop failed messages instead.
+func (c *consumer) process(ctx context.Context, ch publisher, d amqp.Delivery, handler Handler) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headersCarrier(d.Headers))
+	ctx, span := tracer.Start(ctx, c.config.Queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingSourceName(c.config.Queue),
+		))
+	defer span.End()
+
+	event, err := decode(d)
+	if err != nil && c.config.Transient {
+		span.SetStatus(codes.Error, "cannot decode message")
+		log.Error().Err(err).Msg("cannot decode user event, dropping it")
+		c.settle(d, nil)
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, "cannot decode message")
+		log.Error().Err(err).Msg("cannot decode user event, moving it to the dead letter queue")
+		c.settle(d, c.deadLetter(ch, d, err))
+		return
+	}
+	event.Attempt = attemptOf(d)
+
+	err = c.handle(ctx, handler, event)
+	if err == nil {
+		c.settle(d, nil)
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	if c.config.Transient {
+		log.Error().Err(err).Msgf("user event for %v failed, dropping it", event.UserId)
+		c.settle(d, nil)
+		return
+	}
+	if event.Attempt >= c.config.MaxAttempts {
+		log.Error().Err(err).Msgf("user event for %v failed %v times, moving it to the dead letter queue",
+			event.UserId, event.Attempt)
+		c.settle(d, c.deadLetter(ch, d, err))
+		return
+	}
+	log.Warn().Err(err).Msgf("user event for %v failed, retrying in %v", event.UserId, c.config.RetryDelay)
+	c.settle(d, c.retry(ch, d, event.Attempt+1))
+}
+
+// Resolve the user if the resolver is set and call the handler.
+func (c *consumer) handle(ctx context.Context, handler Handler, e Event) error {
+	if c.config.Resolver != nil {
+		user, err := c.config.Resolver.Resolve(ctx, e.UserId)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			e.Deleted = true
+		case err != nil:
+			return fmt.Errorf("cannot resolve user: %w", err)
+		default:
+			e.User = user
+		}
+	}
+	return handler(ctx, e)
+}
+
+// Publish copy of the delivery to the retry queue, where it waits for
+// the retry delay.
+func (c *consumer) retry(ch publisher, d amqp.Delivery, attempt int) error {
+	msg := copyDelivery(d)
+	msg.Headers[AttemptHeader] = int32(attempt)
+	msg.Expiration = strconv.FormatInt(c.config.RetryDelay.Milliseconds(), 10)
+	return ch.Publish("", retryQueue(c.config.Queue), false, false, msg)
+}
+
+func (c *consumer) deadLetter(ch publisher, d amqp.Delivery, cause error) error {
+	msg := copyDelivery(d)
+	msg.Headers[ErrorHeader] = cause.Error()
+	return ch.Publish("", deadLetterQueue(c.config.Queue), false, false, msg)
+}
+
+// Ack the delivery if it was moved, otherwise requeue it so it isn't lost.
+func (c *consumer) settle(d amqp.Delivery, moveErr error) {
+	if moveErr != nil {
+		log.Error().Err(moveErr).Msg("cannot move user event, requeueing it")
+		if err := d.Nack(false, true); err != nil {
+			log.Error().Err(err).Msg("cannot requeue user event")
+		}
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		log.Error().Err(err).Msg("cannot ack user event")
+	}
+}
+
+func copyDelivery(d amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         d.Body,
+	}
+}
+
+// Attempt from the header, 1 for the first delivery.
+func attemptOf(d amqp.Delivery) int {
+	switch v := d.Headers[AttemptHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 1
+}
@@ -0,0 +1,255 @@
+package userevents
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records acks of the delivery.
+type fakeAcknowledger struct {
+	acked   bool
+	requeue bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.requeue = requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.requeue = requeue
+	return nil
+}
+
+type published struct {
+	key string
+	msg amqp.Publishing
+}
+
+// Records published messages.
+type fakePublisher struct {
+	messages []published
+	err      error
+}
+
+func (p *fakePublisher) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, published{key: key, msg: msg})
+	return nil
+}
+
+type fakeResolver struct {
+	user *proto.UserPageResponse_User
+	err  error
+}
+
+func (r *fakeResolver) Resolve(ctx context.Context, id uuid.UUID) (*proto.UserPageResponse_User, error) {
+	return r.user, r.err
+}
+
+func newTestConsumer(resolver UserResolver) *consumer {
+	return NewConsumer(Config{
+		Queue:       "billing",
+		MaxAttempts: 3,
+		RetryDelay:  2 * time.Second,
+		Resolver:    resolver,
+	})
+}
+
+func delivery(body string, headers amqp.Table) (amqp.Delivery, *fakeAcknowledger) {
+	ack := &fakeAcknowledger{}
+	return amqp.Delivery{Acknowledger: ack, Headers: headers, Body: []byte(body)}, ack
+}
+
+func TestProcess_HandlerPass_ShouldAck(t *testing.T) {
+	c := newTestConsumer(nil)
+	ch := &fakePublisher{}
+
+	// arrange
+	id := uuid.New()
+	d, ack := delivery(id.String(), nil)
+	var received Event
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		received = e
+		return nil
+	})
+
+	// assert
+	assert.True(t, ack.acked)
+	assert.Empty(t, ch.messages)
+	assert.Equal(t, Event{UserId: id, Attempt: 1}, received)
+}
+
+func TestProcess_HandlerErr_ShouldPublishToRetryQueue(t *testing.T) {
+	c := newTestConsumer(nil)
+	ch := &fakePublisher{}
+
+	// arrange
+	d, ack := delivery(uuid.NewString(), amqp.Table{"traceparent": "00-01-02-01"})
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		return errors.New("billing is down")
+	})
+
+	// assert
+	assert.True(t, ack.acked)
+	assert.Len(t, ch.messages, 1)
+	assert.Equal(t, "billing.retry", ch.messages[0].key)
+	assert.Equal(t, "2000", ch.messages[0].msg.Expiration)
+	assert.Equal(t, int32(2), ch.messages[0].msg.Headers[AttemptHeader])
+	assert.Equal(t, "00-01-02-01", ch.messages[0].msg.Headers["traceparent"])
+	assert.Equal(t, d.Body, ch.messages[0].msg.Body)
+}
+
+func TestProcess_TransientHandlerErr_ShouldDropMessage(t *testing.T) {
+	c := NewConsumer(Config{Queue: "cache", Transient: true})
+	ch := &fakePublisher{}
+
+	// arrange
+	d, ack := delivery(uuid.NewString(), nil)
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		return errors.New("cache is down")
+	})
+
+	// assert
+	assert.True(t, ack.acked)
+	assert.Empty(t, ch.messages)
+}
+
+func TestProcess_LastAttemptFails_ShouldPublishToDeadLetterQueue(t *testing.T) {
+	c := newTestConsumer(nil)
+	ch := &fakePublisher{}
+
+	// arrange
+	d, ack := delivery(uuid.NewString(), amqp.Table{AttemptHeader: int32(3)})
+	var attempt int
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		attempt = e.Attempt
+		return errors.New("billing is down")
+	})
+
+	// assert
+	assert.Equal(t, 3, attempt)
+	assert.True(t, ack.acked)
+	assert.Len(t, ch.messages, 1)
+	assert.Equal(t, "billing.dlq", ch.messages[0].key)
+	assert.Equal(t, "billing is down", ch.messages[0].msg.Headers[ErrorHeader])
+}
+
+func TestProcess_InvalidBody_ShouldPublishToDeadLetterQueue(t *testing.T) {
+	c := newTestConsumer(nil)
+	ch := &fakePublisher{}
+	called := false
+
+	// arrange
+	d, ack := delivery("not an id", nil)
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		called = true
+		return nil
+	})
+
+	// assert
+	assert.False(t, called)
+	assert.True(t, ack.acked)
+	assert.Len(t, ch.messages, 1)
+	assert.Equal(t, "billing.dlq", ch.messages[0].key)
+}
+
+func TestProcess_RetryPublishErr_ShouldRequeue(t *testing.T) {
+	c := newTestConsumer(nil)
+	ch := &fakePublisher{err: errors.New("channel closed")}
+
+	// arrange
+	d, ack := delivery(uuid.NewString(), nil)
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		return errors.New("billing is down")
+	})
+
+	// assert
+	assert.False(t, ack.acked)
+	assert.True(t, ack.requeue)
+}
+
+func TestProcess_WithResolver_ShouldPassUser(t *testing.T) {
+	user := &proto.UserPageResponse_User{Id: uuid.NewString(), Nickname: "ben"}
+	c := newTestConsumer(&fakeResolver{user: user})
+
+	// arrange
+	d, _ := delivery(user.Id, nil)
+	var received Event
+
+	// act
+	c.process(context.Background(), &fakePublisher{}, d, func(ctx context.Context, e Event) error {
+		received = e
+		return nil
+	})
+
+	// assert
+	assert.Equal(t, user, received.User)
+	assert.False(t, received.Deleted)
+}
+
+func TestProcess_ResolverNotFound_ShouldPassDeleted(t *testing.T) {
+	c := newTestConsumer(&fakeResolver{err: ErrUserNotFound})
+
+	// arrange
+	d, ack := delivery(uuid.NewString(), nil)
+	var received Event
+
+	// act
+	c.process(context.Background(), &fakePublisher{}, d, func(ctx context.Context, e Event) error {
+		received = e
+		return nil
+	})
+
+	// assert
+	assert.True(t, ack.acked)
+	assert.True(t, received.Deleted)
+	assert.Nil(t, received.User)
+}
+
+func TestProcess_ResolverErr_ShouldRetryWithoutCallingHandler(t *testing.T) {
+	c := newTestConsumer(&fakeResolver{err: errors.New("unavailable")})
+	ch := &fakePublisher{}
+	called := false
+
+	// arrange
+	d, _ := delivery(uuid.NewString(), nil)
+
+	// act
+	c.process(context.Background(), ch, d, func(ctx context.Context, e Event) error {
+		called = true
+		return nil
+	})
+
+	// assert
+	assert.False(t, called)
+	assert.Len(t, ch.messages, 1)
+	assert.Equal(t, "billing.retry", ch.messages[0].key)
+}
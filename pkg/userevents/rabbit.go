@@ -0,0 +1,119 @@
+package userevents
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// Part of the amqp channel used to move failed messages.
+type publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Open connection with deliveries of the consumer queue.
+type session struct {
+	channel    publisher
+	deliveries <-chan amqp.Delivery
+	conn       *amqp.Connection
+}
+
+func (s *session) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+func retryQueue(queue string) string {
+	return queue + ".retry"
+}
+
+func deadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+// Connect, declare the topology and start consuming with manual acks.
+func dial(c Config) (*session, error) {
+	conn, err := amqp.Dial(c.Url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to rabbit: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot open rabbit channel: %w", err)
+	}
+
+	if err := declare(ch, c); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Qos(c.Prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot set prefetch: %w", err)
+	}
+
+	deliveries, err := ch.Consume(
+		c.Queue, // queue
+		"",      // consumer
+		false,   // auto-ack
+		false,   // exclusive
+		false,   // no-local
+		false,   // no-wait
+		nil,     // args
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot consume queue %v: %w", c.Queue, err)
+	}
+
+	return &session{channel: ch, deliveries: deliveries, conn: conn}, nil
+}
+
+// Declare the exchange, the consumer queue bound to it, the retry queue
+// that returns expired messages to the consumer queue and the dead
+// letter queue. Transient queues have neither.
+func declare(ch *amqp.Channel, c Config) error {
+	err := ch.ExchangeDeclare(
+		c.Exchange, // name
+		"fanout",   // type
+		true,       // durable
+		false,      // auto-deleted
+		false,      // internal
+		false,      // no-wait
+		nil,        // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("cannot declare exchange %v: %w", c.Exchange, err)
+	}
+
+	// transient queue is exclusive to the connection and auto-deleted
+	durable := !c.Transient
+	if _, err := ch.QueueDeclare(c.Queue, durable, c.Transient, c.Transient, false, nil); err != nil {
+		return fmt.Errorf("cannot declare queue %v: %w", c.Queue, err)
+	}
+	if err := ch.QueueBind(c.Queue, "", c.Exchange, false, nil); err != nil {
+		return fmt.Errorf("cannot bind queue %v: %w", c.Queue, err)
+	}
+	if c.Transient {
+		return nil
+	}
+
+	// messages expire in the retry queue and go back through the
+	// default exchange
+	retryArgs := amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": c.Queue,
+	}
+	if _, err := ch.QueueDeclare(retryQueue(c.Queue), true, false, false, false, retryArgs); err != nil {
+		return fmt.Errorf("cannot declare retry queue: %w", err)
+	}
+
+	if _, err := ch.QueueDeclare(deadLetterQueue(c.Queue), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("cannot declare dead letter queue: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package userevents
+
+import (
+	"context"
+	"errors"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// User doesn't exist anymore.
+var ErrUserNotFound = errors.New("user not found")
+
+// Fetches the current state of the user.
+type UserResolver interface {
+	// Returns ErrUserNotFound if the user doesn't exist.
+	Resolve(ctx context.Context, id uuid.UUID) (*proto.UserPageResponse_User, error)
+}
+
+type grpcResolver struct {
+	client proto.UserServiceClient
+}
+
+// Create resolver that calls the GetUser RPC. Credentials are set up
+// on the connection, the caller needs the read permission.
+func NewGrpcResolver(conn grpc.ClientConnInterface) *grpcResolver {
+	return &grpcResolver{client: proto.NewUserServiceClient(conn)}
+}
+
+func (r *grpcResolver) Resolve(ctx context.Context, id uuid.UUID) (*proto.UserPageResponse_User, error) {
+	user, err := r.client.GetUser(ctx, &proto.GetUserRequest{Id: id.String()})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
@@ -0,0 +1,44 @@
+package userevents
+
+import (
+	"context"
+	"testing"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Answers GetUser, other methods are not used.
+type fakeUserClient struct {
+	proto.UserServiceClient
+	user *proto.UserPageResponse_User
+	err  error
+}
+
+func (c *fakeUserClient) GetUser(ctx context.Context, in *proto.GetUserRequest,
+	opts ...grpc.CallOption) (*proto.UserPageResponse_User, error) {
+	return c.user, c.err
+}
+
+func TestResolve_UserExists_ShouldReturnUser(t *testing.T) {
+	user := &proto.UserPageResponse_User{Id: uuid.NewString()}
+	r := &grpcResolver{client: &fakeUserClient{user: user}}
+
+	result, err := r.Resolve(context.Background(), uuid.MustParse(user.Id))
+
+	assert.Nil(t, err)
+	assert.Equal(t, user, result)
+}
+
+func TestResolve_NotFound_ShouldReturnErrUserNotFound(t *testing.T) {
+	r := &grpcResolver{client: &fakeUserClient{err: status.Error(codes.NotFound, "no user in database")}}
+
+	_, err := r.Resolve(context.Background(), uuid.New())
+
+	assert.Equal(t, ErrUserNotFound, err)
+}
@@ -0,0 +1,143 @@
+// Package userevents consumes the user change notifications that the
+// user manager publishes to the rabbit fanout exchange.
+package userevents
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	proto "usermanager/app/ui/protos/user"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultExchange       = "notification_queue"
+	defaultPrefetch       = 10
+	defaultMaxAttempts    = 3
+	defaultRetryDelay     = 5 * time.Second
+	defaultReconnectDelay = 5 * time.Second
+)
+
+// Consumer settings
+type Config struct {
+	Url string
+	// fanout exchange the user manager publishes to
+	Exchange string
+	// durable queue of this consumer, bound to the exchange. Failed
+	// messages wait in <Queue>.retry and end up in <Queue>.dlq.
+	Queue string
+	// unacked messages delivered at once
+	Prefetch int
+	// handler attempts of one message, including the first one
+	MaxAttempts int
+	// delay before the message is delivered again after a failure
+	RetryDelay time.Duration
+	// delay between connection attempts while rabbit is unreachable
+	ReconnectDelay time.Duration
+	// fetches the user before the handler is called, optional
+	Resolver UserResolver
+	// queue exists only while the consumer is connected, without retry
+	// and dead letter queues. Failed messages are dropped. For consumers
+	// that only care about changes while they run, like a local cache.
+	Transient bool
+}
+
+// User change notification.
+type Event struct {
+	// CloudEvents id and time, not set for plain messages
+	Id     string
+	Time   time.Time
+	UserId uuid.UUID
+	// current state of the user, set by the resolver
+	User *proto.UserPageResponse_User
+	// resolver didn't find the user
+	Deleted bool
+	// delivery attempt, starting with 1
+	Attempt int
+}
+
+// Handles one event. Returned error retries the event.
+type Handler func(ctx context.Context, e Event) error
+
+type Consumer interface {
+	// Consume events until ctx is done. Keeps reconnecting while rabbit
+	// is unreachable. Returns ctx error once ctx is done.
+	Subscribe(ctx context.Context, handler Handler) error
+}
+
+type consumer struct {
+	config Config
+	dial   func(c Config) (*session, error)
+}
+
+// Create consumer with defaults for the unset settings.
+func NewConsumer(c Config) *consumer {
+	if c.Exchange == "" {
+		c.Exchange = defaultExchange
+	}
+	if c.Prefetch <= 0 {
+		c.Prefetch = defaultPrefetch
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = defaultRetryDelay
+	}
+	if c.ReconnectDelay <= 0 {
+		c.ReconnectDelay = defaultReconnectDelay
+	}
+	return &consumer{
+		config: c,
+		dial:   dial,
+	}
+}
+
+func (c *consumer) Subscribe(ctx context.Context, handler Handler) error {
+	if c.config.Queue == "" {
+		return errors.New("userevents: queue is required")
+	}
+	if handler == nil {
+		return errors.New("userevents: handler is required")
+	}
+
+	for {
+		s, err := c.dial(c.config)
+		if err == nil {
+			log.Info().Msgf("consuming user events from queue %v", c.config.Queue)
+			c.consume(ctx, s, handler)
+			s.Close()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warn().Msgf("user events connection lost, reconnecting in %v", c.config.ReconnectDelay)
+
+		timer := time.NewTimer(c.config.ReconnectDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Process deliveries one by one until the connection is lost or ctx
+// is done.
+func (c *consumer) consume(ctx context.Context, s *session, handler Handler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-s.deliveries:
+			if !ok {
+				return
+			}
+			c.process(ctx, s.channel, d, handler)
+		}
+	}
+}
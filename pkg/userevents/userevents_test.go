@@ -0,0 +1,63 @@
+package userevents
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe_WithoutQueue_ShouldReturnErr(t *testing.T) {
+	c := NewConsumer(Config{})
+
+	err := c.Subscribe(context.Background(), func(ctx context.Context, e Event) error { return nil })
+
+	assert.NotNil(t, err)
+}
+
+func TestSubscribe_ConnectionLost_ShouldReconnect(t *testing.T) {
+	c := NewConsumer(Config{Queue: "billing", ReconnectDelay: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// arrange
+	first, second := uuid.New(), uuid.New()
+	dials := 0
+	c.dial = func(Config) (*session, error) {
+		dials++
+		deliveries := make(chan amqp.Delivery, 1)
+		switch dials {
+		case 1:
+			return nil, errors.New("connection refused")
+		case 2:
+			d, _ := delivery(first.String(), nil)
+			deliveries <- d
+			close(deliveries)
+		default:
+			d, _ := delivery(second.String(), nil)
+			deliveries <- d
+		}
+		return &session{channel: &fakePublisher{}, deliveries: deliveries}, nil
+	}
+
+	var received []uuid.UUID
+	handler := func(ctx context.Context, e Event) error {
+		received = append(received, e.UserId)
+		if e.UserId == second {
+			cancel()
+		}
+		return nil
+	}
+
+	// act
+	err := c.Subscribe(ctx, handler)
+
+	// assert
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 3, dials)
+	assert.Equal(t, []uuid.UUID{first, second}, received)
+}